@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/types"
+)
+
+const cleanupBatch = 100
+
+// NewKey - ключ новой копии. Случайная часть нужна, чтобы копия, сохраненная заново после
+// удаления старой записи, не пересеклась с ее удалением в хранилище
+func NewKey(fileUniqueID string) string {
+	return fmt.Sprintf("media/%s-%s", fileUniqueID, uuid.New().String())
+}
+
+// Enqueue ставит файл в очередь на архивацию. Если копия файла уже есть, только добавляет к ней пользователя
+func Enqueue(
+	ctx context.Context,
+	service *repository.MongoRepository,
+	rdb *redis.Redis,
+	cfg *config.ArchiveConfig,
+	botID int64,
+	userID int64,
+	media *types.MediaItem,
+) error {
+	if media.FileUniqueID == "" || media.FileSize > cfg.MaxSize {
+		metrics.ArchivedFilesTotal.WithLabelValues("skipped").Inc()
+		return nil
+	}
+
+	found, err := service.AddArchivedFileUser(ctx, media.FileUniqueID, userID)
+	if err != nil {
+		return fmt.Errorf("failed add archived file user: %w", err)
+	}
+	if found {
+		metrics.ArchivedFilesTotal.WithLabelValues("duplicate").Inc()
+		return nil
+	}
+
+	return rdb.EnqueueJob(ctx, consts.REDIS_QUEUE_FILES, redis.Job{
+		Type:   redis.JobTypeArchive,
+		File:   media,
+		UserID: userID,
+		BotID:  botID,
+	})
+}
+
+// Open открывает копию файла пользователя. ErrNotFound - копии нет
+func Open(
+	ctx context.Context,
+	service *repository.MongoRepository,
+	storage Storage,
+	userID int64,
+	fileUniqueID string,
+) (io.ReadCloser, *repository.ArchivedFile, error) {
+	file, err := service.GetArchivedFile(ctx, fileUniqueID, userID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed get archived file: %w", err)
+	}
+
+	r, err := storage.Open(ctx, file.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, file, nil
+}
+
+// RunCleanup удаляет копии, у которых не осталось пользователей. Запускается один раз на реплику
+func RunCleanup(ctx context.Context, service *repository.MongoRepository, storage Storage, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		files, err := service.OrphanArchivedFiles(ctx, cleanupBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Msg("failed get orphan archived files")
+			}
+			continue
+		}
+
+		for _, file := range files {
+			// сначала запись: если файл успели взять снова, она не удалится и копия останется
+			deleted, err := service.DeleteOrphanArchivedFile(ctx, file.FileUniqueID)
+			if err != nil {
+				log.Warn().Err(err).Str("fileUniqueID", file.FileUniqueID).Msg("failed delete orphan archived file")
+				continue
+			}
+			if !deleted {
+				continue
+			}
+
+			if err := storage.Delete(ctx, file.Key); err != nil {
+				log.Warn().Err(err).Str("key", file.Key).Msg("failed delete archived file from storage")
+				continue
+			}
+			metrics.ArchivedFilesTotal.WithLabelValues("deleted").Inc()
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+)
+
+var ErrNotFound = errors.New("archived file not found")
+
+// Storage - хранилище копий файлов. Ключи создает NewKey, они уникальны для каждой копии,
+// поэтому Put никогда не перезаписывает чужой файл
+type Storage interface {
+	// Put сохраняет size байт из r
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Open возвращает ErrNotFound, если файла нет
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete не считает ошибкой, если файла уже нет
+	Delete(ctx context.Context, key string) error
+}
+
+func NewStorage(cfg *config.ArchiveConfig) (Storage, error) {
+	switch cfg.Backend {
+	case consts.ARCHIVE_BACKEND_LOCAL:
+		return NewLocalStorage(cfg.Dir)
+	case consts.ARCHIVE_BACKEND_S3:
+		return NewS3Storage(cfg.S3)
+	}
+	return nil, fmt.Errorf("unknown archive backend %q", cfg.Backend)
+}
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/rs/zerolog"
@@ -16,12 +18,15 @@ import (
 	"ssuspy-bot/metrics"
 	"ssuspy-bot/redis"
 	"ssuspy-bot/repository"
+	"ssuspy-bot/retention"
 	"ssuspy-bot/telegram"
 	"ssuspy-bot/telegram/locales"
+	"ssuspy-common/crypto"
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	zerolog.TimeFieldFormat = time.RFC3339
 	// zerolog.ErrorStackMarshaler = pkgerrors.MarshalStack
@@ -50,11 +55,24 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to initialize i18n")
 	}
 
-	mongoRepo, err := repository.NewMongoRepository(cfg.Mongo)
+	box, err := crypto.NewBox(cfg.TokenEncryptionKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid token encryption key")
+	}
+
+	var messageKeys *crypto.Keyring
+	if cfg.MessageEncryption.Enabled {
+		messageKeys, err = crypto.NewKeyring(cfg.MessageEncryption.Key)
+		if err != nil {
+			log.Fatal().Err(err).Msg("invalid message encryption key")
+		}
+	}
+
+	mongoRepo, err := repository.NewMongoRepository(cfg.Mongo, box, messageKeys)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to MongoDB")
 	}
-	defer mongoRepo.Disconnect(ctx)
+	defer mongoRepo.Disconnect(context.Background())
 
 	rdb, err := redis.NewRedis(cfg.Redis)
 	if err != nil {
@@ -64,8 +82,14 @@ func main() {
 	mux := http.NewServeMux()
 	go metrics.RunMetrics(mux)
 
-	telegram.RunTelegram(ctx, mux, mongoRepo, &rdb)
+	coordinator := telegram.RunTelegram(ctx, mux, mongoRepo, &rdb)
+
+	go retention.NewPurger(mongoRepo, cfg.Retention).Run(ctx)
+
+	<-ctx.Done()
+	log.Info().Msg("shutting down, releasing bots")
 
-	quit := make(chan bool)
-	<-quit
+	leaveCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	coordinator.Leave(leaveCtx)
 }
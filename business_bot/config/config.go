@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/url"
+	"time"
 
 	"github.com/sethvargo/go-envconfig"
+
+	"ssuspy-bot/consts"
+	"ssuspy-common/grpcauth"
 )
 
 var Config StructConfig
@@ -16,18 +20,46 @@ func NewConfig() (StructConfig, error) {
 	if err := envconfig.Process(context.Background(), &config); err != nil {
 		return StructConfig{}, err
 	}
+	if err := config.Updates.Validate(); err != nil {
+		return StructConfig{}, err
+	}
+	if err := config.FilesFetcher.Validate(); err != nil {
+		return StructConfig{}, err
+	}
+	if err := config.Archive.Validate(); err != nil {
+		return StructConfig{}, err
+	}
+	if err := config.Quota.Validate(); err != nil {
+		return StructConfig{}, err
+	}
+	if err := config.Shard.Validate(); err != nil {
+		return StructConfig{}, err
+	}
 
 	Config = config
 	return config, nil
 }
 
 type StructConfig struct {
-	Mongo             *MongoConfig `env:", prefix=MONGO_"`
-	Redis             *RedisConfig `env:", prefix=REDIS_"`
-	TelegramBot       *BotConfig   `env:", prefix=TELEGRAM_"`
-	BusinessGithubURL string       `env:"BUSINESS_GITHUB_URL"`
-	FilesWorkers      int          `env:"FILES_WORKERS, default=5"`
-	DevMode           bool         `env:"DEV_MODE, default=false"`
+	Mongo              *MongoConfig             `env:", prefix=MONGO_"`
+	Redis              *RedisConfig             `env:", prefix=REDIS_"`
+	Health             *HealthConfig            `env:", prefix=HEALTH_"`
+	Shard              *ShardConfig             `env:", prefix=SHARD_"`
+	Grpc               *GrpcConfig              `env:", prefix=GRPC_"`
+	Updates            *UpdatesConfig           `env:", prefix=UPDATES_"`
+	TelegramBot        *BotConfig               `env:", prefix=TELEGRAM_"`
+	Retention          *RetentionConfig         `env:", prefix=RETENTION_"`
+	MessageEncryption  *MessageEncryptionConfig `env:", prefix=MESSAGE_ENCRYPTION_"`
+	Webhooks           *WebhooksConfig          `env:", prefix=WEBHOOKS_"`
+	FilesQueue         *FilesQueueConfig        `env:", prefix=FILES_QUEUE_"`
+	FilesFetcher       *FilesFetcherConfig      `env:", prefix=FILES_FETCHER_"`
+	Archive            *ArchiveConfig           `env:", prefix=ARCHIVE_"`
+	Quota              *QuotaConfig             `env:", prefix=QUOTA_"`
+	Digest             *DigestConfig            `env:", prefix=DIGEST_"`
+	BusinessGithubURL  string                   `env:"BUSINESS_GITHUB_URL"`
+	FilesWorkers       int                      `env:"FILES_WORKERS, default=5"`
+	DevMode            bool                     `env:"DEV_MODE, default=false"`
+	TokenEncryptionKey string                   `env:"TOKEN_ENCRYPTION_KEY, required"`
 }
 
 type MongoConfig struct {
@@ -65,5 +97,223 @@ type RedisConfig struct {
 }
 
 type BotConfig struct {
-	ApiURL string `env:"API_URL, required"`
+	ApiURL string `env:"API_URL, default=https://api.telegram.org"`
+}
+
+type RetentionConfig struct {
+	Interval  time.Duration `env:"INTERVAL, default=1h"`
+	BatchSize int           `env:"BATCH_SIZE, default=1000"`
+}
+
+// DigestConfig - отправка сводок уведомлений
+type DigestConfig struct {
+	// Interval - как часто искать сводки, которым пора уйти
+	Interval time.Duration `env:"INTERVAL, default=1m"`
+	// ClaimTimeout - через сколько события, взятые упавшей репликой, снова можно отправить
+	ClaimTimeout time.Duration `env:"CLAIM_TIMEOUT, default=10m"`
+	// неотправленная сводка повторяется через BackoffBase, дальше интервал удваивается, но не больше BackoffMax.
+	// После MaxAttempts попыток события удаляются
+	MaxAttempts int           `env:"MAX_ATTEMPTS, default=5"`
+	BackoffBase time.Duration `env:"BACKOFF_BASE, default=1m"`
+	BackoffMax  time.Duration `env:"BACKOFF_MAX, default=30m"`
+}
+
+type MessageEncryptionConfig struct {
+	Enabled bool `env:"ENABLED, default=false"`
+	// мастер-ключ, из него выводятся ключи пользователей, base64 32 байта
+	Key string `env:"KEY"`
+}
+
+// FilesQueueConfig - очередь задач воркеров файлов и экспорта
+type FilesQueueConfig struct {
+	// VisibilityTimeout - пока задача обрабатывается, воркер продлевает ее срок, а если не продлил
+	// (упал или завис), после этого времени задача считается упавшей
+	VisibilityTimeout time.Duration `env:"VISIBILITY_TIMEOUT, default=2m"`
+	MaxAttempts       int           `env:"MAX_ATTEMPTS, default=5"`
+	BackoffBase       time.Duration `env:"BACKOFF_BASE, default=10s"`
+	BackoffMax        time.Duration `env:"BACKOFF_MAX, default=10m"`
+	// DeadLetterSize - сколько последних упавших задач хранить в dead letter списке
+	DeadLetterSize int64 `env:"DEAD_LETTER_SIZE, default=1000"`
+	// ReapInterval - как часто искать зависшие задачи и возвращать отложенные в очередь
+	ReapInterval time.Duration `env:"REAP_INTERVAL, default=5s"`
+	// CaptureWindow - сколько ждать остальные защищенные медиа чата, чтобы прислать их одним альбомом.
+	// Задача уходит в очередь при следующем проходе ReapInterval
+	CaptureWindow time.Duration `env:"CAPTURE_WINDOW, default=5s"`
+}
+
+// WebhooksConfig - доставка событий на вебхуки пользователей
+type WebhooksConfig struct {
+	Workers     int           `env:"WORKERS, default=2"`
+	Timeout     time.Duration `env:"TIMEOUT, default=10s"`
+	MaxAttempts int           `env:"MAX_ATTEMPTS, default=8"`
+	// первая повторная попытка через BackoffBase, дальше интервал удваивается, но не больше BackoffMax
+	BackoffBase time.Duration `env:"BACKOFF_BASE, default=30s"`
+	BackoffMax  time.Duration `env:"BACKOFF_MAX, default=1h"`
+	// DeadLetterSize - сколько последних недоставленных событий хранить в dead letter списке
+	DeadLetterSize int64 `env:"DEAD_LETTER_SIZE, default=1000"`
+	// VisibilityTimeout - если реплика не подтвердила доставку за это время (упала или зависла), событие отправится снова
+	VisibilityTimeout time.Duration `env:"VISIBILITY_TIMEOUT, default=1m"`
+	// AllowPrivate - разрешить адреса локальной сети, иначе через вебхук можно достучаться до внутренних сервисов
+	AllowPrivate bool `env:"ALLOW_PRIVATE, default=false"`
+}
+
+// FilesFetcherConfig - откуда воркер берет файлы защищенных медиа
+type FilesFetcherConfig struct {
+	// local - файл читается с диска, который общий с Bot API в режиме --local,
+	// http - скачивается через /file/bot<token>/, auto - выбирается по пути из getFile
+	Mode string `env:"MODE, default=auto"`
+	// MaxSize - файлы больше не скачиваются, обычный Bot API все равно отдает не больше 20 МБ
+	MaxSize int64         `env:"MAX_SIZE, default=2000000000"`
+	Timeout time.Duration `env:"TIMEOUT, default=10m"`
+	// TempDir - куда скачивать файлы, пустой - системная временная папка
+	TempDir string `env:"TEMP_DIR"`
+}
+
+func (f *FilesFetcherConfig) Validate() error {
+	switch f.Mode {
+	case consts.FILES_FETCHER_MODE_AUTO, consts.FILES_FETCHER_MODE_LOCAL, consts.FILES_FETCHER_MODE_HTTP:
+		return nil
+	}
+	return fmt.Errorf(
+		"unknown files fetcher mode %q, expected %q, %q or %q",
+		f.Mode,
+		consts.FILES_FETCHER_MODE_AUTO,
+		consts.FILES_FETCHER_MODE_LOCAL,
+		consts.FILES_FETCHER_MODE_HTTP,
+	)
+}
+
+// ArchiveConfig - копии медиа из бизнес чатов, чтобы их можно было вернуть, когда file_id перестанет работать
+type ArchiveConfig struct {
+	Enabled bool   `env:"ENABLED, default=false"`
+	Backend string `env:"BACKEND, default=local"`
+	// Dir - папка для backend=local
+	Dir string `env:"DIR, default=/data/archive"`
+	// MaxSize - файлы больше не архивируются, обычный Bot API все равно не отдает больше 20 МБ
+	MaxSize int64 `env:"MAX_SIZE, default=20000000"`
+	// CleanupInterval - как часто удалять файлы, которые больше не нужны ни одному пользователю
+	CleanupInterval time.Duration    `env:"CLEANUP_INTERVAL, default=10m"`
+	S3              *ArchiveS3Config `env:", prefix=S3_"`
+}
+
+// ArchiveS3Config - любое S3-совместимое хранилище (AWS, MinIO, R2 и т.д.)
+type ArchiveS3Config struct {
+	// Endpoint - адрес вместе со схемой, например https://s3.eu-central-1.amazonaws.com
+	Endpoint  string `env:"ENDPOINT"`
+	Region    string `env:"REGION, default=us-east-1"`
+	Bucket    string `env:"BUCKET"`
+	AccessKey string `env:"ACCESS_KEY"`
+	SecretKey string `env:"SECRET_KEY"`
+	// PathStyle - endpoint/bucket/key вместо bucket.endpoint/key, нужен MinIO и большинству self-hosted хранилищ
+	PathStyle bool          `env:"PATH_STYLE, default=true"`
+	Timeout   time.Duration `env:"TIMEOUT, default=10m"`
+}
+
+func (a *ArchiveConfig) Validate() error {
+	if !a.Enabled {
+		return nil
+	}
+
+	switch a.Backend {
+	case consts.ARCHIVE_BACKEND_LOCAL:
+		return nil
+	case consts.ARCHIVE_BACKEND_S3:
+		if a.S3.Endpoint == "" || a.S3.Bucket == "" || a.S3.AccessKey == "" || a.S3.SecretKey == "" {
+			return fmt.Errorf("archive backend %q requires endpoint, bucket, access key and secret key", a.Backend)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown archive backend %q, expected %q or %q", a.Backend, consts.ARCHIVE_BACKEND_LOCAL, consts.ARCHIVE_BACKEND_S3)
+}
+
+// QuotaConfig - сколько один пользователь может хранить в одном боте, 0 - без ограничения.
+// Владелец бота может задать в creator_bot лимиты строже этих, но не мягче
+type QuotaConfig struct {
+	MaxMessages     int64 `env:"MAX_MESSAGES, default=0"`
+	MaxMessageBytes int64 `env:"MAX_MESSAGE_BYTES, default=0"`
+	MaxMediaBytes   int64 `env:"MAX_MEDIA_BYTES, default=0"`
+	// Policy - что делать, когда квота заполнена: reject или drop_oldest
+	Policy string `env:"POLICY, default=reject"`
+	// DropBatch - сколько самых старых сообщений удалять за раз при drop_oldest
+	DropBatch int `env:"DROP_BATCH, default=100"`
+	// CacheTTL - как долго помнить лимиты бота, после изменения в creator_bot они применятся не сразу
+	CacheTTL time.Duration `env:"CACHE_TTL, default=1m"`
+}
+
+func (q *QuotaConfig) Validate() error {
+	if q.DropBatch <= 0 {
+		return fmt.Errorf("quota drop batch must be positive, got %d", q.DropBatch)
+	}
+	switch q.Policy {
+	case consts.QUOTA_POLICY_REJECT, consts.QUOTA_POLICY_DROP_OLDEST:
+		return nil
+	}
+	return fmt.Errorf("unknown quota policy %q, expected %q or %q", q.Policy, consts.QUOTA_POLICY_REJECT, consts.QUOTA_POLICY_DROP_OLDEST)
+}
+
+type UpdatesConfig struct {
+	// webhook - Telegram сам присылает обновления на WebhookURL, polling - бот забирает их через getUpdates
+	Mode string `env:"MODE, default=webhook"`
+	// WebhookURL - базовый адрес, к нему добавляется /bot/<id>
+	WebhookURL string `env:"WEBHOOK_URL, default=http://business-bot:8080"`
+}
+
+func (u *UpdatesConfig) Validate() error {
+	switch u.Mode {
+	case consts.UPDATES_MODE_WEBHOOK, consts.UPDATES_MODE_POLLING:
+		return nil
+	}
+	return fmt.Errorf("unknown updates mode %q, expected %q or %q", u.Mode, consts.UPDATES_MODE_WEBHOOK, consts.UPDATES_MODE_POLLING)
+}
+
+type ShardConfig struct {
+	// ReplicaID - уникальное имя реплики, по умолчанию hostname
+	ReplicaID string `env:"REPLICA_ID"`
+	// AdvertiseAddr - gRPC адрес реплики для других реплик, по умолчанию hostname:50051
+	AdvertiseAddr string `env:"ADVERTISE_ADDR"`
+	// LeaseTTL - через сколько боты упавшей реплики достанутся остальным
+	LeaseTTL          time.Duration `env:"LEASE_TTL, default=15s"`
+	RebalanceInterval time.Duration `env:"REBALANCE_INTERVAL, default=5s"`
+	// StartConcurrency - сколько ботов реплика запускает одновременно
+	StartConcurrency int `env:"START_CONCURRENCY, default=8"`
+}
+
+func (s *ShardConfig) Validate() error {
+	if s.LeaseTTL <= 0 {
+		return fmt.Errorf("shard lease ttl must be positive, got %s", s.LeaseTTL)
+	}
+	if s.StartConcurrency < 1 {
+		return fmt.Errorf("shard start concurrency must be at least 1, got %d", s.StartConcurrency)
+	}
+	return nil
+}
+
+type HealthConfig struct {
+	// MaxQueueDepth - при большей очереди файлов /readyz отвечает 503
+	MaxQueueDepth int64         `env:"MAX_QUEUE_DEPTH, default=1000"`
+	Timeout       time.Duration `env:"TIMEOUT, default=3s"`
+	// BotsToken - токен для /bots (Authorization: Bearer), пустой - /bots выключен
+	BotsToken string `env:"BOTS_TOKEN"`
+}
+
+// GrpcConfig - защита gRPC сервера. Тот же сертификат и токен используются при пересылке запросов другим репликам,
+// поэтому сертификат должен подходить и как серверный, и как клиентский
+type GrpcConfig struct {
+	TLSCert string `env:"TLS_CERT"`
+	TLSKey  string `env:"TLS_KEY"`
+	// TLSCA - CA клиентских сертификатов, если задан - без сертификата подключиться нельзя (mTLS)
+	TLSCA string `env:"TLS_CA"`
+	// TLSServerName - имя из сертификата реплик, если оно не совпадает с адресом SHARD_ADVERTISE_ADDR
+	TLSServerName string `env:"TLS_SERVER_NAME"`
+	// Token - общий секрет с creator_bot, для сетей без mTLS
+	Token string `env:"TOKEN"`
+}
+
+func (g *GrpcConfig) TLS() grpcauth.TLS {
+	return grpcauth.TLS{
+		CertFile:   g.TLSCert,
+		KeyFile:    g.TLSKey,
+		CAFile:     g.TLSCA,
+		ServerName: g.TLSServerName,
+	}
 }
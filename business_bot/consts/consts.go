@@ -9,10 +9,35 @@ const MAX_LEN = 4096
 const MAX_BUTTONS = 8
 
 const MAX_NAME_LEN = 128
+const MAX_BUTTON_TEXT_LEN = 32
 const MAX_MESSAGE_TEXT_LEN = 256
 const MAX_MEDIA_CAPTION_LEN = 1024
 const MAX_USER_MESSAGE_TEXT_LEN = 4096 + 1024
 
+const (
+	UPDATES_MODE_WEBHOOK = "webhook"
+	UPDATES_MODE_POLLING = "polling"
+)
+
+const (
+	// FILES_FETCHER_MODE_AUTO - local, если Bot API отдал абсолютный путь (--local), иначе http
+	FILES_FETCHER_MODE_AUTO  = "auto"
+	FILES_FETCHER_MODE_LOCAL = "local"
+	FILES_FETCHER_MODE_HTTP  = "http"
+)
+
+const (
+	// QUOTA_POLICY_REJECT - новые сообщения не сохраняются, пока пользователь не уложится в квоту
+	QUOTA_POLICY_REJECT = "reject"
+	// QUOTA_POLICY_DROP_OLDEST - перед сохранением удаляются самые старые сообщения пользователя
+	QUOTA_POLICY_DROP_OLDEST = "drop_oldest"
+)
+
+const (
+	ARCHIVE_BACKEND_LOCAL = "local"
+	ARCHIVE_BACKEND_S3    = "s3"
+)
+
 const DATETIME_FOR_FILES = "02-01-2006_15-04-05"
 const DATETIME_FOR_MESSAGE = "02-01-2006 15:04:05"
 
@@ -41,18 +66,61 @@ const (
 	CALLBACK_PREFIX_SETTINGS         = "__11"
 	CALLBACK_PREFIX_SETTINGS_EDITED  = "__12"
 	CALLBACK_PREFIX_SETTINGS_DELETED = "__13"
+
+	CALLBACK_PREFIX_SEARCH = "__14"
+
+	CALLBACK_PREFIX_HISTORY         = "__15"
+	CALLBACK_PREFIX_HISTORY_CHAT    = "__16"
+	CALLBACK_PREFIX_HISTORY_MESSAGE = "__17"
+
+	CALLBACK_PREFIX_EXPORT        = "__18"
+	CALLBACK_PREFIX_EXPORT_FORMAT = "__19"
+	CALLBACK_PREFIX_EXPORT_START  = "__20"
+
+	CALLBACK_PREFIX_SETTINGS_RETENTION = "__21"
+
+	CALLBACK_PREFIX_FORGET_ME = "__22"
+
+	CALLBACK_PREFIX_SETTINGS_WEBHOOK = "__23"
+
+	CALLBACK_PREFIX_SETTINGS_USAGE = "__24"
+
+	CALLBACK_PREFIX_SETTINGS_CHATS = "__25"
+	CALLBACK_PREFIX_SETTINGS_CHAT  = "__26"
+
+	CALLBACK_PREFIX_SETTINGS_DIGEST = "__27"
+
+	CALLBACK_PREFIX_EDITED_TIMELINE = "__28"
+
+	CALLBACK_PREFIX_SETTINGS_CAPTURE = "__29"
+
+	// CALLBACK_PREFIX_STORED_DETAILS - то же, что ___4, но для сообщений, которые не обязательно удалены
+	CALLBACK_PREFIX_STORED_DETAILS = "__30"
 )
 
 const REDIS_IGNORE = "ignore"
 const REDIS_QUEUE_FILES = "queue:files"
+const REDIS_QUEUE_WEBHOOKS = "queue:webhooks"
 const REDIS_PUBLIC_GIFTS = "public_gifts"
 const REDIS_RATELIMIT_COUNT = "rl_count"
 const REDIS_RATELIMIT_QUEUE = "rl_queue"
 const REDIS_RATELIMIT_QUEUE_BUSINESS = "rl_queue_business"
 const REDIS_RATELIMIT_QUEUE_BUSINESS_CONNECTION = "rl_queue_business_connection"
+const REDIS_SHARD_REPLICAS = "shard:replicas"
+const REDIS_SHARD_ADDRS = "shard:addrs"
+const REDIS_SHARD_BOT_LEASE = "shard:bot"
+
+// защищенные медиа, которые ждут отправки одним альбомом
+const REDIS_CAPTURE = "capture"
+
+// значение аренды бота после удаления: пока не истечет, ребалансировка его не запустит
+const SHARD_LEASE_REMOVED = "-"
+
+const GRPC_PORT = "50051"
 
 const REDIS_TTL_IGNORE = time.Minute
 const REDIS_TTL_PUBLIC_GIFTS = time.Minute * 15
+const REDIS_TTL_CAPTURE = time.Hour
 
 const MonthInSeconds = 30 * 24 * 60 * 60
 
@@ -61,4 +129,51 @@ const (
 	SETTINGS_SHOW_PARTNER_EDITS
 	SETTINGS_SHOW_MY_DELETED
 	SETTINGS_SHOW_PARTNER_DELETED
+	SETTINGS_CAPTURE_PROTECTED
+)
+
+// действия экрана правил чата. Кроме них в действии может быть SETTINGS_SHOW_*,
+// тогда переключается переопределение этого флага
+const (
+	CHAT_RULE_SHOW   = -1
+	CHAT_RULE_LISTED = -2
+	CHAT_RULE_RESET  = -3
+)
+
+const (
+	// CHATS_MODE_DENYLIST - отслеживаются все чаты, кроме чатов из списка
+	CHATS_MODE_DENYLIST = "denylist"
+	// CHATS_MODE_ALLOWLIST - отслеживаются только чаты из списка
+	CHATS_MODE_ALLOWLIST = "allowlist"
+)
+
+const MAX_CHAT_RULES = 100
+
+// действия истории правок сообщения
+const (
+	// EDITED_TIMELINE_OPEN - отдельным сообщением, чтобы не затереть уведомление о правке
+	EDITED_TIMELINE_OPEN = iota
+	EDITED_TIMELINE_SHOW
+	EDITED_TIMELINE_FILES
+)
+
+const (
+	WEBHOOK_TOGGLE_DELETED = iota
+	WEBHOOK_TOGGLE_EDITED
+	WEBHOOK_ROTATE_SECRET
+	WEBHOOK_TEST
+	WEBHOOK_REMOVE
+)
+
+const MAX_WEBHOOK_URL_LEN = 512
+
+// варианты срока хранения сообщений в днях, 0 - хранить всегда
+var RETENTION_DAYS = []int{7, 30, 90, 365, 0}
+
+// варианты окна сводки уведомлений в минутах, 0 - уведомлять сразу
+var DIGEST_WINDOWS = []int{0, 15, 60, 24 * 60}
+
+const (
+	DIGEST_KIND_DELETED = "deleted"
+	DIGEST_KIND_EDITED  = "edited"
 )
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+
+	"ssuspy-bot/metrics"
+)
+
+type Kind string
+
+const (
+	KindMessage    Kind = "message"
+	KindEdited     Kind = "edited"
+	KindDeleted    Kind = "deleted"
+	KindConnection Kind = "connection"
+)
+
+// Event - то, что увидели обработчики бизнес обновлений. Заполняются только поля, относящиеся к Kind
+type Event struct {
+	Kind  Kind
+	BotID int64
+	// UserID - владелец бизнес аккаунта
+	UserID int64
+	Date   time.Time
+
+	ChatID   int64
+	ChatName string
+	// Message - новое сообщение или сообщение после правки
+	Message    *telego.Message
+	MessageIDs []int
+	// Changes - результат format.EditedDiff, пусто если старой версии нет в базе
+	Changes []string
+
+	ConnectionEnabled bool
+}
+
+// Filter - пустое поле не ограничивает
+type Filter struct {
+	BotIDs  []int64
+	UserIDs []int64
+	Kinds   []Kind
+}
+
+func (f *Filter) match(event *Event) bool {
+	return (len(f.BotIDs) == 0 || slices.Contains(f.BotIDs, event.BotID)) &&
+		(len(f.UserIDs) == 0 || slices.Contains(f.UserIDs, event.UserID)) &&
+		(len(f.Kinds) == 0 || slices.Contains(f.Kinds, event.Kind))
+}
+
+type Subscription struct {
+	C <-chan Event
+
+	ch     chan Event
+	filter Filter
+	bus    *Bus
+	once   sync.Once
+}
+
+// Close отписывает и закрывает C, вызывать можно несколько раз
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.bus.mutex.Lock()
+		delete(s.bus.subscriptions, s)
+		s.bus.mutex.Unlock()
+
+		close(s.ch)
+		metrics.EventSubscribers.Dec()
+	})
+}
+
+// Bus - шина событий внутри процесса. Publish не блокирует обработчики: если подписчик не успевает
+// читать, событие для него выбрасывается
+type Bus struct {
+	mutex         sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+}
+
+func NewBus() *Bus {
+	return &Bus{
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *Bus) Subscribe(filter Filter, buffer int) *Subscription {
+	ch := make(chan Event, buffer)
+	sub := &Subscription{
+		C:      ch,
+		ch:     ch,
+		filter: filter,
+		bus:    b,
+	}
+
+	b.mutex.Lock()
+	b.subscriptions[sub] = struct{}{}
+	b.mutex.Unlock()
+
+	metrics.EventSubscribers.Inc()
+	return sub
+}
+
+func (b *Bus) Publish(event Event) {
+	if event.Date.IsZero() {
+		event.Date = time.Now()
+	}
+	metrics.EventsPublishedTotal.WithLabelValues(string(event.Kind)).Inc()
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for sub := range b.subscriptions {
+		if !sub.filter.match(&event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			metrics.EventsDroppedTotal.Inc()
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package grpc_server
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"ssuspy-bot/events"
+	proto "ssuspy-bot/pb"
+)
+
+const (
+	eventsBuffer = 256
+	// как часто подписка проверяет, не появились ли новые реплики
+	eventsPeersInterval = 10 * time.Second
+)
+
+var eventKinds = map[proto.EventKind]events.Kind{
+	proto.EventKind_EVENT_KIND_MESSAGE:    events.KindMessage,
+	proto.EventKind_EVENT_KIND_EDITED:     events.KindEdited,
+	proto.EventKind_EVENT_KIND_DELETED:    events.KindDeleted,
+	proto.EventKind_EVENT_KIND_CONNECTION: events.KindConnection,
+}
+
+// SubscribeEvents отдает события ботов этой реплики, а если запрос пришел не от другой реплики -
+// еще и события со всех остальных, чтобы подписчику было неважно, где запущен бот
+func (s *BotServer) SubscribeEvents(req *proto.SubscribeEventsRequest, stream grpc.ServerStreamingServer[proto.Event]) error {
+	ctx := stream.Context()
+
+	filter := events.Filter{
+		BotIDs:  req.BotIds,
+		UserIDs: req.UserIds,
+	}
+	for _, kind := range req.Kinds {
+		eventKind, ok := eventKinds[kind]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "unknown event kind %s", kind)
+		}
+		filter.Kinds = append(filter.Kinds, eventKind)
+	}
+
+	sub := s.bus.Subscribe(filter, eventsBuffer)
+	defer sub.Close()
+
+	relayed := make(chan *proto.Event, eventsBuffer)
+	if !isForwarded(ctx) {
+		go s.relayPeerEvents(ctx, req, relayed)
+	}
+
+	for {
+		var event *proto.Event
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case busEvent, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			event = eventToProto(&busEvent)
+		case event = <-relayed:
+		}
+
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
+
+// relayPeerEvents подписывается на события остальных реплик и пересылает их в out,
+// отвалившиеся подписки переоткрываются на следующей проверке
+func (s *BotServer) relayPeerEvents(ctx context.Context, req *proto.SubscribeEventsRequest, out chan<- *proto.Event) {
+	var (
+		active = make(map[string]bool)
+		mutex  sync.Mutex
+	)
+
+	ticker := time.NewTicker(eventsPeersInterval)
+	defer ticker.Stop()
+
+	for {
+		peers, err := s.coordinator.Peers(ctx)
+		if err != nil {
+			log.Warn().Err(err).Msg("failed to get replicas for event subscription")
+		}
+
+		for replicaID, peer := range peers {
+			mutex.Lock()
+			if active[replicaID] {
+				mutex.Unlock()
+				continue
+			}
+			active[replicaID] = true
+			mutex.Unlock()
+
+			go func() {
+				defer func() {
+					mutex.Lock()
+					delete(active, replicaID)
+					mutex.Unlock()
+				}()
+
+				peerStream, err := peer.SubscribeEvents(forwardContext(ctx), req)
+				if err != nil {
+					log.Warn().Err(err).Str("replicaID", replicaID).Msg("failed to subscribe to replica events")
+					return
+				}
+				for {
+					event, err := peerStream.Recv()
+					if err != nil {
+						if ctx.Err() == nil {
+							log.Warn().Err(err).Str("replicaID", replicaID).Msg("replica event stream closed")
+						}
+						return
+					}
+
+					select {
+					case out <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func eventToProto(event *events.Event) *proto.Event {
+	result := &proto.Event{
+		BotId:             event.BotID,
+		UserId:            event.UserID,
+		Date:              event.Date.Unix(),
+		ChatId:            event.ChatID,
+		ChatName:          event.ChatName,
+		Changes:           event.Changes,
+		ConnectionEnabled: event.ConnectionEnabled,
+	}
+	for kind, eventKind := range eventKinds {
+		if eventKind == event.Kind {
+			result.Kind = kind
+		}
+	}
+	for _, messageID := range event.MessageIDs {
+		result.MessageIds = append(result.MessageIds, int64(messageID))
+	}
+
+	if event.Message != nil {
+		messageJSON, err := json.Marshal(event.Message)
+		if err != nil {
+			log.Warn().Err(err).Int64("botID", event.BotID).Msg("failed to marshal event message")
+		} else {
+			result.MessageJson = string(messageJSON)
+		}
+	}
+
+	return result
+}
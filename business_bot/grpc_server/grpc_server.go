@@ -2,43 +2,74 @@ package grpc_server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"ssuspy-bot/events"
 	proto "ssuspy-bot/pb"
 	"ssuspy-bot/repository"
+	"ssuspy-bot/shard"
 	"ssuspy-bot/telegram/manager"
 )
 
+// запрос уже переслан другой репликой, дальше не пересылаем, даже если взгляды на владельца разошлись
+const forwardedMetadataKey = "x-shard-forwarded"
+
 type BotServer struct {
 	proto.UnimplementedBotServer
-	manager *manager.BotManager
-	repo    *repository.MongoRepository
+	manager     *manager.BotManager
+	repo        *repository.MongoRepository
+	coordinator *shard.Coordinator
+	bus         *events.Bus
 }
 
-func NewBotServer(manager *manager.BotManager, repo *repository.MongoRepository) *BotServer {
+func NewBotServer(manager *manager.BotManager, repo *repository.MongoRepository, coordinator *shard.Coordinator, bus *events.Bus) *BotServer {
 	return &BotServer{
-		manager: manager,
-		repo:    repo,
+		manager:     manager,
+		repo:        repo,
+		coordinator: coordinator,
+		bus:         bus,
 	}
 }
+
+func isForwarded(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	return ok && len(md.Get(forwardedMetadataKey)) > 0
+}
+
+func forwardContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, forwardedMetadataKey, "1")
+}
+
 func (s *BotServer) AddBot(ctx context.Context, req *proto.AddBotRequest) (*proto.AddBotReply, error) {
 	if req.Id == 0 {
 		return nil, status.Error(codes.InvalidArgument, "botID is required")
 	}
 
-	botData, err := s.repo.BotByID(ctx, req.Id)
-	if err != nil {
+	if !isForwarded(ctx) {
+		peer, err := s.coordinator.Owner(ctx, req.Id)
+		if err != nil {
+			log.Error().Err(err).Int64("botID", req.Id).Msg("failed to find bot owner replica")
+			return nil, status.Error(codes.Unavailable, "failed to find bot owner replica")
+		}
+		if peer != nil {
+			return peer.AddBot(forwardContext(ctx), req)
+		}
+	}
+
+	if _, err := s.repo.BotByID(ctx, req.Id); err != nil {
 		log.Error().Err(err).Int64("botID", req.Id).Msg("failed to get bot from database")
 		return nil, status.Error(codes.NotFound, "bot not found in database")
 	}
 
-	err = s.manager.AddBot(ctx, req.Id, botData.SecretToken)
+	err := s.coordinator.StartOwned(ctx, req.Id)
 	if err != nil {
 		log.Error().Err(err).Int64("botID", req.Id).Msg("failed to add bot")
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to add bot: %v", err))
@@ -71,6 +102,17 @@ func (s *BotServer) RemoveBot(ctx context.Context, req *proto.RemoveBotRequest)
 		return nil, status.Error(codes.InvalidArgument, "botID is required")
 	}
 
+	if !isForwarded(ctx) {
+		peer, err := s.coordinator.Holder(ctx, req.Id)
+		if err != nil {
+			log.Error().Err(err).Int64("botID", req.Id).Msg("failed to find bot holder replica")
+			return nil, status.Error(codes.Unavailable, "failed to find bot holder replica")
+		}
+		if peer != nil {
+			return peer.RemoveBot(forwardContext(ctx), req)
+		}
+	}
+
 	botInstance, exists := s.manager.GetBot(req.Id)
 	var username string = "unknown"
 
@@ -81,7 +123,7 @@ func (s *BotServer) RemoveBot(ctx context.Context, req *proto.RemoveBotRequest)
 		}
 	}
 
-	err := s.manager.RemoveBot(req.Id)
+	err := s.coordinator.StopRemoved(ctx, req.Id)
 	if err != nil {
 		log.Error().Err(err).Int64("botID", req.Id).Msg("failed to remove bot")
 		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to remove bot: %v", err))
@@ -95,14 +137,37 @@ func (s *BotServer) RemoveBot(ctx context.Context, req *proto.RemoveBotRequest)
 	}, nil
 }
 
-func StartGRPCServer(port string, manager *manager.BotManager, repo *repository.MongoRepository) error {
+// ForwardUpdate принимает вебхук бота, который пришел на другую реплику. Дальше не пересылается
+func (s *BotServer) ForwardUpdate(ctx context.Context, req *proto.ForwardUpdateRequest) (*proto.ForwardUpdateReply, error) {
+	if req.BotId == 0 {
+		return nil, status.Error(codes.InvalidArgument, "botID is required")
+	}
+
+	err := s.manager.HandleForwardedUpdate(ctx, req.BotId, req.Update)
+	if errors.Is(err, manager.ErrBotNotHere) {
+		return nil, status.Error(codes.NotFound, "bot is not running on this replica")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to handle update: %v", err))
+	}
+	return &proto.ForwardUpdateReply{}, nil
+}
+
+func StartGRPCServer(
+	port string,
+	manager *manager.BotManager,
+	repo *repository.MongoRepository,
+	coordinator *shard.Coordinator,
+	bus *events.Bus,
+	opts ...grpc.ServerOption,
+) error {
 	lis, err := net.Listen("tcp", ":"+port)
 	if err != nil {
 		return err
 	}
 
-	grpcServer := grpc.NewServer()
-	botServer := NewBotServer(manager, repo)
+	grpcServer := grpc.NewServer(opts...)
+	botServer := NewBotServer(manager, repo, coordinator, bus)
 
 	proto.RegisterBotServer(grpcServer, botServer)
 
@@ -0,0 +1,226 @@
+package grpc_server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	proto "ssuspy-bot/pb"
+)
+
+func (s *BotServer) ListBots(ctx context.Context, req *proto.ListBotsRequest) (*proto.ListBotsReply, error) {
+	local := s.manager.ListBots()
+	statuses := s.localStatuses(ctx, local)
+
+	// переслали с другой реплики - отдаем только своих ботов, собирает ответ та реплика
+	if isForwarded(ctx) {
+		return &proto.ListBotsReply{Bots: statuses}, nil
+	}
+
+	botIDs, err := s.repo.AllBotIDs(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get bot ids")
+		return nil, status.Error(codes.Internal, "failed to get bots")
+	}
+
+	peers, err := s.coordinator.Peers(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("failed to get replicas")
+		return nil, status.Error(codes.Unavailable, "failed to get replicas")
+	}
+
+	byID := make(map[int64]*proto.BotStatus, len(botIDs))
+	for _, botStatus := range statuses {
+		byID[botStatus.Id] = botStatus
+	}
+	for replicaID, peer := range peers {
+		reply, err := peer.ListBots(forwardContext(ctx), req)
+		if err != nil {
+			log.Warn().Err(err).Str("replicaID", replicaID).Msg("failed to list bots of replica")
+			continue
+		}
+		for _, botStatus := range reply.Bots {
+			byID[botStatus.Id] = botStatus
+		}
+	}
+
+	bots := make([]*proto.BotStatus, 0, len(botIDs))
+	for _, botID := range botIDs {
+		botStatus, ok := byID[botID]
+		if !ok {
+			botStatus, err = s.stoppedStatus(ctx, botID)
+			if err != nil {
+				log.Warn().Err(err).Int64("botID", botID).Msg("failed to get bot status")
+				botStatus = &proto.BotStatus{Id: botID}
+			}
+		}
+		bots = append(bots, botStatus)
+	}
+	slices.SortFunc(bots, func(a, b *proto.BotStatus) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+
+	return &proto.ListBotsReply{Bots: bots}, nil
+}
+
+func (s *BotServer) GetBotStatus(ctx context.Context, req *proto.GetBotStatusRequest) (*proto.BotStatus, error) {
+	if req.Id == 0 {
+		return nil, status.Error(codes.InvalidArgument, "botID is required")
+	}
+
+	if !isForwarded(ctx) {
+		peer, err := s.coordinator.Holder(ctx, req.Id)
+		if err != nil {
+			log.Error().Err(err).Int64("botID", req.Id).Msg("failed to find bot holder replica")
+			return nil, status.Error(codes.Unavailable, "failed to find bot holder replica")
+		}
+		if peer != nil {
+			return peer.GetBotStatus(forwardContext(ctx), req)
+		}
+	}
+
+	if _, running := s.manager.GetBot(req.Id); running {
+		return s.localStatuses(ctx, []int64{req.Id})[0], nil
+	}
+
+	botStatus, err := s.stoppedStatus(ctx, req.Id)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, status.Error(codes.NotFound, "bot not found in database")
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("botID", req.Id).Msg("failed to get bot status")
+		return nil, status.Error(codes.Internal, "failed to get bot status")
+	}
+	return botStatus, nil
+}
+
+func (s *BotServer) RestartBot(ctx context.Context, req *proto.RestartBotRequest) (*proto.RestartBotReply, error) {
+	if req.Id == 0 {
+		return nil, status.Error(codes.InvalidArgument, "botID is required")
+	}
+
+	if !isForwarded(ctx) {
+		// перезапускаем там, где бот работает, а если нигде - на его владельце
+		peer, err := s.coordinator.Holder(ctx, req.Id)
+		if err == nil && peer == nil {
+			if _, running := s.manager.GetBot(req.Id); !running {
+				peer, err = s.coordinator.Owner(ctx, req.Id)
+			}
+		}
+		if err != nil {
+			log.Error().Err(err).Int64("botID", req.Id).Msg("failed to find bot replica")
+			return nil, status.Error(codes.Unavailable, "failed to find bot replica")
+		}
+		if peer != nil {
+			return peer.RestartBot(forwardContext(ctx), req)
+		}
+	}
+
+	botData, err := s.repo.BotByID(ctx, req.Id)
+	if err != nil {
+		log.Error().Err(err).Int64("botID", req.Id).Msg("failed to get bot from database")
+		return nil, status.Error(codes.NotFound, "bot not found in database")
+	}
+
+	if err := s.coordinator.Restart(ctx, req.Id); err != nil {
+		log.Error().Err(err).Int64("botID", req.Id).Msg("failed to restart bot")
+		return nil, status.Error(codes.Internal, fmt.Sprintf("failed to restart bot: %v", err))
+	}
+
+	log.Info().Int64("botID", req.Id).Str("username", botData.Username).Msg("bot restarted successfully")
+
+	return &proto.RestartBotReply{
+		Id:       req.Id,
+		Username: botData.Username,
+	}, nil
+}
+
+// localStatuses собирает статусы ботов, запущенных на этой реплике, запросы к API идут параллельно
+func (s *BotServer) localStatuses(ctx context.Context, botIDs []int64) []*proto.BotStatus {
+	statuses := make([]*proto.BotStatus, len(botIDs))
+
+	var wg sync.WaitGroup
+	for i, botID := range botIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			statuses[i] = s.localStatus(ctx, botID)
+		}()
+	}
+	wg.Wait()
+
+	return statuses
+}
+
+func (s *BotServer) localStatus(ctx context.Context, botID int64) *proto.BotStatus {
+	botStatus, err := s.stoppedStatus(ctx, botID)
+	if err != nil {
+		log.Warn().Err(err).Int64("botID", botID).Msg("failed to get bot info from database")
+		botStatus = &proto.BotStatus{Id: botID}
+	}
+
+	instance, exists := s.manager.GetBot(botID)
+	if !exists {
+		return botStatus
+	}
+
+	botStatus.Running = instance.Running()
+	botStatus.ReplicaId = s.coordinator.ReplicaID()
+	botStatus.StartedAt = instance.StartedAt.Unix()
+	if lastUpdate := instance.LastUpdate(); !lastUpdate.IsZero() {
+		botStatus.LastUpdateAt = lastUpdate.Unix()
+	}
+	if lastError := instance.LastError(); lastError != nil {
+		botStatus.LastError = lastError.Message
+		botStatus.LastErrorAt = lastError.At.Unix()
+	}
+
+	info, err := instance.Bot.GetWebhookInfo(ctx)
+	if err != nil {
+		log.Warn().Err(err).Int64("botID", botID).Msg("failed to get webhook info")
+		return botStatus
+	}
+	// в режиме long polling вебхука нет
+	if info.URL != "" {
+		botStatus.Webhook = &proto.WebhookInfo{
+			Url:                info.URL,
+			PendingUpdateCount: int32(info.PendingUpdateCount),
+			LastErrorDate:      info.LastErrorDate,
+			LastErrorMessage:   info.LastErrorMessage,
+		}
+	}
+	return botStatus
+}
+
+// stoppedStatus - статус бота только по данным из базы, плюс ошибка запуска, если бот не поднялся здесь
+func (s *BotServer) stoppedStatus(ctx context.Context, botID int64) (*proto.BotStatus, error) {
+	botData, err := s.repo.BotByID(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	total, connected, err := s.repo.BotUsersCount(ctx, botID)
+	if err != nil {
+		return nil, err
+	}
+
+	botStatus := &proto.BotStatus{
+		Id:                  botID,
+		Username:            botData.Username,
+		UsersCount:          total,
+		ConnectedUsersCount: connected,
+	}
+	if startError := s.coordinator.StartError(botID); startError != nil {
+		botStatus.LastError = startError.Message
+		botStatus.LastErrorAt = startError.At.Unix()
+	}
+	return botStatus, nil
+}
@@ -0,0 +1,232 @@
+package health
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/rs/zerolog/log"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/manager"
+)
+
+const (
+	// botsCacheTTL - сколько отдается прошлый ответ /bots, чтобы частые запросы не били по API Telegram
+	botsCacheTTL = 5 * time.Second
+	// botsConcurrency - сколько GetWebhookInfo идет одновременно
+	botsConcurrency = 8
+)
+
+type Handler struct {
+	service *repository.MongoRepository
+	rdb     *redis.Redis
+	manager *manager.BotManager
+	cfg     *config.HealthConfig
+
+	// botsMutex - один сбор статусов за раз, остальные запросы ждут его и берут кеш
+	botsMutex    sync.Mutex
+	botsCache    []botStatus
+	botsCachedAt time.Time
+}
+
+type check struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Latency string `json:"latency,omitempty"`
+	Depth   *int64 `json:"depth,omitempty"`
+}
+
+type report struct {
+	OK     bool             `json:"ok"`
+	Checks map[string]check `json:"checks"`
+}
+
+type webhookStatus struct {
+	URL                  string `json:"url,omitempty"`
+	PendingUpdateCount   int    `json:"pending_update_count"`
+	LastErrorDate        int64  `json:"last_error_date,omitempty"`
+	LastErrorMessage     string `json:"last_error_message,omitempty"`
+	LastSyncErrorDate    int64  `json:"last_synchronization_error_date,omitempty"`
+	MaxConnections       int    `json:"max_connections,omitempty"`
+	WebhookInfoFetchFail string `json:"webhook_info_error,omitempty"`
+}
+
+type botStatus struct {
+	ID         int64          `json:"id"`
+	Running    bool           `json:"running"`
+	StartedAt  time.Time      `json:"started_at"`
+	LastUpdate *time.Time     `json:"last_update,omitempty"`
+	Webhook    *webhookStatus `json:"webhook"`
+}
+
+// Register вешает /healthz, /readyz и /bots на общий mux с вебхуками и /metrics.
+// mux публичный, поэтому /bots есть только с токеном
+func Register(mux *http.ServeMux, service *repository.MongoRepository, rdb *redis.Redis, manager *manager.BotManager, cfg *config.HealthConfig) {
+	h := &Handler{
+		service: service,
+		rdb:     rdb,
+		manager: manager,
+		cfg:     cfg,
+	}
+
+	mux.HandleFunc("GET /healthz", h.handleHealth)
+	mux.HandleFunc("GET /readyz", h.handleReady)
+	if cfg.BotsToken != "" {
+		mux.HandleFunc("GET /bots", h.handleBots)
+	}
+}
+
+func (h *Handler) checks(ctx context.Context) map[string]check {
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	checks := make(map[string]check, 3)
+	timed := func(name string, fn func(context.Context) error) {
+		start := time.Now()
+		if err := fn(ctx); err != nil {
+			checks[name] = check{Error: err.Error(), Latency: time.Since(start).String()}
+			return
+		}
+		checks[name] = check{OK: true, Latency: time.Since(start).String()}
+	}
+
+	timed("mongo", h.service.Ping)
+	timed("redis", func(ctx context.Context) error {
+		return h.rdb.Ping(ctx).Err()
+	})
+
+	depth, err := h.rdb.QueueLength(ctx, consts.REDIS_QUEUE_FILES)
+	switch {
+	case err != nil:
+		checks["files_queue"] = check{Error: err.Error()}
+	case depth > h.cfg.MaxQueueDepth:
+		checks["files_queue"] = check{Error: "queue is too deep", Depth: &depth}
+	default:
+		checks["files_queue"] = check{OK: true, Depth: &depth}
+	}
+
+	return checks
+}
+
+// handleHealth - живость: процесс отвечает и видит mongo и redis. Глубина очереди
+// только для информации, из-за нее перезапускать контейнер бессмысленно
+func (h *Handler) handleHealth(w http.ResponseWriter, r *http.Request) {
+	checks := h.checks(r.Context())
+	writeReport(w, checks, checks["mongo"].OK && checks["redis"].OK)
+}
+
+// handleReady - готовность: плюс очередь файлов не переполнена
+func (h *Handler) handleReady(w http.ResponseWriter, r *http.Request) {
+	checks := h.checks(r.Context())
+
+	ok := true
+	for _, c := range checks {
+		ok = ok && c.OK
+	}
+	writeReport(w, checks, ok)
+}
+
+func writeReport(w http.ResponseWriter, checks map[string]check, ok bool) {
+	status := http.StatusOK
+	if !ok {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report{OK: ok, Checks: checks})
+}
+
+func (h *Handler) handleBots(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.cfg.BotsToken)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.botStatuses(r.Context()))
+}
+
+func (h *Handler) botStatuses(ctx context.Context) []botStatus {
+	h.botsMutex.Lock()
+	defer h.botsMutex.Unlock()
+
+	if time.Since(h.botsCachedAt) < botsCacheTTL {
+		return h.botsCache
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.cfg.Timeout)
+	defer cancel()
+
+	botIDs := h.manager.ListBots()
+	slices.Sort(botIDs)
+
+	statuses := make([]botStatus, len(botIDs))
+	sem := make(chan struct{}, botsConcurrency)
+	var wg sync.WaitGroup
+	for i, botID := range botIDs {
+		instance, exists := h.manager.GetBot(botID)
+		if !exists {
+			statuses[i] = botStatus{ID: botID}
+			continue
+		}
+
+		statuses[i] = botStatus{
+			ID:        botID,
+			Running:   instance.Running(),
+			StartedAt: instance.StartedAt,
+		}
+		if lastUpdate := instance.LastUpdate(); !lastUpdate.IsZero() {
+			statuses[i].LastUpdate = &lastUpdate
+		}
+
+		wg.Add(1)
+		go func(status *botStatus, bot *telego.Bot) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				status.Webhook = &webhookStatus{WebhookInfoFetchFail: ctx.Err().Error()}
+				return
+			}
+			defer func() { <-sem }()
+
+			status.Webhook = webhookInfo(ctx, bot)
+		}(&statuses[i], instance.Bot)
+	}
+	wg.Wait()
+
+	h.botsCache, h.botsCachedAt = statuses, time.Now()
+	return statuses
+}
+
+func webhookInfo(ctx context.Context, bot *telego.Bot) *webhookStatus {
+	info, err := bot.GetWebhookInfo(ctx)
+	if err != nil {
+		return &webhookStatus{WebhookInfoFetchFail: err.Error()}
+	}
+	return &webhookStatus{
+		URL:                info.URL,
+		PendingUpdateCount: info.PendingUpdateCount,
+		LastErrorDate:      info.LastErrorDate,
+		LastErrorMessage:   info.LastErrorMessage,
+		LastSyncErrorDate:  info.LastSynchronizationErrorDate,
+		MaxConnections:     info.MaxConnections,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Warn().Err(err).Msg("failed write health response")
+	}
+}
@@ -36,6 +36,157 @@ var (
 		},
 		[]string{"handler"},
 	)
+
+	RetentionDeletedMessages = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bot_retention_deleted_messages_total",
+			Help: "Total number of messages deleted by retention purge",
+		},
+	)
+
+	RetentionPurgedUsers = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bot_retention_purged_users_total",
+			Help: "Total number of users whose expired messages were purged",
+		},
+	)
+
+	RetentionErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bot_retention_errors_total",
+			Help: "Total number of errors during retention purge",
+		},
+	)
+
+	ShardReplicas = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "bot_shard_replicas",
+			Help: "Number of live business bot replicas",
+		},
+	)
+
+	ShardOwnedBots = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "bot_shard_owned_bots",
+			Help: "Number of bots running on this replica",
+		},
+	)
+
+	ShardRebalanceErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bot_shard_rebalance_errors_total",
+			Help: "Total number of errors during shard rebalance",
+		},
+	)
+
+	EventsPublishedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_events_published_total",
+			Help: "Total number of business events published to the event bus",
+		},
+		[]string{"kind"},
+	)
+
+	EventsDroppedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bot_events_dropped_total",
+			Help: "Total number of events dropped because a subscriber was too slow",
+		},
+	)
+
+	EventSubscribers = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "bot_event_subscribers",
+			Help: "Number of active event subscriptions",
+		},
+	)
+
+	FilesQueueDepth = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "bot_files_queue_depth",
+			Help: "Number of files jobs by state (pending, processing, retry, dead)",
+		},
+		[]string{"state"},
+	)
+
+	FilesJobRetriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_files_job_retries_total",
+			Help: "Total number of files jobs scheduled for retry by reason (error, timeout)",
+		},
+		[]string{"reason"},
+	)
+
+	FilesJobFailuresTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "bot_files_job_failures_total",
+			Help: "Total number of files jobs moved to the dead-letter list",
+		},
+	)
+
+	WebhookDeliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_webhook_deliveries_total",
+			Help: "Total number of webhook delivery attempts by result (delivered, retry, dead, dropped)",
+		},
+		[]string{"result"},
+	)
+
+	WebhookDeliveryDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "bot_webhook_delivery_duration_seconds",
+			Help:    "Duration of one webhook HTTP request",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
+
+	ArchivedFilesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_archived_files_total",
+			Help: "Total number of media archive operations by result (stored, duplicate, skipped, deleted)",
+		},
+		[]string{"result"},
+	)
+
+	ArchiveFallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_archive_fallbacks_total",
+			Help: "Total number of resends from the media archive after file_id failed by result (sent, missing, failed)",
+		},
+		[]string{"result"},
+	)
+
+	QuotaMessagesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_quota_messages_total",
+			Help: "Total number of messages affected by storage quotas by result (rejected, dropped)",
+		},
+		[]string{"result"},
+	)
+
+	DigestEventsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_digest_events_total",
+			Help: "Total number of notifications by digest stage (buffered, sent, retried, failed)",
+		},
+		[]string{"stage"},
+	)
+
+	CapturedFilesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "bot_captured_files_total",
+			Help: "Total number of protected media captured on arrival by result (queued, duplicate, sent, too_big, failed)",
+		},
+		[]string{"result"},
+	)
+
+	RetentionRunDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "bot_retention_run_duration_seconds",
+			Help:    "Duration of one retention purge run",
+			Buckets: prometheus.DefBuckets,
+		},
+	)
 )
 
 func init() {
@@ -43,4 +194,24 @@ func init() {
 	prometheus.MustRegister(ErrorsTotal)
 	prometheus.MustRegister(PanicsTotal)
 	prometheus.MustRegister(ProcessingTime)
+	prometheus.MustRegister(RetentionDeletedMessages)
+	prometheus.MustRegister(RetentionPurgedUsers)
+	prometheus.MustRegister(RetentionErrorsTotal)
+	prometheus.MustRegister(RetentionRunDuration)
+	prometheus.MustRegister(ShardReplicas)
+	prometheus.MustRegister(ShardOwnedBots)
+	prometheus.MustRegister(ShardRebalanceErrorsTotal)
+	prometheus.MustRegister(EventsPublishedTotal)
+	prometheus.MustRegister(EventsDroppedTotal)
+	prometheus.MustRegister(EventSubscribers)
+	prometheus.MustRegister(FilesQueueDepth)
+	prometheus.MustRegister(FilesJobRetriesTotal)
+	prometheus.MustRegister(FilesJobFailuresTotal)
+	prometheus.MustRegister(WebhookDeliveriesTotal)
+	prometheus.MustRegister(WebhookDeliveryDuration)
+	prometheus.MustRegister(ArchivedFilesTotal)
+	prometheus.MustRegister(ArchiveFallbacksTotal)
+	prometheus.MustRegister(QuotaMessagesTotal)
+	prometheus.MustRegister(DigestEventsTotal)
+	prometheus.MustRegister(CapturedFilesTotal)
 }
@@ -0,0 +1,75 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-common/crypto"
+)
+
+// DoBindEncryptedSecretsMigrate перешифровывает токены ботов и секреты вебхуков, зашифрованные
+// без привязки к записи, с aad их бота или пользователя
+func DoBindEncryptedSecretsMigrate(ctx context.Context, botsCollection *mongo.Collection, webhooksCollection *mongo.Collection, box *crypto.Box) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	bots, err := rebindSecrets(ctx, botsCollection, "secret_token", box, crypto.BotAAD)
+	if err != nil {
+		return fmt.Errorf("bots: %w", err)
+	}
+	webhooks, err := rebindSecrets(ctx, webhooksCollection, "secret", box, crypto.UserAAD)
+	if err != nil {
+		return fmt.Errorf("webhooks: %w", err)
+	}
+
+	log.Info().Int("bots", bots).Int("webhooks", webhooks).Msg("migration done")
+	return nil
+}
+
+func rebindSecrets(ctx context.Context, collection *mongo.Collection, field string, box *crypto.Box, aad func(int64) string) (int, error) {
+	cursor, err := collection.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, fmt.Errorf("error find records: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var count int
+	for cursor.Next(ctx) {
+		var record bson.M
+		if err := cursor.Decode(&record); err != nil {
+			return count, fmt.Errorf("error decode record: %w", err)
+		}
+		id, _ := record["_id"].(int64)
+		secret, _ := record[field].(string)
+		if !crypto.IsEncrypted(secret) {
+			continue
+		}
+
+		// уже привязан: мигрировали частично в прошлый запуск или запись новая
+		if _, err := box.Decrypt(secret, aad(id)); err == nil {
+			continue
+		}
+
+		plaintext, err := box.Decrypt(secret, "")
+		if err != nil {
+			return count, fmt.Errorf("record %d: %w", id, err)
+		}
+		bound, err := box.Encrypt(plaintext, aad(id))
+		if err != nil {
+			return count, fmt.Errorf("record %d: failed encrypt: %w", id, err)
+		}
+
+		if _, err := collection.UpdateByID(ctx, id, bson.M{
+			"$set": bson.M{field: bound},
+		}); err != nil {
+			return count, fmt.Errorf("record %d: failed update: %w", id, err)
+		}
+		count++
+	}
+	return count, cursor.Err()
+}
@@ -0,0 +1,64 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DoChatResolveUsersMigrate отмечает в chats_resolve пользователей, у которых уже есть сообщения чата.
+// Новые чаты отмечает UpdateChatName, миграция нужна только для сохраненных раньше
+func DoChatResolveUsersMigrate(ctx context.Context, messagesCollection *mongo.Collection, botUsersCollection *mongo.Collection, chatResolveCollection *mongo.Collection) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Minute)
+	defer cancel()
+
+	log.Info().Msg("starting chat resolve users migration")
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "chat", Value: "$message.chat.id"},
+				{Key: "connection", Value: "$message.business_connection_id"},
+			}},
+		}}},
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: botUsersCollection.Name()},
+			{Key: "localField", Value: "_id.connection"},
+			{Key: "foreignField", Value: "business_connections.id"},
+			{Key: "as", Value: "bot_user"},
+		}}},
+		bson.D{{Key: "$unwind", Value: "$bot_user"}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$_id.chat"},
+			{Key: "user_ids", Value: bson.D{{Key: "$addToSet", Value: "$bot_user.user_id"}}},
+		}}},
+		bson.D{{Key: "$merge", Value: bson.D{
+			{Key: "into", Value: chatResolveCollection.Name()},
+			{Key: "on", Value: "_id"},
+			{Key: "whenMatched", Value: mongo.Pipeline{
+				bson.D{{Key: "$set", Value: bson.D{
+					{Key: "user_ids", Value: bson.D{{Key: "$setUnion", Value: bson.A{
+						bson.D{{Key: "$ifNull", Value: bson.A{"$user_ids", bson.A{}}}},
+						"$$new.user_ids",
+					}}}},
+				}}},
+			}},
+			// чат без имени все равно не показать в списке
+			{Key: "whenNotMatched", Value: "discard"},
+		}}},
+	}
+
+	cursor, err := messagesCollection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("fail merge chat users: %w", err)
+	}
+	cursor.Close(ctx)
+
+	log.Info().Msg("migration done")
+	return nil
+}
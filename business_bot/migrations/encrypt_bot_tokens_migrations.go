@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-common/crypto"
+)
+
+type oldBot struct {
+	ID          int64  `bson:"_id"`
+	SecretToken string `bson:"secret_token"`
+}
+
+// DoEncryptBotTokensMigrate шифрует токены, которые лежат в открытом виде, и проставляет token_hash
+func DoEncryptBotTokensMigrate(ctx context.Context, botsCollection *mongo.Collection, box *crypto.Box) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	cursor, err := botsCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("error find bots: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var count int
+	for cursor.Next(ctx) {
+		var bot oldBot
+		if err := cursor.Decode(&bot); err != nil {
+			return fmt.Errorf("error decode bot: %w", err)
+		}
+
+		token := bot.SecretToken
+		if crypto.IsEncrypted(token) {
+			// мигрировали частично в прошлый запуск, проверяем что ключ тот же
+			if token, err = box.Decrypt(token, crypto.BotAAD(bot.ID)); err != nil {
+				return fmt.Errorf("bot %d: %w", bot.ID, err)
+			}
+		}
+
+		encrypted, err := box.Encrypt(token, crypto.BotAAD(bot.ID))
+		if err != nil {
+			return fmt.Errorf("bot %d: failed encrypt token: %w", bot.ID, err)
+		}
+
+		if _, err := botsCollection.UpdateByID(ctx, bot.ID, bson.M{
+			"$set": bson.M{
+				"secret_token": encrypted,
+				"token_hash":   box.Hash(token),
+			},
+		}); err != nil {
+			return fmt.Errorf("bot %d: failed update: %w", bot.ID, err)
+		}
+		count++
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+
+	log.Info().Int("count", count).Msg("migration done")
+	return nil
+}
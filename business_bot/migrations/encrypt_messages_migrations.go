@@ -0,0 +1,111 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	custom_registry "ssuspy-bot/repository/bson_custon_registry"
+	"ssuspy-common/crypto"
+)
+
+type connectionOwner struct {
+	UserID      int64 `bson:"user_id"`
+	Connections []struct {
+		ID string `bson:"id"`
+	} `bson:"business_connections"`
+}
+
+// DoEncryptMessagesMigrate шифрует уже сохраненные сообщения ключом владельца подключения.
+// Сообщения подключений, владелец которых неизвестен, остаются как есть
+func DoEncryptMessagesMigrate(
+	ctx context.Context,
+	messagesCollection *mongo.Collection,
+	botUsersCollection *mongo.Collection,
+	registry *custom_registry.CustomRegistry,
+	keyring *crypto.Keyring,
+) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	cursor, err := botUsersCollection.Find(ctx, bson.M{})
+	if err != nil {
+		return fmt.Errorf("error find bot users: %w", err)
+	}
+	var owners []connectionOwner
+	if err := cursor.All(ctx, &owners); err != nil {
+		return fmt.Errorf("error decode bot users: %w", err)
+	}
+
+	var count int
+	for _, owner := range owners {
+		connectionIDs := make([]string, 0, len(owner.Connections))
+		for _, connection := range owner.Connections {
+			connectionIDs = append(connectionIDs, connection.ID)
+		}
+		if len(connectionIDs) == 0 {
+			continue
+		}
+
+		box, err := keyring.ForUser(owner.UserID)
+		if err != nil {
+			return err
+		}
+
+		cursor, err := messagesCollection.Find(ctx, bson.M{
+			"message.business_connection_id": bson.M{"$in": connectionIDs},
+		})
+		if err != nil {
+			return fmt.Errorf("error find messages: %w", err)
+		}
+
+		for cursor.Next(ctx) {
+			var doc struct {
+				ID      int64    `bson:"_id"`
+				Message bson.Raw `bson:"message"`
+			}
+			if err := cursor.Decode(&doc); err != nil {
+				log.Warn().Err(err).Msg("err decode message doc")
+				continue
+			}
+
+			var msg telego.Message
+			if err := registry.LoadMessage(doc.Message, &msg); err != nil {
+				log.Warn().Err(err).Int64("internalID", doc.ID).Msg("fail load message via registry")
+				continue
+			}
+
+			// SealMessage пропускает уже зашифрованные поля, поэтому прерванную миграцию можно перезапустить
+			rawBytes, err := registry.SaveMessage(&msg, box)
+			if err != nil {
+				log.Warn().Err(err).Int64("internalID", doc.ID).Msg("fail seal message")
+				continue
+			}
+
+			if _, err := messagesCollection.UpdateByID(ctx, doc.ID, bson.M{
+				"$set": bson.M{"message": bson.Raw(rawBytes)},
+			}); err != nil {
+				cursor.Close(ctx)
+				return fmt.Errorf("fail update message %d: %w", doc.ID, err)
+			}
+
+			count++
+			if count%500 == 0 {
+				log.Info().Int("count", count).Msg("encrypted messages")
+			}
+		}
+		err = cursor.Err()
+		cursor.Close(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info().Int("count", count).Msg("migration done")
+	return nil
+}
@@ -46,7 +46,7 @@ func DoJsonToBsonMessagesMigrate(ctx context.Context, db *mongo.Database, regist
 			continue
 		}
 
-		rawBytes, err := registry.SaveMessage(&msg)
+		rawBytes, err := registry.SaveMessage(&msg, nil)
 		if err != nil {
 			log.Warn().Err(err).Int64("internalID", old.ID).Msg("fail save message via registry")
 			continue
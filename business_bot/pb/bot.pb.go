@@ -21,6 +21,61 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type EventKind int32
+
+const (
+	EventKind_EVENT_KIND_UNSPECIFIED EventKind = 0
+	EventKind_EVENT_KIND_MESSAGE     EventKind = 1
+	EventKind_EVENT_KIND_EDITED      EventKind = 2
+	EventKind_EVENT_KIND_DELETED     EventKind = 3
+	EventKind_EVENT_KIND_CONNECTION  EventKind = 4
+)
+
+// Enum value maps for EventKind.
+var (
+	EventKind_name = map[int32]string{
+		0: "EVENT_KIND_UNSPECIFIED",
+		1: "EVENT_KIND_MESSAGE",
+		2: "EVENT_KIND_EDITED",
+		3: "EVENT_KIND_DELETED",
+		4: "EVENT_KIND_CONNECTION",
+	}
+	EventKind_value = map[string]int32{
+		"EVENT_KIND_UNSPECIFIED": 0,
+		"EVENT_KIND_MESSAGE":     1,
+		"EVENT_KIND_EDITED":      2,
+		"EVENT_KIND_DELETED":     3,
+		"EVENT_KIND_CONNECTION":  4,
+	}
+)
+
+func (x EventKind) Enum() *EventKind {
+	p := new(EventKind)
+	*p = x
+	return p
+}
+
+func (x EventKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (EventKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_bot_proto_enumTypes[0].Descriptor()
+}
+
+func (EventKind) Type() protoreflect.EnumType {
+	return &file_bot_proto_enumTypes[0]
+}
+
+func (x EventKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use EventKind.Descriptor instead.
+func (EventKind) EnumDescriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{0}
+}
+
 type AddBotRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
@@ -213,6 +268,682 @@ func (x *RemoveBotReply) GetUsername() string {
 	return ""
 }
 
+type ListBotsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBotsRequest) Reset() {
+	*x = ListBotsRequest{}
+	mi := &file_bot_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBotsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBotsRequest) ProtoMessage() {}
+
+func (x *ListBotsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBotsRequest.ProtoReflect.Descriptor instead.
+func (*ListBotsRequest) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{4}
+}
+
+type ListBotsReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bots          []*BotStatus           `protobuf:"bytes,1,rep,name=bots,proto3" json:"bots,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListBotsReply) Reset() {
+	*x = ListBotsReply{}
+	mi := &file_bot_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListBotsReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListBotsReply) ProtoMessage() {}
+
+func (x *ListBotsReply) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListBotsReply.ProtoReflect.Descriptor instead.
+func (*ListBotsReply) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ListBotsReply) GetBots() []*BotStatus {
+	if x != nil {
+		return x.Bots
+	}
+	return nil
+}
+
+type GetBotStatusRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetBotStatusRequest) Reset() {
+	*x = GetBotStatusRequest{}
+	mi := &file_bot_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetBotStatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetBotStatusRequest) ProtoMessage() {}
+
+func (x *GetBotStatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetBotStatusRequest.ProtoReflect.Descriptor instead.
+func (*GetBotStatusRequest) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GetBotStatusRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type WebhookInfo struct {
+	state              protoimpl.MessageState `protogen:"open.v1"`
+	Url                string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	PendingUpdateCount int32                  `protobuf:"varint,2,opt,name=pending_update_count,json=pendingUpdateCount,proto3" json:"pending_update_count,omitempty"`
+	LastErrorDate      int64                  `protobuf:"varint,3,opt,name=last_error_date,json=lastErrorDate,proto3" json:"last_error_date,omitempty"`
+	LastErrorMessage   string                 `protobuf:"bytes,4,opt,name=last_error_message,json=lastErrorMessage,proto3" json:"last_error_message,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *WebhookInfo) Reset() {
+	*x = WebhookInfo{}
+	mi := &file_bot_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WebhookInfo) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WebhookInfo) ProtoMessage() {}
+
+func (x *WebhookInfo) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WebhookInfo.ProtoReflect.Descriptor instead.
+func (*WebhookInfo) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WebhookInfo) GetUrl() string {
+	if x != nil {
+		return x.Url
+	}
+	return ""
+}
+
+func (x *WebhookInfo) GetPendingUpdateCount() int32 {
+	if x != nil {
+		return x.PendingUpdateCount
+	}
+	return 0
+}
+
+func (x *WebhookInfo) GetLastErrorDate() int64 {
+	if x != nil {
+		return x.LastErrorDate
+	}
+	return 0
+}
+
+func (x *WebhookInfo) GetLastErrorMessage() string {
+	if x != nil {
+		return x.LastErrorMessage
+	}
+	return ""
+}
+
+type BotStatus struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	Id                  int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username            string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Running             bool                   `protobuf:"varint,3,opt,name=running,proto3" json:"running,omitempty"`
+	ReplicaId           string                 `protobuf:"bytes,4,opt,name=replica_id,json=replicaId,proto3" json:"replica_id,omitempty"`
+	StartedAt           int64                  `protobuf:"varint,5,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	LastUpdateAt        int64                  `protobuf:"varint,6,opt,name=last_update_at,json=lastUpdateAt,proto3" json:"last_update_at,omitempty"`
+	LastError           string                 `protobuf:"bytes,7,opt,name=last_error,json=lastError,proto3" json:"last_error,omitempty"`
+	LastErrorAt         int64                  `protobuf:"varint,8,opt,name=last_error_at,json=lastErrorAt,proto3" json:"last_error_at,omitempty"`
+	Webhook             *WebhookInfo           `protobuf:"bytes,9,opt,name=webhook,proto3" json:"webhook,omitempty"`
+	UsersCount          int64                  `protobuf:"varint,10,opt,name=users_count,json=usersCount,proto3" json:"users_count,omitempty"`
+	ConnectedUsersCount int64                  `protobuf:"varint,11,opt,name=connected_users_count,json=connectedUsersCount,proto3" json:"connected_users_count,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *BotStatus) Reset() {
+	*x = BotStatus{}
+	mi := &file_bot_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BotStatus) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BotStatus) ProtoMessage() {}
+
+func (x *BotStatus) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BotStatus.ProtoReflect.Descriptor instead.
+func (*BotStatus) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *BotStatus) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *BotStatus) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *BotStatus) GetRunning() bool {
+	if x != nil {
+		return x.Running
+	}
+	return false
+}
+
+func (x *BotStatus) GetReplicaId() string {
+	if x != nil {
+		return x.ReplicaId
+	}
+	return ""
+}
+
+func (x *BotStatus) GetStartedAt() int64 {
+	if x != nil {
+		return x.StartedAt
+	}
+	return 0
+}
+
+func (x *BotStatus) GetLastUpdateAt() int64 {
+	if x != nil {
+		return x.LastUpdateAt
+	}
+	return 0
+}
+
+func (x *BotStatus) GetLastError() string {
+	if x != nil {
+		return x.LastError
+	}
+	return ""
+}
+
+func (x *BotStatus) GetLastErrorAt() int64 {
+	if x != nil {
+		return x.LastErrorAt
+	}
+	return 0
+}
+
+func (x *BotStatus) GetWebhook() *WebhookInfo {
+	if x != nil {
+		return x.Webhook
+	}
+	return nil
+}
+
+func (x *BotStatus) GetUsersCount() int64 {
+	if x != nil {
+		return x.UsersCount
+	}
+	return 0
+}
+
+func (x *BotStatus) GetConnectedUsersCount() int64 {
+	if x != nil {
+		return x.ConnectedUsersCount
+	}
+	return 0
+}
+
+type RestartBotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartBotRequest) Reset() {
+	*x = RestartBotRequest{}
+	mi := &file_bot_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartBotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartBotRequest) ProtoMessage() {}
+
+func (x *RestartBotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartBotRequest.ProtoReflect.Descriptor instead.
+func (*RestartBotRequest) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RestartBotRequest) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+type RestartBotReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RestartBotReply) Reset() {
+	*x = RestartBotReply{}
+	mi := &file_bot_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RestartBotReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RestartBotReply) ProtoMessage() {}
+
+func (x *RestartBotReply) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RestartBotReply.ProtoReflect.Descriptor instead.
+func (*RestartBotReply) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *RestartBotReply) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *RestartBotReply) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+type SubscribeEventsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BotIds        []int64                `protobuf:"varint,1,rep,packed,name=bot_ids,json=botIds,proto3" json:"bot_ids,omitempty"`
+	UserIds       []int64                `protobuf:"varint,2,rep,packed,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	Kinds         []EventKind            `protobuf:"varint,3,rep,packed,name=kinds,proto3,enum=pb.EventKind" json:"kinds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscribeEventsRequest) Reset() {
+	*x = SubscribeEventsRequest{}
+	mi := &file_bot_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscribeEventsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscribeEventsRequest) ProtoMessage() {}
+
+func (x *SubscribeEventsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscribeEventsRequest.ProtoReflect.Descriptor instead.
+func (*SubscribeEventsRequest) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SubscribeEventsRequest) GetBotIds() []int64 {
+	if x != nil {
+		return x.BotIds
+	}
+	return nil
+}
+
+func (x *SubscribeEventsRequest) GetUserIds() []int64 {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+func (x *SubscribeEventsRequest) GetKinds() []EventKind {
+	if x != nil {
+		return x.Kinds
+	}
+	return nil
+}
+
+type Event struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Kind              EventKind              `protobuf:"varint,1,opt,name=kind,proto3,enum=pb.EventKind" json:"kind,omitempty"`
+	BotId             int64                  `protobuf:"varint,2,opt,name=bot_id,json=botId,proto3" json:"bot_id,omitempty"`
+	UserId            int64                  `protobuf:"varint,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Date              int64                  `protobuf:"varint,4,opt,name=date,proto3" json:"date,omitempty"`
+	ChatId            int64                  `protobuf:"varint,5,opt,name=chat_id,json=chatId,proto3" json:"chat_id,omitempty"`
+	ChatName          string                 `protobuf:"bytes,6,opt,name=chat_name,json=chatName,proto3" json:"chat_name,omitempty"`
+	MessageJson       string                 `protobuf:"bytes,7,opt,name=message_json,json=messageJson,proto3" json:"message_json,omitempty"`
+	MessageIds        []int64                `protobuf:"varint,8,rep,packed,name=message_ids,json=messageIds,proto3" json:"message_ids,omitempty"`
+	Changes           []string               `protobuf:"bytes,9,rep,name=changes,proto3" json:"changes,omitempty"`
+	ConnectionEnabled bool                   `protobuf:"varint,10,opt,name=connection_enabled,json=connectionEnabled,proto3" json:"connection_enabled,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_bot_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Event) GetKind() EventKind {
+	if x != nil {
+		return x.Kind
+	}
+	return EventKind_EVENT_KIND_UNSPECIFIED
+}
+
+func (x *Event) GetBotId() int64 {
+	if x != nil {
+		return x.BotId
+	}
+	return 0
+}
+
+func (x *Event) GetUserId() int64 {
+	if x != nil {
+		return x.UserId
+	}
+	return 0
+}
+
+func (x *Event) GetDate() int64 {
+	if x != nil {
+		return x.Date
+	}
+	return 0
+}
+
+func (x *Event) GetChatId() int64 {
+	if x != nil {
+		return x.ChatId
+	}
+	return 0
+}
+
+func (x *Event) GetChatName() string {
+	if x != nil {
+		return x.ChatName
+	}
+	return ""
+}
+
+func (x *Event) GetMessageJson() string {
+	if x != nil {
+		return x.MessageJson
+	}
+	return ""
+}
+
+func (x *Event) GetMessageIds() []int64 {
+	if x != nil {
+		return x.MessageIds
+	}
+	return nil
+}
+
+func (x *Event) GetChanges() []string {
+	if x != nil {
+		return x.Changes
+	}
+	return nil
+}
+
+func (x *Event) GetConnectionEnabled() bool {
+	if x != nil {
+		return x.ConnectionEnabled
+	}
+	return false
+}
+
+type ForwardUpdateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BotId         int64                  `protobuf:"varint,1,opt,name=bot_id,json=botId,proto3" json:"bot_id,omitempty"`
+	Update        []byte                 `protobuf:"bytes,2,opt,name=update,proto3" json:"update,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForwardUpdateRequest) Reset() {
+	*x = ForwardUpdateRequest{}
+	mi := &file_bot_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForwardUpdateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForwardUpdateRequest) ProtoMessage() {}
+
+func (x *ForwardUpdateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForwardUpdateRequest.ProtoReflect.Descriptor instead.
+func (*ForwardUpdateRequest) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ForwardUpdateRequest) GetBotId() int64 {
+	if x != nil {
+		return x.BotId
+	}
+	return 0
+}
+
+func (x *ForwardUpdateRequest) GetUpdate() []byte {
+	if x != nil {
+		return x.Update
+	}
+	return nil
+}
+
+type ForwardUpdateReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ForwardUpdateReply) Reset() {
+	*x = ForwardUpdateReply{}
+	mi := &file_bot_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ForwardUpdateReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ForwardUpdateReply) ProtoMessage() {}
+
+func (x *ForwardUpdateReply) ProtoReflect() protoreflect.Message {
+	mi := &file_bot_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ForwardUpdateReply.ProtoReflect.Descriptor instead.
+func (*ForwardUpdateReply) Descriptor() ([]byte, []int) {
+	return file_bot_proto_rawDescGZIP(), []int{14}
+}
+
 var File_bot_proto protoreflect.FileDescriptor
 
 const file_bot_proto_rawDesc = "" +
@@ -227,10 +958,75 @@ const file_bot_proto_rawDesc = "" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\"<\n" +
 	"\x0eRemoveBotReply\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
-	"\busername\x18\x02 \x01(\tR\busername2j\n" +
+	"\busername\x18\x02 \x01(\tR\busername\"\x11\n" +
+	"\x0fListBotsRequest\"2\n" +
+	"\rListBotsReply\x12!\n" +
+	"\x04bots\x18\x01 \x03(\v2\r.pb.BotStatusR\x04bots\"%\n" +
+	"\x13GetBotStatusRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"\xa7\x01\n" +
+	"\vWebhookInfo\x12\x10\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x120\n" +
+	"\x14pending_update_count\x18\x02 \x01(\x05R\x12pendingUpdateCount\x12&\n" +
+	"\x0flast_error_date\x18\x03 \x01(\x03R\rlastErrorDate\x12,\n" +
+	"\x12last_error_message\x18\x04 \x01(\tR\x10lastErrorMessage\"\xf8\x02\n" +
+	"\tBotStatus\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x18\n" +
+	"\arunning\x18\x03 \x01(\bR\arunning\x12\x1d\n" +
+	"\n" +
+	"replica_id\x18\x04 \x01(\tR\treplicaId\x12\x1d\n" +
+	"\n" +
+	"started_at\x18\x05 \x01(\x03R\tstartedAt\x12$\n" +
+	"\x0elast_update_at\x18\x06 \x01(\x03R\flastUpdateAt\x12\x1d\n" +
+	"\n" +
+	"last_error\x18\a \x01(\tR\tlastError\x12\"\n" +
+	"\rlast_error_at\x18\b \x01(\x03R\vlastErrorAt\x12)\n" +
+	"\awebhook\x18\t \x01(\v2\x0f.pb.WebhookInfoR\awebhook\x12\x1f\n" +
+	"\vusers_count\x18\n" +
+	" \x01(\x03R\n" +
+	"usersCount\x122\n" +
+	"\x15connected_users_count\x18\v \x01(\x03R\x13connectedUsersCount\"#\n" +
+	"\x11RestartBotRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\"=\n" +
+	"\x0fRestartBotReply\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\"q\n" +
+	"\x16SubscribeEventsRequest\x12\x17\n" +
+	"\abot_ids\x18\x01 \x03(\x03R\x06botIds\x12\x19\n" +
+	"\buser_ids\x18\x02 \x03(\x03R\auserIds\x12#\n" +
+	"\x05kinds\x18\x03 \x03(\x0e2\r.pb.EventKindR\x05kinds\"\xb1\x02\n" +
+	"\x05Event\x12!\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\r.pb.EventKindR\x04kind\x12\x15\n" +
+	"\x06bot_id\x18\x02 \x01(\x03R\x05botId\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\x03R\x06userId\x12\x12\n" +
+	"\x04date\x18\x04 \x01(\x03R\x04date\x12\x17\n" +
+	"\achat_id\x18\x05 \x01(\x03R\x06chatId\x12\x1b\n" +
+	"\tchat_name\x18\x06 \x01(\tR\bchatName\x12!\n" +
+	"\fmessage_json\x18\a \x01(\tR\vmessageJson\x12\x1f\n" +
+	"\vmessage_ids\x18\b \x03(\x03R\n" +
+	"messageIds\x12\x18\n" +
+	"\achanges\x18\t \x03(\tR\achanges\x12-\n" +
+	"\x12connection_enabled\x18\n" +
+	" \x01(\bR\x11connectionEnabled\"E\n" +
+	"\x14ForwardUpdateRequest\x12\x15\n" +
+	"\x06bot_id\x18\x01 \x01(\x03R\x05botId\x12\x16\n" +
+	"\x06update\x18\x02 \x01(\fR\x06update\"\x14\n" +
+	"\x12ForwardUpdateReply*\x89\x01\n" +
+	"\tEventKind\x12\x1a\n" +
+	"\x16EVENT_KIND_UNSPECIFIED\x10\x00\x12\x16\n" +
+	"\x12EVENT_KIND_MESSAGE\x10\x01\x12\x15\n" +
+	"\x11EVENT_KIND_EDITED\x10\x02\x12\x16\n" +
+	"\x12EVENT_KIND_DELETED\x10\x03\x12\x19\n" +
+	"\x15EVENT_KIND_CONNECTION\x10\x042\x8f\x03\n" +
 	"\x03Bot\x12,\n" +
 	"\x06AddBot\x12\x11.pb.AddBotRequest\x1a\x0f.pb.AddBotReply\x125\n" +
-	"\tRemoveBot\x12\x14.pb.RemoveBotRequest\x1a\x12.pb.RemoveBotReplyb\x06proto3"
+	"\tRemoveBot\x12\x14.pb.RemoveBotRequest\x1a\x12.pb.RemoveBotReply\x122\n" +
+	"\bListBots\x12\x13.pb.ListBotsRequest\x1a\x11.pb.ListBotsReply\x126\n" +
+	"\fGetBotStatus\x12\x17.pb.GetBotStatusRequest\x1a\r.pb.BotStatus\x128\n" +
+	"\n" +
+	"RestartBot\x12\x15.pb.RestartBotRequest\x1a\x13.pb.RestartBotReply\x12:\n" +
+	"\x0fSubscribeEvents\x12\x1a.pb.SubscribeEventsRequest\x1a\t.pb.Event0\x01\x12A\n" +
+	"\rForwardUpdate\x12\x18.pb.ForwardUpdateRequest\x1a\x16.pb.ForwardUpdateReplyb\x06proto3"
 
 var (
 	file_bot_proto_rawDescOnce sync.Once
@@ -244,23 +1040,50 @@ func file_bot_proto_rawDescGZIP() []byte {
 	return file_bot_proto_rawDescData
 }
 
-var file_bot_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_bot_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_bot_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
 var file_bot_proto_goTypes = []any{
-	(*AddBotRequest)(nil),    // 0: pb.AddBotRequest
-	(*AddBotReply)(nil),      // 1: pb.AddBotReply
-	(*RemoveBotRequest)(nil), // 2: pb.RemoveBotRequest
-	(*RemoveBotReply)(nil),   // 3: pb.RemoveBotReply
+	(EventKind)(0),                 // 0: pb.EventKind
+	(*AddBotRequest)(nil),          // 1: pb.AddBotRequest
+	(*AddBotReply)(nil),            // 2: pb.AddBotReply
+	(*RemoveBotRequest)(nil),       // 3: pb.RemoveBotRequest
+	(*RemoveBotReply)(nil),         // 4: pb.RemoveBotReply
+	(*ListBotsRequest)(nil),        // 5: pb.ListBotsRequest
+	(*ListBotsReply)(nil),          // 6: pb.ListBotsReply
+	(*GetBotStatusRequest)(nil),    // 7: pb.GetBotStatusRequest
+	(*WebhookInfo)(nil),            // 8: pb.WebhookInfo
+	(*BotStatus)(nil),              // 9: pb.BotStatus
+	(*RestartBotRequest)(nil),      // 10: pb.RestartBotRequest
+	(*RestartBotReply)(nil),        // 11: pb.RestartBotReply
+	(*SubscribeEventsRequest)(nil), // 12: pb.SubscribeEventsRequest
+	(*Event)(nil),                  // 13: pb.Event
+	(*ForwardUpdateRequest)(nil),   // 14: pb.ForwardUpdateRequest
+	(*ForwardUpdateReply)(nil),     // 15: pb.ForwardUpdateReply
 }
 var file_bot_proto_depIdxs = []int32{
-	0, // 0: pb.Bot.AddBot:input_type -> pb.AddBotRequest
-	2, // 1: pb.Bot.RemoveBot:input_type -> pb.RemoveBotRequest
-	1, // 2: pb.Bot.AddBot:output_type -> pb.AddBotReply
-	3, // 3: pb.Bot.RemoveBot:output_type -> pb.RemoveBotReply
-	2, // [2:4] is the sub-list for method output_type
-	0, // [0:2] is the sub-list for method input_type
-	0, // [0:0] is the sub-list for extension type_name
-	0, // [0:0] is the sub-list for extension extendee
-	0, // [0:0] is the sub-list for field type_name
+	9,  // 0: pb.ListBotsReply.bots:type_name -> pb.BotStatus
+	8,  // 1: pb.BotStatus.webhook:type_name -> pb.WebhookInfo
+	0,  // 2: pb.SubscribeEventsRequest.kinds:type_name -> pb.EventKind
+	0,  // 3: pb.Event.kind:type_name -> pb.EventKind
+	1,  // 4: pb.Bot.AddBot:input_type -> pb.AddBotRequest
+	3,  // 5: pb.Bot.RemoveBot:input_type -> pb.RemoveBotRequest
+	5,  // 6: pb.Bot.ListBots:input_type -> pb.ListBotsRequest
+	7,  // 7: pb.Bot.GetBotStatus:input_type -> pb.GetBotStatusRequest
+	10, // 8: pb.Bot.RestartBot:input_type -> pb.RestartBotRequest
+	12, // 9: pb.Bot.SubscribeEvents:input_type -> pb.SubscribeEventsRequest
+	14, // 10: pb.Bot.ForwardUpdate:input_type -> pb.ForwardUpdateRequest
+	2,  // 11: pb.Bot.AddBot:output_type -> pb.AddBotReply
+	4,  // 12: pb.Bot.RemoveBot:output_type -> pb.RemoveBotReply
+	6,  // 13: pb.Bot.ListBots:output_type -> pb.ListBotsReply
+	9,  // 14: pb.Bot.GetBotStatus:output_type -> pb.BotStatus
+	11, // 15: pb.Bot.RestartBot:output_type -> pb.RestartBotReply
+	13, // 16: pb.Bot.SubscribeEvents:output_type -> pb.Event
+	15, // 17: pb.Bot.ForwardUpdate:output_type -> pb.ForwardUpdateReply
+	11, // [11:18] is the sub-list for method output_type
+	4,  // [4:11] is the sub-list for method input_type
+	4,  // [4:4] is the sub-list for extension type_name
+	4,  // [4:4] is the sub-list for extension extendee
+	0,  // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_bot_proto_init() }
@@ -273,13 +1096,14 @@ func file_bot_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bot_proto_rawDesc), len(file_bot_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   4,
+			NumEnums:      1,
+			NumMessages:   15,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_bot_proto_goTypes,
 		DependencyIndexes: file_bot_proto_depIdxs,
+		EnumInfos:         file_bot_proto_enumTypes,
 		MessageInfos:      file_bot_proto_msgTypes,
 	}.Build()
 	File_bot_proto = out.File
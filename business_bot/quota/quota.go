@@ -0,0 +1,123 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/repository"
+)
+
+// maxDropRounds - сколько пачек удалять перед одним сообщением. Если квота все еще заполнена,
+// сообщение сохраняется, следующие продолжат освобождать место
+const maxDropRounds = 5
+
+type cachedQuota struct {
+	quota     *repository.Quota
+	expiresAt time.Time
+}
+
+// Enforcer проверяет квоты перед сохранением сообщений. Лимиты ботов кэшируются на CacheTTL
+type Enforcer struct {
+	service *repository.MongoRepository
+	cfg     *config.QuotaConfig
+
+	mutex sync.Mutex
+	bots  map[int64]cachedQuota
+}
+
+func NewEnforcer(service *repository.MongoRepository, cfg *config.QuotaConfig) *Enforcer {
+	return &Enforcer{
+		service: service,
+		cfg:     cfg,
+		bots:    make(map[int64]cachedQuota),
+	}
+}
+
+// Limits - лимиты для пользователей бота. Владелец бота может только ужесточить лимиты сервера:
+// из двух ненулевых берется меньший
+func (e *Enforcer) Limits(ctx context.Context, botID int64) (*repository.Quota, error) {
+	e.mutex.Lock()
+	cached, ok := e.bots[botID]
+	e.mutex.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.quota, nil
+	}
+
+	override, err := e.service.BotQuota(ctx, botID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed get bot quota: %w", err)
+	}
+
+	limits := &repository.Quota{
+		MaxMessages:     e.cfg.MaxMessages,
+		MaxMessageBytes: e.cfg.MaxMessageBytes,
+		MaxMediaBytes:   e.cfg.MaxMediaBytes,
+		Policy:          e.cfg.Policy,
+	}
+	if override != nil {
+		limits.MaxMessages = stricter(limits.MaxMessages, override.MaxMessages)
+		limits.MaxMessageBytes = stricter(limits.MaxMessageBytes, override.MaxMessageBytes)
+		limits.MaxMediaBytes = stricter(limits.MaxMediaBytes, override.MaxMediaBytes)
+		if override.Policy == consts.QUOTA_POLICY_REJECT || override.Policy == consts.QUOTA_POLICY_DROP_OLDEST {
+			limits.Policy = override.Policy
+		}
+	}
+
+	e.mutex.Lock()
+	e.bots[botID] = cachedQuota{quota: limits, expiresAt: time.Now().Add(e.cfg.CacheTTL)}
+	e.mutex.Unlock()
+	return limits, nil
+}
+
+// Allow решает, можно ли сохранить еще одно сообщение пользователя бота.
+// При drop_oldest сначала удаляет его самые старые сообщения
+func (e *Enforcer) Allow(ctx context.Context, botUser *repository.BotUser) (bool, error) {
+	limits, err := e.Limits(ctx, botUser.BotID)
+	if err != nil {
+		return false, err
+	}
+	if !limits.Exceeded(botUser.Usage) {
+		return true, nil
+	}
+
+	if limits.Policy != consts.QUOTA_POLICY_DROP_OLDEST {
+		metrics.QuotaMessagesTotal.WithLabelValues("rejected").Inc()
+		return false, nil
+	}
+
+	connectionIDs := botUser.GetUserCurrentConnectionIDs()
+	for range maxDropRounds {
+		deleted, err := e.service.DropOldestMessages(ctx, connectionIDs, e.cfg.DropBatch)
+		if err != nil {
+			return false, fmt.Errorf("failed drop oldest messages: %w", err)
+		}
+		metrics.QuotaMessagesTotal.WithLabelValues("dropped").Add(float64(deleted))
+		if deleted == 0 {
+			break
+		}
+
+		fresh, err := e.service.FindBotUser(ctx, botUser.UserID, botUser.BotID)
+		if err != nil {
+			return false, fmt.Errorf("failed get bot user usage: %w", err)
+		}
+		if !limits.Exceeded(fresh.Usage) {
+			break
+		}
+	}
+	return true, nil
+}
+
+func stricter(server, owner int64) int64 {
+	if server == 0 || owner > 0 && owner < server {
+		return owner
+	}
+	return server
+}
@@ -0,0 +1,76 @@
+package redis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mymmrac/telego"
+
+	"ssuspy-common/crypto"
+)
+
+// Подписи медиа в задачах очереди файлов при включенном шифровании сообщений хранятся так же,
+// как тело вебхуков: одним конвертом, зашифрованным ключом пользователя. Расшифровываются они
+// только в памяти воркера, в записи задачи (в том числе при повторе) остается конверт
+
+type sealedCaption struct {
+	Text     string                 `json:"t"`
+	Entities []telego.MessageEntity `json:"e,omitempty"`
+}
+
+func sealCaption(box *crypto.Box, userID int64, text *string, entities *[]telego.MessageEntity, sealed *string) error {
+	if box == nil || *text == "" {
+		return nil
+	}
+
+	payload, err := json.Marshal(sealedCaption{Text: *text, Entities: *entities})
+	if err != nil {
+		return err
+	}
+	*sealed, err = box.Encrypt(string(payload), crypto.UserAAD(userID))
+	if err != nil {
+		return fmt.Errorf("failed to seal caption: %w", err)
+	}
+	*text, *entities = "", nil
+	return nil
+}
+
+func openCaption(box *crypto.Box, userID int64, text string, entities []telego.MessageEntity, sealed string) (string, []telego.MessageEntity, error) {
+	if sealed == "" {
+		return text, entities, nil
+	}
+	if box == nil {
+		return "", nil, errors.New("caption is sealed, but message encryption is disabled")
+	}
+
+	payload, err := box.Decrypt(sealed, crypto.UserAAD(userID))
+	if err != nil {
+		return "", nil, err
+	}
+	var opened sealedCaption
+	if err := json.Unmarshal([]byte(payload), &opened); err != nil {
+		return "", nil, fmt.Errorf("failed unmarshal sealed caption: %w", err)
+	}
+	return opened.Text, opened.Entities, nil
+}
+
+// SealCaption шифрует подпись задачи ключом сообщений пользователя, box nil - шифрование выключено
+func (j *Job) SealCaption(box *crypto.Box) error {
+	return sealCaption(box, j.UserID, &j.Caption, &j.CaptionEntities, &j.SealedCaption)
+}
+
+// OpenCaption возвращает подпись задачи, саму задачу не меняет
+func (j *Job) OpenCaption(box *crypto.Box) (string, []telego.MessageEntity, error) {
+	return openCaption(box, j.UserID, j.Caption, j.CaptionEntities, j.SealedCaption)
+}
+
+// SealCaption шифрует подпись медиа ключом сообщений пользователя userID, box nil - шифрование выключено
+func (c *CaptureItem) SealCaption(box *crypto.Box, userID int64) error {
+	return sealCaption(box, userID, &c.Caption, &c.CaptionEntities, &c.SealedCaption)
+}
+
+// OpenCaption возвращает подпись медиа, само медиа не меняет
+func (c *CaptureItem) OpenCaption(box *crypto.Box, userID int64) (string, []telego.MessageEntity, error) {
+	return openCaption(box, userID, c.Caption, c.CaptionEntities, c.SealedCaption)
+}
@@ -0,0 +1,100 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/types"
+	"time"
+
+	"github.com/mymmrac/telego"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// переименовывает пачку чата (KEYS[1]) в пачку задачи (KEYS[2]) и отдает ее. Если пачка задачи
+// уже есть, это повтор после падения воркера: пачку чата не трогаем, она уже принадлежит новой задаче
+var takeCapturesScript = goredis.NewScript(`
+if redis.call("EXISTS", KEYS[2]) == 0 and redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("RENAME", KEYS[1], KEYS[2])
+	redis.call("PEXPIRE", KEYS[2], ARGV[1])
+end
+return redis.call("LRANGE", KEYS[2], 0, -1)
+`)
+
+// CaptureItem - защищенное медиа собеседника, которое ждет отправки альбомом
+type CaptureItem struct {
+	File            *types.MediaItem
+	Caption         string
+	CaptionEntities []telego.MessageEntity
+	// SealedCaption - Caption с entities, зашифрованные ключом сообщений пользователя (см. SealCaption)
+	SealedCaption string `json:",omitempty"`
+	// Sent - файл уже отправлен, при повторе задачи он пропускается
+	Sent bool
+}
+
+func captureKey(userID int64, chatID int64) string {
+	return fmt.Sprintf("%s:%d:%d", consts.REDIS_CAPTURE, userID, chatID)
+}
+
+// captureJobKey - пачка, которую забрала задача. Под тем же префиксом пользователя, чтобы ее удалял ForgetUser
+func captureJobKey(userID int64, jobID string) string {
+	return fmt.Sprintf("%s:%d:job:%s", consts.REDIS_CAPTURE, userID, jobID)
+}
+
+// AddCapture добавляет медиа в пачку чата. true - пачка только началась, и для нее нужно поставить задачу.
+// ttl страхует от пачек, задача которых потерялась
+func (r *Redis) AddCapture(ctx context.Context, userID int64, chatID int64, item *CaptureItem, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal capture: %w", err)
+	}
+
+	key := captureKey(userID, chatID)
+	pipe := r.TxPipeline()
+	length := pipe.RPush(ctx, key, data)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return length.Val() == 1, nil
+}
+
+// TakeCaptures забирает всю пачку чата в пачку задачи jobID, следующее медиа начнет новую.
+// Пачка задачи остается в redis, пока ее не удалит DropCaptures, так что после падения воркера
+// повтор задачи получит те же файлы. ttl страхует от задач, которые так и не завершились
+func (r *Redis) TakeCaptures(ctx context.Context, userID int64, chatID int64, jobID string, ttl time.Duration) ([]*CaptureItem, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	entries, err := takeCapturesScript.Run(
+		ctx,
+		r,
+		[]string{captureKey(userID, chatID), captureJobKey(userID, jobID)},
+		ttl.Milliseconds(),
+	).StringSlice()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*CaptureItem, 0, len(entries))
+	for _, entry := range entries {
+		var item CaptureItem
+		if err := json.Unmarshal([]byte(entry), &item); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal capture: %w", err)
+		}
+		items = append(items, &item)
+	}
+	return items, nil
+}
+
+// DropCaptures удаляет пачку задачи, когда она отправлена
+func (r *Redis) DropCaptures(ctx context.Context, userID int64, jobID string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	return r.Del(ctx, captureJobKey(userID, jobID)).Err()
+}
@@ -5,22 +5,147 @@ import (
 	"encoding/json"
 	"fmt"
 	"ssuspy-bot/types"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
 	goredis "github.com/redis/go-redis/v9"
 )
 
+// Очередь задач с подтверждением, на ней же работает очередь вебхуков. DequeueJob переносит задачу из queueKey в список :processing,
+// а в :inflight записывает срок, до которого ее нужно подтвердить (AckJob) или продлить (TouchJob).
+// Упавшие задачи откладываются в :retry или после последней попытки попадают в :dead,
+// неподтвержденные вовремя находит ExpiredJobs
+
+// переносит отложенные задачи, у которых подошло время, обратно в очередь, не больше ARGV[2] за раз
+var promoteScript = goredis.NewScript(`
+local jobs = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, job in ipairs(jobs) do
+	redis.call("RPUSH", KEYS[2], job)
+	redis.call("ZREM", KEYS[1], job)
+end
+return #jobs
+`)
+
+// забирает задачу из обработки и кладет ее новую версию в retry (ARGV[3] = "retry", ARGV[4] - время)
+// или в dead (ARGV[4] - размер списка, 0 - не обрезать). Если задачу уже забрали, ничего не делает
+var moveJobScript = goredis.NewScript(`
+if redis.call("ZREM", KEYS[1], ARGV[1]) == 0 or redis.call("LREM", KEYS[2], 1, ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[3] == "retry" then
+	redis.call("ZADD", KEYS[3], ARGV[4], ARGV[2])
+else
+	redis.call("LPUSH", KEYS[3], ARGV[2])
+	if tonumber(ARGV[4]) > 0 then
+		redis.call("LTRIM", KEYS[3], 0, tonumber(ARGV[4]) - 1)
+	end
+end
+return 1
+`)
+
+// ставит срок ARGV[1] задачам из обработки, у которых его нет
+var deadlineScript = goredis.NewScript(`
+for _, job in ipairs(redis.call("LRANGE", KEYS[1], 0, -1)) do
+	if not redis.call("ZSCORE", KEYS[2], job) then
+		redis.call("ZADD", KEYS[2], ARGV[1], job)
+	end
+end
+return 0
+`)
+
+type JobType int
+
+const (
+	JobTypeFiles JobType = iota
+	JobTypeExport
+	// JobTypeArchive - скачать File в архив медиа, пользователю ничего не отправляется
+	JobTypeArchive
+	// JobTypeCapture - прислать альбомом защищенные медиа чата, собранные в пачку при получении
+	JobTypeCapture
+)
+
+type ExportJob struct {
+	// ChatID - 0 для экспорта всех чатов
+	ChatID        int64
+	ConnectionIDs []string
+	Format        string
+	// Archive - архив, часть которого уже отправлена. Заполняется перед повтором, чтобы не слать части дважды
+	Archive *ExportArchive
+}
+
+// ExportArchive - собранный архив экспорта. Path - файл на диске реплики, которая его собрала,
+// на другой реплике архив собирается и отправляется заново. Содержимого сообщений в задаче нет,
+// только путь и счетчики, поэтому шифровать здесь нечего
+type ExportArchive struct {
+	Path      string
+	FileName  string
+	Size      int64
+	Chats     int
+	Messages  int
+	SentParts int
+}
+
 type Job struct {
+	// ID делает задачи уникальными, по записи задачи ее находят в :processing и :inflight
+	ID               string
+	Type             JobType
 	File             *types.MediaItem
+	Export           *ExportJob
 	UserID           int64
 	UserLanguageCode string
 	ChatID           int64
 	MessageID        int
 	Caption          string
+	CaptionEntities  []telego.MessageEntity
 	BotID            int64
+	// SealedCaption - Caption с entities, зашифрованные ключом сообщений пользователя (см. SealCaption)
+	SealedCaption string `json:",omitempty"`
+
+	// ChatName и Captures - для JobTypeCapture. Captures заполняются из пачки при первой попытке,
+	// чтобы повтор отправил те же файлы, подписи в них остаются зашифрованными (см. CaptureItem.SealCaption).
+	// HeaderSent - заголовок уже отправлен, повтор шлет только оставшиеся альбомы
+	ChatName   string
+	Captures   []*CaptureItem
+	HeaderSent bool
+
+	// Attempt - сколько попыток уже было
+	Attempt   int
+	LastError string
+
+	// raw - запись задачи в :processing, нужна для AckJob и перемещения
+	raw string
+}
+
+type QueueStats struct {
+	Pending    int64
+	Processing int64
+	Retry      int64
+	Dead       int64
+}
+
+func processingKey(queueKey string) string {
+	return queueKey + ":processing"
+}
+
+func inflightKey(queueKey string) string {
+	return queueKey + ":inflight"
+}
+
+func retryKey(queueKey string) string {
+	return queueKey + ":retry"
+}
+
+func deadKey(queueKey string) string {
+	return queueKey + ":dead"
 }
 
 func (r *Redis) EnqueueJob(ctx context.Context, queueKey string, job Job) error {
+	if job.ID == "" {
+		job.ID = uuid.New().String()
+	}
+
 	data, err := json.Marshal(job)
 	if err != nil {
 		return fmt.Errorf("failed to marshal job: %w", err)
@@ -28,22 +153,204 @@ func (r *Redis) EnqueueJob(ctx context.Context, queueKey string, job Job) error
 	return r.RPush(ctx, queueKey, data).Err()
 }
 
-func (r *Redis) DequeueJob(ctx context.Context, queueKey string, timeout time.Duration) (*Job, error) {
-	res, err := r.BRPop(ctx, timeout, queueKey).Result()
+// ScheduleJob кладет задачу сразу в отложенные, в очередь ее вернет PromoteJobs после at
+func (r *Redis) ScheduleJob(ctx context.Context, queueKey string, job Job, at time.Time) error {
+	if job.ID == "" {
+		job.ID = uuid.New().String()
+	}
+
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+	return r.ZAdd(ctx, retryKey(queueKey), goredis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: data,
+	}).Err()
+}
+
+func (r *Redis) QueueLength(ctx context.Context, queueKey string) (int64, error) {
+	return r.LLen(ctx, queueKey).Result()
+}
+
+// DequeueJob берет самую старую задачу. Ее нужно подтвердить AckJob за visibility,
+// иначе ExpiredJobs посчитает ее упавшей
+func (r *Redis) DequeueJob(ctx context.Context, queueKey string, timeout time.Duration, visibility time.Duration) (*Job, error) {
+	raw, err := r.dequeueRaw(ctx, queueKey, timeout, visibility)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	job, err := decodeJob(raw)
+	if err != nil {
+		if errBury := r.buryJob(ctx, queueKey, raw); errBury != nil {
+			return nil, errBury
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// dequeueRaw переносит запись из очереди в обработку и ставит ей срок. "" - очередь пуста
+func (r *Redis) dequeueRaw(ctx context.Context, queueKey string, timeout time.Duration, visibility time.Duration) (string, error) {
+	raw, err := r.BLMove(ctx, queueKey, processingKey(queueKey), "LEFT", "RIGHT", timeout).Result()
 	if err != nil {
 		if err == goredis.Nil {
-			return nil, nil
+			return "", nil
 		}
-		return nil, fmt.Errorf("redis BRPop error: %w", err)
+		return "", fmt.Errorf("redis BLMove error: %w", err)
+	}
+
+	// если не запишется, срок поставит ExpiredJobs
+	err = r.ZAdd(ctx, inflightKey(queueKey), goredis.Z{
+		Score:  float64(time.Now().Add(visibility).UnixMilli()),
+		Member: raw,
+	}).Err()
+	if err != nil {
+		return "", fmt.Errorf("failed to set job deadline: %w", err)
 	}
+	return raw, nil
+}
 
+func decodeJob(raw string) (*Job, error) {
 	var job Job
-	if err := json.Unmarshal([]byte(res[1]), &job); err != nil {
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal job: %w", err)
 	}
+	job.raw = raw
 	return &job, nil
 }
 
-func (r *Redis) QueueLen(ctx context.Context, queueKey string) (int64, error) {
-	return r.LLen(ctx, queueKey).Result()
+// TouchJob продлевает срок задачи, которая еще обрабатывается
+func (r *Redis) TouchJob(ctx context.Context, queueKey string, job *Job, visibility time.Duration) error {
+	return r.touchRaw(ctx, queueKey, job.raw, visibility)
+}
+
+func (r *Redis) touchRaw(ctx context.Context, queueKey string, raw string, visibility time.Duration) error {
+	return r.ZAddXX(ctx, inflightKey(queueKey), goredis.Z{
+		Score:  float64(time.Now().Add(visibility).UnixMilli()),
+		Member: raw,
+	}).Err()
+}
+
+func (r *Redis) AckJob(ctx context.Context, queueKey string, job *Job) error {
+	return r.ackRaw(ctx, queueKey, job.raw)
+}
+
+func (r *Redis) ackRaw(ctx context.Context, queueKey string, raw string) error {
+	pipe := r.TxPipeline()
+	pipe.ZRem(ctx, inflightKey(queueKey), raw)
+	pipe.LRem(ctx, processingKey(queueKey), 1, raw)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RetryJob забирает задачу из обработки и откладывает ее до at, в очередь ее вернет PromoteJobs
+func (r *Redis) RetryJob(ctx context.Context, queueKey string, job *Job, at time.Time) error {
+	return r.moveJob(ctx, queueKey, job.raw, job, retryKey(queueKey), "retry", at.UnixMilli())
+}
+
+// DeadJob забирает задачу из обработки в dead letter список, храня только последние size
+func (r *Redis) DeadJob(ctx context.Context, queueKey string, job *Job, size int64) error {
+	return r.moveJob(ctx, queueKey, job.raw, job, deadKey(queueKey), "dead", size)
+}
+
+// moveJob заменяет запись raw новой версией задачи job в target
+func (r *Redis) moveJob(ctx context.Context, queueKey string, raw string, job any, target string, mode string, arg int64) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal job: %w", err)
+	}
+
+	return moveJobScript.Run(
+		ctx,
+		r,
+		[]string{inflightKey(queueKey), processingKey(queueKey), target},
+		raw,
+		data,
+		mode,
+		arg,
+	).Err()
+}
+
+// buryJob переносит в dead запись, которую не удалось разобрать, как есть
+func (r *Redis) buryJob(ctx context.Context, queueKey string, raw string) error {
+	return moveJobScript.Run(
+		ctx,
+		r,
+		[]string{inflightKey(queueKey), processingKey(queueKey), deadKey(queueKey)},
+		raw,
+		raw,
+		"dead",
+		0,
+	).Err()
+}
+
+func (r *Redis) PromoteJobs(ctx context.Context, queueKey string, now time.Time, limit int) (int64, error) {
+	return promoteScript.Run(
+		ctx,
+		r,
+		[]string{retryKey(queueKey), queueKey},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		limit,
+	).Int64()
+}
+
+// ExpiredJobs - задачи, срок которых истек: воркер упал или завис. Задачам, у которых срока нет
+// (реплика упала сразу после DequeueJob), ставит срок now+visibility
+func (r *Redis) ExpiredJobs(ctx context.Context, queueKey string, now time.Time, visibility time.Duration, limit int64) ([]*Job, error) {
+	expired, err := r.expiredRaw(ctx, queueKey, now, visibility, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*Job, 0, len(expired))
+	for _, raw := range expired {
+		job, err := decodeJob(raw)
+		if err != nil {
+			// запись не разобрать, повторять ее бессмысленно
+			if err := r.buryJob(ctx, queueKey, raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
+
+func (r *Redis) expiredRaw(ctx context.Context, queueKey string, now time.Time, visibility time.Duration, limit int64) ([]string, error) {
+	err := deadlineScript.Run(
+		ctx,
+		r,
+		[]string{processingKey(queueKey), inflightKey(queueKey)},
+		now.Add(visibility).UnixMilli(),
+	).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.ZRangeByScore(ctx, inflightKey(queueKey), &goredis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.UnixMilli(), 10),
+		Count: limit,
+	}).Result()
+}
+
+func (r *Redis) QueueStats(ctx context.Context, queueKey string) (*QueueStats, error) {
+	pipe := r.Pipeline()
+	pending := pipe.LLen(ctx, queueKey)
+	processing := pipe.LLen(ctx, processingKey(queueKey))
+	retry := pipe.ZCard(ctx, retryKey(queueKey))
+	dead := pipe.LLen(ctx, deadKey(queueKey))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	return &QueueStats{
+		Pending:    pending.Val(),
+		Processing: processing.Val(),
+		Retry:      retry.Val(),
+		Dead:       dead.Val(),
+	}, nil
 }
@@ -0,0 +1,137 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"ssuspy-bot/consts"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ForgetUser удаляет ключи ratelimit/isolation и временные данные пользователя,
+// его задачи в очередях файлов и вебхуков, а также записи ignore списка по его чатам. Возвращает количество удаленных ключей и записей
+func (r *Redis) ForgetUser(ctx context.Context, userID int64, chatIDs []int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	defer cancel()
+
+	keys := []string{
+		fmt.Sprintf("%s:%d", consts.REDIS_RATELIMIT_COUNT, userID),
+		fmt.Sprintf("%s:%d", consts.REDIS_RATELIMIT_QUEUE, userID),
+		fmt.Sprintf("%s:%d", consts.REDIS_RATELIMIT_QUEUE_BUSINESS, userID),
+		fmt.Sprintf("%s:%d", consts.REDIS_RATELIMIT_QUEUE_BUSINESS_CONNECTION, userID),
+	}
+
+	for _, prefix := range []string{consts.REDIS_PUBLIC_GIFTS, consts.REDIS_CAPTURE} {
+		iter := r.Scan(ctx, 0, fmt.Sprintf("%s:%d:*", prefix, userID), 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return 0, err
+		}
+	}
+
+	deleted, err := r.Del(ctx, keys...).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	// в задачах очередей лежат тексты сообщений и файлы пользователя
+	jobs, err := r.forgetJobs(
+		ctx,
+		[]string{
+			consts.REDIS_QUEUE_FILES,
+			processingKey(consts.REDIS_QUEUE_FILES),
+			deadKey(consts.REDIS_QUEUE_FILES),
+			consts.REDIS_QUEUE_WEBHOOKS,
+			processingKey(consts.REDIS_QUEUE_WEBHOOKS),
+			deadKey(consts.REDIS_QUEUE_WEBHOOKS),
+		},
+		[]string{
+			inflightKey(consts.REDIS_QUEUE_FILES),
+			retryKey(consts.REDIS_QUEUE_FILES),
+			inflightKey(consts.REDIS_QUEUE_WEBHOOKS),
+			retryKey(consts.REDIS_QUEUE_WEBHOOKS),
+		},
+		func(entry string) bool {
+			var job struct{ UserID int64 }
+			return json.Unmarshal([]byte(entry), &job) == nil && job.UserID == userID
+		},
+	)
+	deleted += jobs
+	if err != nil {
+		return deleted, err
+	}
+
+	if len(chatIDs) == 0 {
+		return deleted, nil
+	}
+
+	chats := make(map[string]bool, len(chatIDs))
+	for _, chatID := range chatIDs {
+		chats[strconv.FormatInt(chatID, 10)] = true
+	}
+
+	// в ignore лежат "messageID|chatID"
+	ignored, err := r.LRange(ctx, consts.REDIS_IGNORE, 0, -1).Result()
+	if err != nil {
+		return deleted, err
+	}
+	for _, entry := range ignored {
+		_, chatID, found := strings.Cut(entry, "|")
+		if !found || !chats[chatID] {
+			continue
+		}
+
+		removed, err := r.LRem(ctx, consts.REDIS_IGNORE, 0, entry).Result()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += removed
+	}
+
+	return deleted, nil
+}
+
+// forgetJobs удаляет из списков lists и множеств sets записи, для которых belongs вернет true
+func (r *Redis) forgetJobs(ctx context.Context, lists []string, sets []string, belongs func(entry string) bool) (int64, error) {
+	var deleted int64
+
+	for _, key := range lists {
+		entries, err := r.LRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return deleted, err
+		}
+		for _, entry := range entries {
+			if !belongs(entry) {
+				continue
+			}
+			removed, err := r.LRem(ctx, key, 0, entry).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += removed
+		}
+	}
+
+	for _, key := range sets {
+		entries, err := r.ZRange(ctx, key, 0, -1).Result()
+		if err != nil {
+			return deleted, err
+		}
+		for _, entry := range entries {
+			if !belongs(entry) {
+				continue
+			}
+			removed, err := r.ZRem(ctx, key, entry).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += removed
+		}
+	}
+
+	return deleted, nil
+}
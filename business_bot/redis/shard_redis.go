@@ -0,0 +1,194 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"ssuspy-bot/consts"
+)
+
+// продлевает аренду, если она наша; занимает свободную; allowRemoved - можно занять и аренду удаленного бота
+var acquireLeaseScript = goredis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current == ARGV[1] or (current == ARGV[3] and ARGV[4] == "1") then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if not current then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0
+`)
+
+var releaseLeaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// продлевает аренду, только если она все еще наша
+var renewLeaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+func botLeaseKey(botID int64) string {
+	return fmt.Sprintf("%s:%d", consts.REDIS_SHARD_BOT_LEASE, botID)
+}
+
+// ShardHeartbeat отмечает реплику живой на ttl и чистит реплики, которые давно не отмечались
+func (r *Redis) ShardHeartbeat(ctx context.Context, replicaID string, addr string, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	now := time.Now()
+	expired, err := r.ZRangeByScore(ctx, consts.REDIS_SHARD_REPLICAS, &goredis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := r.TxPipeline()
+	if len(expired) > 0 {
+		pipe.ZRem(ctx, consts.REDIS_SHARD_REPLICAS, expired)
+		pipe.HDel(ctx, consts.REDIS_SHARD_ADDRS, expired...)
+	}
+	pipe.ZAdd(ctx, consts.REDIS_SHARD_REPLICAS, goredis.Z{
+		Score:  float64(now.Add(ttl).UnixMilli()),
+		Member: replicaID,
+	})
+	pipe.HSet(ctx, consts.REDIS_SHARD_ADDRS, replicaID, addr)
+
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// ShardReplicas возвращает живые реплики: ID -> gRPC адрес
+func (r *Redis) ShardReplicas(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	ids, err := r.ZRangeByScore(ctx, consts.REDIS_SHARD_REPLICAS, &goredis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(time.Now().UnixMilli(), 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return map[string]string{}, nil
+	}
+
+	addrs, err := r.HMGet(ctx, consts.REDIS_SHARD_ADDRS, ids...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make(map[string]string, len(ids))
+	for i, id := range ids {
+		addr, _ := addrs[i].(string)
+		replicas[id] = addr
+	}
+	return replicas, nil
+}
+
+func (r *Redis) ShardLeave(ctx context.Context, replicaID string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	pipe := r.TxPipeline()
+	pipe.ZRem(ctx, consts.REDIS_SHARD_REPLICAS, replicaID)
+	pipe.HDel(ctx, consts.REDIS_SHARD_ADDRS, replicaID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// AcquireBotLease занимает или продлевает аренду бота для реплики. false - бот принадлежит другой реплике
+// (или недавно удален, если allowRemoved = false)
+func (r *Redis) AcquireBotLease(ctx context.Context, botID int64, replicaID string, ttl time.Duration, allowRemoved bool) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	allow := "0"
+	if allowRemoved {
+		allow = "1"
+	}
+
+	res, err := acquireLeaseScript.Run(
+		ctx, r.Client,
+		[]string{botLeaseKey(botID)},
+		replicaID, ttl.Milliseconds(), consts.SHARD_LEASE_REMOVED, allow,
+	).Int()
+	if err != nil {
+		return false, err
+	}
+	return res == 1, nil
+}
+
+// RenewBotLeases продлевает аренды ботов одним запросом и возвращает ботов, чьи аренды потеряны.
+// Свободную аренду не занимает: бота могли только что остановить и отпустить другой реплике
+func (r *Redis) RenewBotLeases(ctx context.Context, botIDs []int64, replicaID string, ttl time.Duration) ([]int64, error) {
+	if len(botIDs) == 0 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	pipe := r.Pipeline()
+	cmds := make([]*goredis.Cmd, len(botIDs))
+	for i, botID := range botIDs {
+		cmds[i] = renewLeaseScript.Eval(ctx, pipe, []string{botLeaseKey(botID)}, replicaID, ttl.Milliseconds())
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	var lost []int64
+	for i, cmd := range cmds {
+		if renewed, _ := cmd.Int(); renewed == 0 {
+			lost = append(lost, botIDs[i])
+		}
+	}
+	return lost, nil
+}
+
+// ReleaseBotLease освобождает аренду, только если она принадлежит реплике
+func (r *Redis) ReleaseBotLease(ctx context.Context, botID int64, replicaID string) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	return releaseLeaseScript.Run(ctx, r.Client, []string{botLeaseKey(botID)}, replicaID).Err()
+}
+
+// MarkBotLeaseRemoved занимает аренду удаленного бота на ttl, чтобы ребалансировка
+// не успела запустить его снова, пока он не удален из базы
+func (r *Redis) MarkBotLeaseRemoved(ctx context.Context, botID int64, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	return r.Set(ctx, botLeaseKey(botID), consts.SHARD_LEASE_REMOVED, ttl).Err()
+}
+
+// BotLeaseHolder возвращает ID реплики, на которой запущен бот, "" - нигде
+func (r *Redis) BotLeaseHolder(ctx context.Context, botID int64) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*5)
+	defer cancel()
+
+	holder, err := r.Get(ctx, botLeaseKey(botID)).Result()
+	if errors.Is(err, goredis.Nil) || holder == consts.SHARD_LEASE_REMOVED {
+		return "", nil
+	}
+	return holder, err
+}
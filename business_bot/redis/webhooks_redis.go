@@ -0,0 +1,117 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"ssuspy-bot/consts"
+)
+
+// События вебхуков идут через ту же очередь с подтверждением, что и задачи файлов:
+// доставка, начатая упавшей репликой, не теряется, а повторяется после истечения срока
+
+type WebhookJob struct {
+	// ID - одинаковый у всех попыток доставки одного события
+	ID      string
+	UserID  int64
+	Event   string
+	Payload json.RawMessage
+	// SealedPayload - Payload, зашифрованный ключом сообщений пользователя, если шифрование включено
+	SealedPayload string `json:",omitempty"`
+	// Attempt - сколько попыток уже было
+	Attempt   int
+	LastError string
+	CreatedAt int64
+
+	// raw - запись события в :processing, нужна для AckWebhook и перемещения
+	raw string
+}
+
+func (r *Redis) EnqueueWebhook(ctx context.Context, job WebhookJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook job: %w", err)
+	}
+	return r.RPush(ctx, consts.REDIS_QUEUE_WEBHOOKS, data).Err()
+}
+
+// DequeueWebhook берет самое старое событие. Его нужно подтвердить AckWebhook за visibility,
+// иначе ExpiredWebhooks посчитает доставку упавшей
+func (r *Redis) DequeueWebhook(ctx context.Context, timeout time.Duration, visibility time.Duration) (*WebhookJob, error) {
+	raw, err := r.dequeueRaw(ctx, consts.REDIS_QUEUE_WEBHOOKS, timeout, visibility)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+
+	job, err := decodeWebhook(raw)
+	if err != nil {
+		if errBury := r.buryJob(ctx, consts.REDIS_QUEUE_WEBHOOKS, raw); errBury != nil {
+			return nil, errBury
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func decodeWebhook(raw string) (*WebhookJob, error) {
+	var job WebhookJob
+	if err := json.Unmarshal([]byte(raw), &job); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook job: %w", err)
+	}
+	job.raw = raw
+	return &job, nil
+}
+
+// TouchWebhook продлевает срок доставки, которая еще идет
+func (r *Redis) TouchWebhook(ctx context.Context, job *WebhookJob, visibility time.Duration) error {
+	return r.touchRaw(ctx, consts.REDIS_QUEUE_WEBHOOKS, job.raw, visibility)
+}
+
+func (r *Redis) AckWebhook(ctx context.Context, job *WebhookJob) error {
+	return r.ackRaw(ctx, consts.REDIS_QUEUE_WEBHOOKS, job.raw)
+}
+
+// RetryWebhook забирает событие из обработки и откладывает доставку до at, в очередь ее вернет PromoteWebhooks
+func (r *Redis) RetryWebhook(ctx context.Context, job *WebhookJob, at time.Time) error {
+	return r.moveJob(ctx, consts.REDIS_QUEUE_WEBHOOKS, job.raw, job, retryKey(consts.REDIS_QUEUE_WEBHOOKS), "retry", at.UnixMilli())
+}
+
+// DeadWebhook забирает недоставленное событие из обработки в dead letter список, храня только последние size
+func (r *Redis) DeadWebhook(ctx context.Context, job *WebhookJob, size int64) error {
+	return r.moveJob(ctx, consts.REDIS_QUEUE_WEBHOOKS, job.raw, job, deadKey(consts.REDIS_QUEUE_WEBHOOKS), "dead", size)
+}
+
+func (r *Redis) PromoteWebhooks(ctx context.Context, now time.Time, limit int) (int64, error) {
+	return promoteScript.Run(
+		ctx,
+		r,
+		[]string{retryKey(consts.REDIS_QUEUE_WEBHOOKS), consts.REDIS_QUEUE_WEBHOOKS},
+		strconv.FormatInt(now.UnixMilli(), 10),
+		limit,
+	).Int64()
+}
+
+// ExpiredWebhooks - доставки, срок которых истек: реплика упала или зависла посреди запроса
+func (r *Redis) ExpiredWebhooks(ctx context.Context, now time.Time, visibility time.Duration, limit int64) ([]*WebhookJob, error) {
+	expired, err := r.expiredRaw(ctx, consts.REDIS_QUEUE_WEBHOOKS, now, visibility, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]*WebhookJob, 0, len(expired))
+	for _, raw := range expired {
+		job, err := decodeWebhook(raw)
+		if err != nil {
+			// запись не разобрать, повторять ее бессмысленно
+			if err := r.buryJob(ctx, consts.REDIS_QUEUE_WEBHOOKS, raw); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ArchivedFile - копия файла в хранилище архива. Одна копия на file_unique_id,
+// UserIDs - пользователи, в чатах которых встречался файл. Копия без пользователей удаляется
+type ArchivedFile struct {
+	FileUniqueID string `bson:"_id"`
+	Key          string `bson:"key"`
+	// Name - имя, под которым файл отправляется, с расширением из file_path
+	Name      string  `bson:"name"`
+	Type      string  `bson:"type"`
+	Size      int64   `bson:"size"`
+	UserIDs   []int64 `bson:"user_ids"`
+	CreatedAt int64   `bson:"created_at"`
+}
+
+// GetArchivedFile ищет копию среди файлов пользователя, чужие копии он получить не может
+func (r *MongoRepository) GetArchivedFile(ctx context.Context, fileUniqueID string, userID int64) (*ArchivedFile, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var file ArchivedFile
+	err := r.archivedFiles.FindOne(ctx, bson.M{"_id": fileUniqueID, "user_ids": userID}).Decode(&file)
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// AddArchivedFileUser добавляет пользователя к уже сохраненной копии. false - копии еще нет
+func (r *MongoRepository) AddArchivedFileUser(ctx context.Context, fileUniqueID string, userID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.archivedFiles.UpdateOne(
+		ctx,
+		bson.M{"_id": fileUniqueID},
+		bson.M{"$addToSet": bson.M{"user_ids": userID}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.MatchedCount > 0, nil
+}
+
+// SaveArchivedFile сохраняет запись о копии. Если другой воркер успел сохранить копию раньше,
+// запись не меняется (кроме пользователя) и возвращается false, тогда свою копию нужно удалить
+func (r *MongoRepository) SaveArchivedFile(ctx context.Context, file *ArchivedFile, userID int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.archivedFiles.UpdateOne(
+		ctx,
+		bson.M{"_id": file.FileUniqueID},
+		bson.M{
+			"$setOnInsert": bson.M{
+				"key":        file.Key,
+				"name":       file.Name,
+				"type":       file.Type,
+				"size":       file.Size,
+				"created_at": time.Now().Unix(),
+			},
+			"$addToSet": bson.M{"user_ids": userID},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return false, err
+	}
+	return res.UpsertedCount == 1, nil
+}
+
+// OrphanArchivedFiles - копии, которые больше не нужны ни одному пользователю
+func (r *MongoRepository) OrphanArchivedFiles(ctx context.Context, limit int64) ([]*ArchivedFile, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cursor, err := r.archivedFiles.Find(
+		ctx,
+		bson.M{"user_ids": bson.M{"$size": 0}},
+		options.Find().SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*ArchivedFile
+	if err := cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// DeleteOrphanArchivedFile удаляет запись, только если у нее так и нет пользователей. false - запись
+// успели взять снова, копию удалять нельзя
+func (r *MongoRepository) DeleteOrphanArchivedFile(ctx context.Context, fileUniqueID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	res, err := r.archivedFiles.DeleteOne(ctx, bson.M{
+		"_id":      fileUniqueID,
+		"user_ids": bson.M{"$size": 0},
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.DeletedCount == 1, nil
+}
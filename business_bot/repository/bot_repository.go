@@ -2,16 +2,24 @@ package repository
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+
+	"ssuspy-common/crypto"
 )
 
 type Bot struct {
 	ID int64 `bson:"_id"`
 
-	Username    string `bson:"username"`
+	Username string `bson:"username"`
+	// SecretToken в базе хранится зашифрованным, репозиторий отдает уже расшифрованный
 	SecretToken string `bson:"secret_token"`
+	TokenHash   string `bson:"token_hash"`
+
+	// Quota - ограничения для пользователей бота, заданные владельцем в creator_bot
+	Quota *Quota `bson:"quota,omitempty"`
 
 	UserID    int64     `bson:"user_id"`
 	CreatedAt time.Time `bson:"created_at"`
@@ -30,6 +38,9 @@ func (r *MongoRepository) BotByID(
 	if err := r.bots.FindOne(ctx, filter).Decode(&bot); err != nil {
 		return nil, err
 	}
+	if err := r.decryptBotToken(&bot); err != nil {
+		return nil, err
+	}
 	return &bot, nil
 }
 
@@ -41,15 +52,76 @@ func (r *MongoRepository) AllBots(
 
 	filter := bson.M{}
 	cursor, err := r.bots.Find(ctx, filter)
-	defer cursor.Close(ctx)
 	if err != nil {
 		return nil, err
 	}
+	defer cursor.Close(ctx)
 
 	var bots []Bot
 	if err := cursor.All(ctx, &bots); err != nil {
 		return nil, err
 	}
 
+	for i := range bots {
+		if err := r.decryptBotToken(&bots[i]); err != nil {
+			return nil, err
+		}
+	}
+
 	return bots, nil
 }
+
+// AllBotIDs - ID всех ботов без токенов, для ребалансировки между репликами
+func (r *MongoRepository) AllBotIDs(
+	ctx context.Context,
+) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	rawIDs, err := r.bots.Distinct(ctx, "_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	botIDs := make([]int64, 0, len(rawIDs))
+	for _, raw := range rawIDs {
+		if botID, ok := distinctInt64(raw); ok {
+			botIDs = append(botIDs, botID)
+		}
+	}
+	return botIDs, nil
+}
+
+// BotUsersCount - сколько пользователей у бота всего и сколько из них сейчас подключили его к бизнес аккаунту
+func (r *MongoRepository) BotUsersCount(
+	ctx context.Context,
+	botID int64,
+) (total int64, connected int64, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	total, err = r.botUsers.CountDocuments(ctx, bson.M{"bot_id": botID})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	connected, err = r.botUsers.CountDocuments(ctx, bson.M{
+		"bot_id": botID,
+		"business_connections": bson.M{
+			"$elemMatch": bson.M{"enabled": true},
+		},
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, connected, nil
+}
+
+func (r *MongoRepository) decryptBotToken(bot *Bot) error {
+	token, err := r.box.Decrypt(bot.SecretToken, crypto.BotAAD(bot.ID))
+	if err != nil {
+		return fmt.Errorf("failed decrypt token of bot %d: %w", bot.ID, err)
+	}
+	bot.SecretToken = token
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	// Добавлен для NewEncoder/NewDecoder
 	"bytes"
 	"errors"
+	"fmt"
 	"reflect"
 
 	"github.com/mymmrac/telego"
@@ -15,6 +16,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 	"go.mongodb.org/mongo-driver/bson/bsonrw"
 	// bsontype больше не нужен напрямую, если используем bson.Type*
+
+	"ssuspy-common/crypto"
 )
 
 // PaidMediaCodec handles encoding/decoding of PaidMedia interface
@@ -393,8 +396,25 @@ func (c *CustomRegistry) LoadMessage(data bson.Raw, message *telego.Message) err
 // Assume CreateCustomRegistry is defined elsewhere.
 // func CreateCustomRegistry() *bson.Registry { /* ... */ }
 
-// The SaveMessage function would remain as previously corrected:
-func (c *CustomRegistry) SaveMessage(message *telego.Message) ([]byte, error) {
+// SaveMessage кодирует сообщение в BSON. Если передан box - сохраняется зашифрованная копия
+// (см. SealMessage), само сообщение не меняется
+func (c *CustomRegistry) SaveMessage(message *telego.Message, box *crypto.Box) ([]byte, error) {
+	raw, err := c.encode(message)
+	if err != nil || box == nil {
+		return raw, err
+	}
+
+	var sealed telego.Message
+	if err := c.LoadMessage(raw, &sealed); err != nil {
+		return nil, err
+	}
+	if err := SealMessage(&sealed, box); err != nil {
+		return nil, fmt.Errorf("failed seal message: %w", err)
+	}
+	return c.encode(&sealed)
+}
+
+func (c *CustomRegistry) encode(message *telego.Message) ([]byte, error) {
 	var buf bytes.Buffer // Import "bytes"
 
 	valueWriter, err := bsonrw.NewBSONValueWriter(&buf)
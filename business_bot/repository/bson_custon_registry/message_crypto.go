@@ -0,0 +1,156 @@
+package custom_registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mymmrac/telego"
+
+	"ssuspy-common/crypto"
+)
+
+// ограничение на случай циклов. Самая глубокая известная вложенность - file_id платного медиа
+// в закрепе внутри ответа, около 13 уровней, поэтому запас берется с избытком
+const maxWalkDepth = 32
+
+var messageType = reflect.TypeOf(telego.Message{})
+
+// sealedEntityType - служебная entity, которой помечается зашифрованный текст. Telegram такой тип
+// не присылает, поэтому, в отличие от префикса в самом тексте, подделать пометку из чата нельзя
+const sealedEntityType = "ssuspy_sealed"
+
+// sealedText - текст вместе с entities, шифруется одним конвертом и кладется в поле text/caption.
+// Вместо entities в документе остается только пометка, иначе по ним можно восстановить ссылки, упоминания и т.д.
+type sealedText struct {
+	Text     string                 `json:"t"`
+	Entities []telego.MessageEntity `json:"e,omitempty"`
+}
+
+// SealMessage шифрует текст, подпись, их entities и все file_id сообщения, включая вложенные
+// (reply, закреп и т.д.). Уже зашифрованные поля не трогает, так что вызывать повторно безопасно
+func SealMessage(message *telego.Message, box *crypto.Box) error {
+	return walk(reflect.ValueOf(message), 0, &messageCipher{box: box, seal: true})
+}
+
+// OpenMessage - обратное к SealMessage, незашифрованные поля оставляет как есть
+func OpenMessage(message *telego.Message, box *crypto.Box) error {
+	return walk(reflect.ValueOf(message), 0, &messageCipher{box: box})
+}
+
+type messageCipher struct {
+	box  *crypto.Box
+	seal bool
+}
+
+func (m *messageCipher) text(text *string, entities *[]telego.MessageEntity) error {
+	if m.seal {
+		if *text == "" || isSealed(*entities) {
+			return nil
+		}
+
+		payload, err := json.Marshal(sealedText{Text: *text, Entities: *entities})
+		if err != nil {
+			return err
+		}
+		sealed, err := m.box.Encrypt(string(payload), "")
+		if err != nil {
+			return err
+		}
+		*text, *entities = sealed, []telego.MessageEntity{{Type: sealedEntityType}}
+		return nil
+	}
+
+	if !isSealed(*entities) {
+		return nil
+	}
+
+	payload, err := m.box.Decrypt(*text, "")
+	if err != nil {
+		return err
+	}
+	var opened sealedText
+	if err := json.Unmarshal([]byte(payload), &opened); err != nil {
+		return fmt.Errorf("failed unmarshal sealed text: %w", err)
+	}
+	*text, *entities = opened.Text, opened.Entities
+	return nil
+}
+
+func isSealed(entities []telego.MessageEntity) bool {
+	return len(entities) == 1 && entities[0].Type == sealedEntityType
+}
+
+// file_id выдает Telegram, двоеточий в них не бывает, поэтому зашифрованный отличается по префиксу
+func (m *messageCipher) fileID(fileID *string) (err error) {
+	switch {
+	case *fileID == "":
+		return nil
+	case m.seal && !crypto.IsEncrypted(*fileID):
+		*fileID, err = m.box.Encrypt(*fileID, "")
+	case !m.seal && crypto.IsEncrypted(*fileID):
+		*fileID, err = m.box.Decrypt(*fileID, "")
+	}
+	return err
+}
+
+func walk(v reflect.Value, depth int, m *messageCipher) error {
+	// пропустить поле нельзя: оно осталось бы в базе в открытом виде
+	if depth > maxWalkDepth {
+		return fmt.Errorf("message is nested deeper than %d levels", maxWalkDepth)
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return walk(v.Elem(), depth+1, m)
+	case reflect.Slice:
+		for i := range v.Len() {
+			if err := walk(v.Index(i), depth+1, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+	default:
+		return nil
+	}
+
+	if v.Type() == messageType && v.CanAddr() {
+		message := v.Addr().Interface().(*telego.Message)
+		if err := m.text(&message.Text, &message.Entities); err != nil {
+			return fmt.Errorf("text: %w", err)
+		}
+		if err := m.text(&message.Caption, &message.CaptionEntities); err != nil {
+			return fmt.Errorf("caption: %w", err)
+		}
+	}
+
+	for i := range v.NumField() {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		value := v.Field(i)
+		if value.Kind() == reflect.String {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "file_id" && value.CanSet() {
+				fileID := value.String()
+				if err := m.fileID(&fileID); err != nil {
+					return fmt.Errorf("file_id: %w", err)
+				}
+				value.SetString(fileID)
+			}
+			continue
+		}
+
+		if err := walk(value, depth+1, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
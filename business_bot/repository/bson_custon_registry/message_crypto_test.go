@@ -0,0 +1,93 @@
+package custom_registry
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/telego"
+
+	"ssuspy-common/crypto"
+)
+
+func TestSealMessage(t *testing.T) {
+	keys, err := crypto.NewKeyring("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := keys.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := CreateCustomRegistry()
+
+	tests := []struct {
+		name    string
+		message *telego.Message
+	}{
+		{
+			name:    "text",
+			message: &telego.Message{MessageID: 1, Text: "hello"},
+		},
+		{
+			name: "text that looks like an envelope",
+			message: &telego.Message{
+				MessageID: 2,
+				Text:      "v1:hello",
+				Entities:  []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 0, Length: 3}},
+			},
+		},
+		{
+			name: "caption and reply that look like an envelope",
+			message: &telego.Message{
+				MessageID:      3,
+				Caption:        "v1:AAAA",
+				Photo:          []telego.PhotoSize{{FileID: "photo", FileUniqueID: "p"}},
+				ReplyToMessage: &telego.Message{MessageID: 1, Text: "v1:"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := registry.SaveMessage(tt.message, box)
+			if err != nil {
+				t.Fatalf("SaveMessage() error = %v", err)
+			}
+			if text := tt.message.Text + tt.message.Caption; strings.Contains(string(raw), text) {
+				t.Errorf("SaveMessage() stored %q in cleartext", text)
+			}
+
+			var loaded telego.Message
+			if err := registry.LoadMessage(raw, &loaded); err != nil {
+				t.Fatalf("LoadMessage() error = %v", err)
+			}
+			if err := OpenMessage(&loaded, box); err != nil {
+				t.Fatalf("OpenMessage() error = %v", err)
+			}
+			if !reflect.DeepEqual(&loaded, tt.message) {
+				t.Errorf("OpenMessage() = %+v, want %+v", &loaded, tt.message)
+			}
+		})
+	}
+}
+
+func TestOpenPlainMessage(t *testing.T) {
+	keys, err := crypto.NewKeyring("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, err := keys.ForUser(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// сообщение, сохраненное до включения шифрования, открывается как есть
+	message := &telego.Message{MessageID: 1, Text: "v1:hello"}
+	if err := OpenMessage(message, box); err != nil {
+		t.Fatalf("OpenMessage() error = %v", err)
+	}
+	if message.Text != "v1:hello" {
+		t.Errorf("OpenMessage() text = %q, want %q", message.Text, "v1:hello")
+	}
+}
@@ -11,9 +11,12 @@ import (
 type ChatResolve struct {
 	ID   int64  `bson:"_id"`
 	Name string `bson:"name"`
+	// UserIDs - пользователи, в чьих бизнес чатах был этот чат
+	UserIDs []int64 `bson:"user_ids,omitempty"`
 }
 
-func (r *MongoRepository) UpdateChatName(ctx context.Context, chatID int64, name string) error {
+// UpdateChatName обновляет имя чата и отмечает, что чат есть у userID
+func (r *MongoRepository) UpdateChatName(ctx context.Context, userID int64, chatID int64, name string) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
@@ -25,6 +28,9 @@ func (r *MongoRepository) UpdateChatName(ctx context.Context, chatID int64, name
 		"$set": bson.M{
 			"name": name,
 		},
+		"$addToSet": bson.M{
+			"user_ids": userID,
+		},
 	}
 
 	opts := options.Update().SetUpsert(true)
@@ -48,3 +54,35 @@ func (r *MongoRepository) FindChatName(
 	}
 	return &chat, nil
 }
+
+// UserChats - чаты пользователя по имени, для настроек правил чатов
+func (r *MongoRepository) UserChats(ctx context.Context, userID int64, offset int, limit int) ([]*ChatResolve, *PaginationAnswer, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetProjection(bson.M{"name": 1}).
+		SetSort(bson.D{{Key: "name", Value: 1}, {Key: "_id", Value: 1}}).
+		SetSkip(int64(offset)).
+		SetLimit(int64(limit + 1))
+
+	cursor, err := r.chatResolve.Find(ctx, bson.M{"user_ids": userID}, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var chats []*ChatResolve
+	if err := cursor.All(ctx, &chats); err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &PaginationAnswer{
+		Backward: offset > 0,
+	}
+	if len(chats) > limit {
+		pagination.Forward = true
+		chats = chats[:limit]
+	}
+	return chats, pagination, nil
+}
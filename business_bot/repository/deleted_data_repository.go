@@ -12,13 +12,13 @@ type DataDeleted struct {
 	ID            int64 `bson:"_id"`
 	MessageIDs    []int `bson:"message_ids"`
 	UserID        int64 `bson:"user_id"`
-	MessagesCount uint8 `bson:"messages_count"`
-	FilesCount    uint8 `bson:"files_count"`
+	MessagesCount int   `bson:"messages_count"`
+	FilesCount    int   `bson:"files_count"`
 
 	CreatedAt time.Time `bson:"created_at"`
 }
 
-func (r *MongoRepository) SetDataDeleted(ctx context.Context, userID int64, messageIDs []int, messagesCount uint8, filesCount uint8) (int64, error) {
+func (r *MongoRepository) SetDataDeleted(ctx context.Context, userID int64, messageIDs []int, messagesCount int, filesCount int) (int64, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
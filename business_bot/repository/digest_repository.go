@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DigestEvent - уведомление, отложенное до сводки. Текст не хранится: при отправке сообщения
+// заново берутся из базы, поэтому в событии нет содержимого переписки
+type DigestEvent struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty"`
+	UserID   int64              `bson:"user_id"`
+	BotID    int64              `bson:"bot_id"`
+	ChatID   int64              `bson:"chat_id"`
+	ChatName string             `bson:"chat_name"`
+	// Kind - DIGEST_KIND_*
+	Kind string `bson:"kind"`
+
+	// MessageIDs - удаленные сообщения
+	MessageIDs []int `bson:"message_ids,omitempty"`
+	// EditedDataID - callback data правки, по ней находятся обе версии сообщения
+	EditedDataID int64 `bson:"edited_data_id,omitempty"`
+
+	DueAt time.Time `bson:"due_at"`
+	// Claim - метка реплики, которая отправляет сводку, пустая - событие еще ждет
+	Claim     string    `bson:"claim,omitempty"`
+	ClaimedAt time.Time `bson:"claimed_at,omitempty"`
+	// Attempts - сколько раз сводку с этим событием не удалось отправить
+	Attempts int `bson:"attempts,omitempty"`
+
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func (r *MongoRepository) AddDigestEvent(ctx context.Context, event *DigestEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	event.CreatedAt = time.Now()
+	_, err := r.digestEvents.InsertOne(ctx, event)
+	return err
+}
+
+// ClaimDigestEvents помечает меткой claim события, которым пора уйти, и возвращает их по порядку.
+// События, взятые раньше claimedBefore, считаются брошенными упавшей репликой и берутся снова
+func (r *MongoRepository) ClaimDigestEvents(ctx context.Context, claim string, now time.Time, claimedBefore time.Time) ([]*DigestEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	_, err := r.digestEvents.UpdateMany(
+		ctx,
+		bson.M{
+			"due_at": bson.M{"$lte": now},
+			"$or": bson.A{
+				bson.M{"claim": bson.M{"$exists": false}},
+				bson.M{"claimed_at": bson.M{"$lt": claimedBefore}},
+			},
+		},
+		bson.M{"$set": bson.M{"claim": claim, "claimed_at": now}},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor, err := r.digestEvents.Find(
+		ctx,
+		bson.M{"claim": claim},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var events []*DigestEvent
+	if err := cursor.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// DeleteDigestEvents удаляет отправленные события и те, что больше не нужно отправлять
+func (r *MongoRepository) DeleteDigestEvents(ctx context.Context, ids []primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.digestEvents.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	return err
+}
+
+// RetryDigestEvents снимает метку с событий, сводку которых не удалось отправить, и откладывает их до dueAt
+func (r *MongoRepository) RetryDigestEvents(ctx context.Context, ids []primitive.ObjectID, dueAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.digestEvents.UpdateMany(
+		ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{
+			"$set":   bson.M{"due_at": dueAt},
+			"$unset": bson.M{"claim": "", "claimed_at": ""},
+			"$inc":   bson.M{"attempts": 1},
+		},
+	)
+	return err
+}
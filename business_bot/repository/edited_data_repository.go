@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/mymmrac/telego"
 	"go.mongodb.org/mongo-driver/bson"
 )
 
@@ -63,3 +64,24 @@ func (r *MongoRepository) GetDataEdited(ctx context.Context, userId int64, id in
 
 	return &data, nil
 }
+
+// Versions находит среди всех версий сообщения старую и новую из правки, nil - версии нет в базе
+func (d *DataEdited) Versions(msgs []*telego.Message) (oldMsg *telego.Message, newMsg *telego.Message) {
+	for _, msg := range msgs {
+		if oldMsg == nil {
+			if (d.OldDateIsEdit && msg.EditDate == d.OldDate) ||
+				(!d.OldDateIsEdit && msg.Date == d.OldDate && msg.EditDate == 0) {
+				oldMsg = msg
+			}
+		}
+
+		if newMsg == nil && msg.EditDate == d.NewDate {
+			newMsg = msg
+		}
+
+		if oldMsg != nil && newMsg != nil {
+			break
+		}
+	}
+	return oldMsg, newMsg
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ForgetCount struct {
+	Collection string
+	Deleted    int64
+}
+
+type ForgetReport struct {
+	Counts []ForgetCount
+	// ChatIDs - чаты, сообщения которых были удалены, нужны для очистки redis
+	ChatIDs []int64
+}
+
+// ForgetUser удаляет все, что хранится о пользователе: сообщения его подключений во всех ботах,
+// файлы, callback data, имена чатов, которые больше никому не нужны, вебхук и сами записи users/bot_users.
+// Из архива медиа пользователь только убирается, копии без пользователей потом удаляет archive.RunCleanup.
+// При добавлении новых коллекций с данными пользователя их нужно добавить сюда
+func (r *MongoRepository) ForgetUser(ctx context.Context, userID int64) (*ForgetReport, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	var botUsers []BotUser
+	cursor, err := r.botUsers.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed find bot users: %w", err)
+	}
+	if err := cursor.All(ctx, &botUsers); err != nil {
+		return nil, fmt.Errorf("failed decode bot users: %w", err)
+	}
+
+	connectionIDs := []string{}
+	for _, botUser := range botUsers {
+		connectionIDs = append(connectionIDs, botUser.GetUserCurrentConnectionIDs()...)
+	}
+
+	report := &ForgetReport{ChatIDs: []int64{}}
+
+	rawChatIDs, err := r.telegramMessages.Distinct(ctx, "message.chat.id", bson.M{
+		"message.business_connection_id": bson.M{"$in": connectionIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed distinct chats: %w", err)
+	}
+	for _, raw := range rawChatIDs {
+		if chatID, ok := distinctInt64(raw); ok {
+			report.ChatIDs = append(report.ChatIDs, chatID)
+		}
+	}
+
+	deleteMany := func(collection *mongo.Collection, filter bson.M) error {
+		res, err := collection.DeleteMany(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("failed delete from %s: %w", collection.Name(), err)
+		}
+		report.Counts = append(report.Counts, ForgetCount{
+			Collection: collection.Name(),
+			Deleted:    res.DeletedCount,
+		})
+		return nil
+	}
+
+	if err := deleteMany(r.telegramMessages, bson.M{"message.business_connection_id": bson.M{"$in": connectionIDs}}); err != nil {
+		return nil, err
+	}
+	if err := deleteMany(r.filesExists, bson.M{"userId": userID}); err != nil {
+		return nil, err
+	}
+	for _, collection := range []*mongo.Collection{
+		r.callbackDataDeleted,
+		r.callbackDataEdited,
+		r.callbackDataSearch,
+		r.callbackDataHistory,
+		r.digestEvents,
+	} {
+		if err := deleteMany(collection, bson.M{"user_id": userID}); err != nil {
+			return nil, err
+		}
+	}
+
+	// имена чатов общие, удаляем только те, по которым не осталось чужих сообщений
+	rawUsedChatIDs, err := r.telegramMessages.Distinct(ctx, "message.chat.id", bson.M{
+		"message.chat.id": bson.M{"$in": report.ChatIDs},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed distinct used chats: %w", err)
+	}
+	usedChatIDs := make(map[int64]bool, len(rawUsedChatIDs))
+	for _, raw := range rawUsedChatIDs {
+		if chatID, ok := distinctInt64(raw); ok {
+			usedChatIDs[chatID] = true
+		}
+	}
+	unusedChatIDs := []int64{userID}
+	for _, chatID := range report.ChatIDs {
+		if !usedChatIDs[chatID] {
+			unusedChatIDs = append(unusedChatIDs, chatID)
+		}
+	}
+	if err := deleteMany(r.chatResolve, bson.M{"_id": bson.M{"$in": unusedChatIDs}}); err != nil {
+		return nil, err
+	}
+	if _, err := r.chatResolve.UpdateMany(
+		ctx,
+		bson.M{"user_ids": userID},
+		bson.M{"$pull": bson.M{"user_ids": userID}},
+	); err != nil {
+		return nil, fmt.Errorf("failed pull user from %s: %w", r.chatResolve.Name(), err)
+	}
+
+	if err := deleteMany(r.webhooks, bson.M{"_id": userID}); err != nil {
+		return nil, err
+	}
+
+	res, err := r.archivedFiles.UpdateMany(
+		ctx,
+		bson.M{"user_ids": userID},
+		bson.M{"$pull": bson.M{"user_ids": userID}},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed pull user from %s: %w", r.archivedFiles.Name(), err)
+	}
+	report.Counts = append(report.Counts, ForgetCount{
+		Collection: r.archivedFiles.Name(),
+		Deleted:    res.ModifiedCount,
+	})
+
+	if err := deleteMany(r.botUsers, bson.M{"user_id": userID}); err != nil {
+		return nil, err
+	}
+	if err := deleteMany(r.users, bson.M{"_id": userID}); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// distinctInt64 - Distinct отдает числа в том виде, в каком они лежат в документе
+func distinctInt64(raw any) (int64, bool) {
+	switch v := raw.(type) {
+	case int64:
+		return v, true
+	case int32:
+		return int64(v), true
+	case float64:
+		return int64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type DataHistory struct {
+	ID          int64   `bson:"_id"`
+	UserID      int64   `bson:"user_id"`
+	ChatIDs     []int64 `bson:"chat_ids"`
+	ChatsOffset int     `bson:"chats_offset"`
+
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func (r *MongoRepository) SetDataHistory(ctx context.Context, userID int64, chatIDs []int64, chatsOffset int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := r.GetNextSequence(ctx, r.callbackDataHistory.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed get next seq: %w", err)
+	}
+
+	row := DataHistory{
+		ID:          id.Value,
+		UserID:      userID,
+		ChatIDs:     chatIDs,
+		ChatsOffset: chatsOffset,
+		CreatedAt:   time.Now(),
+	}
+
+	_, err = r.callbackDataHistory.InsertOne(ctx, row)
+	return id.Value, err
+}
+
+func (r *MongoRepository) GetDataHistory(ctx context.Context, userID int64, id int64) (*DataHistory, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "user_id": userID}
+
+	var data DataHistory
+	if err := r.callbackDataHistory.FindOne(ctx, filter).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
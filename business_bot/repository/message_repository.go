@@ -10,11 +10,18 @@ import (
 	"github.com/rs/zerolog/log"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	custom_registry "ssuspy-bot/repository/bson_custon_registry"
+	"ssuspy-common/crypto"
 )
 
 type internalMessage struct {
 	InternalID int64    `bson:"_id"`
 	Message    bson.Raw `bson:"message"`
+	// Size и MediaSize учтены в usage пользователя, при удалении вычитаются обратно
+	Size      int64 `bson:"size"`
+	MediaSize int64 `bson:"media_size"`
 }
 
 type GetMessageOptions struct {
@@ -28,6 +35,28 @@ type GetMessagesOptions struct {
 	MessageIDs    []int
 	ConnectionIDs []string
 	WithEdits     bool
+	// AllMessages - все сообщения чата без фильтра по MessageIDs, по дате (сначала новые)
+	AllMessages bool
+	Offset      int
+	Limit       int
+}
+
+type StreamMessagesOptions struct {
+	// ChatID - 0 для всех чатов подключений
+	ChatID        int64
+	ConnectionIDs []string
+}
+
+type HistoryChat struct {
+	ID            int64  `bson:"_id"`
+	Name          string `bson:"name"`
+	MessagesCount int    `bson:"messages_count"`
+	LastDate      int64  `bson:"last_date"`
+}
+
+type SearchMessagesOptions struct {
+	Query         string
+	ConnectionIDs []string
 	Offset        int
 	Limit         int
 }
@@ -37,11 +66,19 @@ type PaginationAnswer struct {
 	Backward bool
 }
 
-func (r *MongoRepository) SaveMessage(ctx context.Context, message *telego.Message) error {
+// SaveMessage сохраняет сообщение, при включенном шифровании - зашифрованным ключом userID
+// (владельца бизнес подключения), и добавляет его к usage пользователя бота.
+// mediaSize - сумма размеров файлов сообщения, считается вызывающим (utils.FilesSize)
+func (r *MongoRepository) SaveMessage(ctx context.Context, userID int64, message *telego.Message, mediaSize int64) error {
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	msgBytes, err := r.customRegistry.SaveMessage(message)
+	box, err := r.MessageBox(userID)
+	if err != nil {
+		return err
+	}
+
+	msgBytes, err := r.customRegistry.SaveMessage(message, box)
 	if err != nil {
 		return fmt.Errorf("failed to marshal message to BSON: %w", err)
 	}
@@ -55,10 +92,18 @@ func (r *MongoRepository) SaveMessage(ctx context.Context, message *telego.Messa
 	doc := internalMessage{
 		InternalID: _id.Value,
 		Message:    msgRaw,
+		Size:       int64(len(msgBytes)),
+		MediaSize:  mediaSize,
 	}
 
-	_, err = r.telegramMessages.InsertOne(ctx, doc)
-	return err
+	if _, err = r.telegramMessages.InsertOne(ctx, doc); err != nil {
+		return err
+	}
+	return r.incUsage(ctx, message.BusinessConnectionID, Usage{
+		Messages:     1,
+		MessageBytes: doc.Size,
+		MediaBytes:   doc.MediaSize,
+	})
 }
 
 func (r *MongoRepository) GetMessage(
@@ -99,7 +144,7 @@ func (r *MongoRepository) GetMessages(ctx context.Context, options *GetMessagesO
 
 	if len(orConditions) > 0 {
 		matchConditions = append(matchConditions, bson.E{Key: "$or", Value: orConditions})
-	} else {
+	} else if !options.AllMessages {
 		matchConditions = append(matchConditions, bson.E{Key: "$or", Value: bson.A{bson.D{{Key: "_id", Value: nil}}}}) // Matches no documents
 	}
 
@@ -125,8 +170,16 @@ func (r *MongoRepository) GetMessages(ctx context.Context, options *GetMessagesO
 				{Key: "doc", Value: bson.D{{Key: "$first", Value: "$$ROOT"}}},
 			}}},
 			bson.D{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$doc"}}}},
-			bson.D{{Key: "$sort", Value: bson.D{{Key: "message.message_id", Value: 1}}}},
 		)
+
+		if options.AllMessages {
+			pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{
+				{Key: "message.date", Value: -1},
+				{Key: "message.message_id", Value: -1},
+			}}})
+		} else {
+			pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "message.message_id", Value: 1}}}})
+		}
 	}
 
 	if options.Offset > 0 {
@@ -148,6 +201,183 @@ func (r *MongoRepository) GetMessages(ctx context.Context, options *GetMessagesO
 		return nil, nil, err
 	}
 
+	messages, pagination := r.loadMessages(results, options.Offset, options.Limit)
+	return messages, pagination, nil
+}
+
+// SearchMessages ищет по text индексу среди сообщений указанных подключений,
+// из каждой цепочки правок берется последняя подходящая версия, сначала новые
+func (r *MongoRepository) SearchMessages(ctx context.Context, options *SearchMessagesOptions) ([]*telego.Message, *PaginationAnswer, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{
+			{Key: "$text", Value: bson.D{{Key: "$search", Value: options.Query}}},
+			{Key: "message.business_connection_id", Value: bson.D{{Key: "$in", Value: options.ConnectionIDs}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "message.chat.id", Value: 1},
+			{Key: "message.message_id", Value: 1},
+			{Key: "message.edit_date", Value: -1},
+			{Key: "message.date", Value: -1},
+		}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "chat", Value: "$message.chat.id"},
+				{Key: "message", Value: "$message.message_id"},
+			}},
+			{Key: "doc", Value: bson.D{{Key: "$first", Value: "$$ROOT"}}},
+		}}},
+		bson.D{{Key: "$replaceRoot", Value: bson.D{{Key: "newRoot", Value: "$doc"}}}},
+		bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "message.date", Value: -1},
+			{Key: "_id", Value: -1},
+		}}},
+	}
+
+	if options.Offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: options.Offset}})
+	}
+
+	if options.Limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: options.Limit + 1}})
+	}
+
+	cursor, err := r.telegramMessages.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to search messages: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var results []internalMessage
+	if err := cursor.All(ctxTimeout, &results); err != nil {
+		return nil, nil, err
+	}
+
+	messages, pagination := r.loadMessages(results, options.Offset, options.Limit)
+	return messages, pagination, nil
+}
+
+// GetHistoryChats возвращает чаты, в которых есть сохраненные сообщения указанных подключений,
+// сначала чаты с самыми свежими сообщениями. Имена берутся из chats_resolve
+func (r *MongoRepository) GetHistoryChats(ctx context.Context, connectionIDs []string, offset int, limit int) ([]*HistoryChat, *PaginationAnswer, error) {
+	ctxTimeout, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipeline := mongo.Pipeline{
+		bson.D{{Key: "$match", Value: bson.D{
+			{Key: "message.business_connection_id", Value: bson.D{{Key: "$in", Value: connectionIDs}}},
+		}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "chat", Value: "$message.chat.id"},
+				{Key: "message", Value: "$message.message_id"},
+			}},
+			{Key: "date", Value: bson.D{{Key: "$max", Value: "$message.date"}}},
+		}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$_id.chat"},
+			{Key: "messages_count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "last_date", Value: bson.D{{Key: "$max", Value: "$date"}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "last_date", Value: -1},
+			{Key: "_id", Value: 1},
+		}}},
+	}
+
+	if offset > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$skip", Value: offset}})
+	}
+
+	if limit > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit + 1}})
+	}
+
+	pipeline = append(
+		pipeline,
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: r.chatResolve.Name()},
+			{Key: "localField", Value: "_id"},
+			{Key: "foreignField", Value: "_id"},
+			{Key: "as", Value: "resolve"},
+		}}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "name", Value: bson.D{{Key: "$first", Value: "$resolve.name"}}},
+		}}},
+		bson.D{{Key: "$unset", Value: "resolve"}},
+	)
+
+	cursor, err := r.telegramMessages.Aggregate(ctxTimeout, pipeline)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to aggregate history chats: %w", err)
+	}
+	defer cursor.Close(ctxTimeout)
+
+	var chats []*HistoryChat
+	if err := cursor.All(ctxTimeout, &chats); err != nil {
+		return nil, nil, err
+	}
+
+	pagination := &PaginationAnswer{
+		Backward: offset > 0,
+	}
+	if limit > 0 && len(chats) > limit {
+		pagination.Forward = true
+		chats = chats[:len(chats)-1]
+	}
+
+	return chats, pagination, nil
+}
+
+// StreamMessages отдает в fn все версии сообщений (вместе с правками) по порядку:
+// чат, message_id, затем от оригинала к последней правке. Используется для экспорта,
+// поэтому без общего таймаута - ограничивает только переданный ctx
+func (r *MongoRepository) StreamMessages(ctx context.Context, opts *StreamMessagesOptions, fn func(msg *telego.Message) error) error {
+	filter := bson.D{
+		{Key: "message.business_connection_id", Value: bson.D{{Key: "$in", Value: opts.ConnectionIDs}}},
+	}
+	if opts.ChatID != 0 {
+		filter = append(filter, bson.E{Key: "message.chat.id", Value: opts.ChatID})
+	}
+
+	findOptions := options.Find().
+		SetSort(bson.D{
+			{Key: "message.chat.id", Value: 1},
+			{Key: "message.message_id", Value: 1},
+			{Key: "message.date", Value: 1},
+			{Key: "message.edit_date", Value: 1},
+		}).
+		SetAllowDiskUse(true)
+
+	cursor, err := r.telegramMessages.Find(ctx, filter, findOptions)
+	if err != nil {
+		return fmt.Errorf("failed to find messages: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var rdoc internalMessage
+		if err := cursor.Decode(&rdoc); err != nil {
+			return err
+		}
+
+		var msg telego.Message
+		if err := r.customRegistry.LoadMessage(rdoc.Message, &msg); err != nil {
+			log.Warn().Err(err).Msg("fail decode message")
+			continue
+		}
+
+		if err := fn(&msg); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (r *MongoRepository) loadMessages(results []internalMessage, offset int, limit int) ([]*telego.Message, *PaginationAnswer) {
 	var messages []*telego.Message
 	for _, rdoc := range results {
 		var msg telego.Message
@@ -159,12 +389,36 @@ func (r *MongoRepository) GetMessages(ctx context.Context, options *GetMessagesO
 	}
 
 	pagination := &PaginationAnswer{
-		Backward: options.Offset > 0,
+		Backward: offset > 0,
 	}
-	if options.Limit > 0 && len(messages) > options.Limit {
+	if limit > 0 && len(messages) > limit {
 		pagination.Forward = true
 		messages = messages[:len(messages)-1]
 	}
 
-	return messages, pagination, nil
+	return messages, pagination
+}
+
+// OpenMessages расшифровывает сообщения, полученные из репозитория. Get* отдают их как есть,
+// расшифровка делается в обработчиках, которым содержимое действительно нужно
+func (r *MongoRepository) OpenMessages(userID int64, messages ...*telego.Message) error {
+	box, err := r.MessageBox(userID)
+	if err != nil || box == nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := custom_registry.OpenMessage(message, box); err != nil {
+			return fmt.Errorf("failed open message %d: %w", message.MessageID, err)
+		}
+	}
+	return nil
+}
+
+// MessageBox - ключ сообщений пользователя, nil, если шифрование сообщений выключено
+func (r *MongoRepository) MessageBox(userID int64) (*crypto.Box, error) {
+	if r.messageKeys == nil {
+		return nil, nil
+	}
+	return r.messageKeys.ForUser(userID)
 }
@@ -12,6 +12,7 @@ import (
 	"ssuspy-bot/consts"
 	"ssuspy-bot/migrations"
 	custom_registry "ssuspy-bot/repository/bson_custon_registry"
+	"ssuspy-common/crypto"
 )
 
 type MongoRepository struct {
@@ -20,21 +21,29 @@ type MongoRepository struct {
 	users               *mongo.Collection
 	callbackDataDeleted *mongo.Collection
 	callbackDataEdited  *mongo.Collection
+	callbackDataSearch  *mongo.Collection
+	callbackDataHistory *mongo.Collection
 	filesExists         *mongo.Collection
 	chatResolve         *mongo.Collection
 	bots                *mongo.Collection
 	botUsers            *mongo.Collection
+	webhooks            *mongo.Collection
+	archivedFiles       *mongo.Collection
+	digestEvents        *mongo.Collection
 	counters            *mongo.Collection
 	migrations          *mongo.Collection
 
 	customRegistry *custom_registry.CustomRegistry
+	box            *crypto.Box
+	// messageKeys - nil, если шифрование сообщений выключено
+	messageKeys *crypto.Keyring
 }
 
 type Sequence struct {
 	Value int64 `bson:"value"`
 }
 
-func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
+func NewMongoRepository(cfg *config.MongoConfig, box *crypto.Box, messageKeys *crypto.Keyring) (*MongoRepository, error) {
 	uri := cfg.BuildMongoURI()
 
 	customRegistry := custom_registry.CreateCustomRegistry()
@@ -84,6 +93,29 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 			},
 			Options: options.Index().SetName("ChatConn_MsgId"),
 		},
+		{
+			Keys: bson.D{
+				{Key: "message.business_connection_id", Value: 1},
+				{Key: "message.chat.id", Value: 1},
+				{Key: "message.date", Value: -1},
+			},
+			Options: options.Index().SetName("Conn_ChatId_Date"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "message.business_connection_id", Value: 1},
+				{Key: "message.date", Value: 1},
+			},
+			Options: options.Index().SetName("Conn_Date"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "message.text", Value: "text"},
+				{Key: "message.caption", Value: "text"},
+			},
+			// "none" - без стемминга, в базе сообщения на разных языках
+			Options: options.Index().SetName("Text_Caption").SetDefaultLanguage("none"),
+		},
 	}
 	_, err = telegramMessages.Indexes().CreateMany(ctx, indexes)
 	if err != nil {
@@ -107,6 +139,16 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 	if err != nil {
 		return nil, err
 	}
+	callbackDataSearchCollection := db.Collection("callback_data_search")
+	_, err = callbackDataSearchCollection.Indexes().CreateOne(ctx, idxTTLMonth)
+	if err != nil {
+		return nil, err
+	}
+	callbackDataHistoryCollection := db.Collection("callback_data_history")
+	_, err = callbackDataHistoryCollection.Indexes().CreateOne(ctx, idxTTLMonth)
+	if err != nil {
+		return nil, err
+	}
 	filesExistsCollection := db.Collection("files_exists")
 	idxModel := mongo.IndexModel{
 		Keys: bson.D{
@@ -122,6 +164,17 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 	}
 
 	chatResolveCollection := db.Collection("chats_resolve")
+	_, err = chatResolveCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_ids", Value: 1},
+			{Key: "name", Value: 1},
+		},
+		Options: options.Index().SetName("UserIds_Name"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	botsCollection := db.Collection("bots")
 	botUsersCollection := db.Collection("bot_users")
 	idxModel = mongo.IndexModel{
@@ -133,6 +186,35 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 	}
 	_, err = botUsersCollection.Indexes().CreateOne(ctx, idxModel)
 
+	webhooksCollection := db.Collection("webhooks")
+	archivedFilesCollection := db.Collection("archived_files")
+	_, err = archivedFilesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "user_ids", Value: 1},
+		},
+		Options: options.Index().SetName("UserIds"),
+	})
+	if err != nil {
+		return nil, err
+	}
+	digestEventsCollection := db.Collection("digest_events")
+	_, err = digestEventsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "due_at", Value: 1},
+			},
+			Options: options.Index().SetName("DueAt"),
+		},
+		{
+			Keys: bson.D{
+				{Key: "claim", Value: 1},
+			},
+			Options: options.Index().SetName("Claim").SetSparse(true),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
 	countersCollection := db.Collection("counters")
 	migrationsCollection := db.Collection("migrations")
 
@@ -142,14 +224,21 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 		users:               userCollection,
 		callbackDataDeleted: callbackDataDeletedCollection,
 		callbackDataEdited:  callbackDataEditedCollection,
+		callbackDataSearch:  callbackDataSearchCollection,
+		callbackDataHistory: callbackDataHistoryCollection,
 		filesExists:         filesExistsCollection,
 		chatResolve:         chatResolveCollection,
 		bots:                botsCollection,
 		botUsers:            botUsersCollection,
+		webhooks:            webhooksCollection,
+		archivedFiles:       archivedFilesCollection,
+		digestEvents:        digestEventsCollection,
 		counters:            countersCollection,
 		migrations:          migrationsCollection,
 
 		customRegistry: customRegistry,
+		box:            box,
+		messageKeys:    messageKeys,
 	}
 
 	jsonToBsonMessages := "json_to_bson_messages"
@@ -188,6 +277,65 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 		}
 	}
 
+	// миграции ниже идут по всей коллекции и переживают ctx подключения,
+	// поэтому проверка и отметка делаются со своим таймаутом
+	encryptBotTokens := "encrypt_bot_tokens"
+	EncryptBotTokensIsNeeded, err := repository.MigrationIsNeeded(context.Background(), encryptBotTokens)
+	if err != nil {
+		return nil, err
+	}
+	if EncryptBotTokensIsNeeded {
+		if err := migrations.DoEncryptBotTokensMigrate(context.Background(), repository.bots, box); err != nil {
+			return nil, err
+		}
+		if err := repository.ApplyMigration(context.Background(), encryptBotTokens); err != nil {
+			return nil, err
+		}
+	}
+
+	bindEncryptedSecrets := "bind_encrypted_secrets"
+	BindEncryptedSecretsIsNeeded, err := repository.MigrationIsNeeded(context.Background(), bindEncryptedSecrets)
+	if err != nil {
+		return nil, err
+	}
+	if BindEncryptedSecretsIsNeeded {
+		if err := migrations.DoBindEncryptedSecretsMigrate(context.Background(), repository.bots, repository.webhooks, box); err != nil {
+			return nil, err
+		}
+		if err := repository.ApplyMigration(context.Background(), bindEncryptedSecrets); err != nil {
+			return nil, err
+		}
+	}
+
+	chatResolveUsers := "chat_resolve_users"
+	ChatResolveUsersIsNeeded, err := repository.MigrationIsNeeded(context.Background(), chatResolveUsers)
+	if err != nil {
+		return nil, err
+	}
+	if ChatResolveUsersIsNeeded {
+		if err := migrations.DoChatResolveUsersMigrate(context.Background(), telegramMessages, botUsersCollection, chatResolveCollection); err != nil {
+			return nil, err
+		}
+		if err := repository.ApplyMigration(context.Background(), chatResolveUsers); err != nil {
+			return nil, err
+		}
+	}
+
+	// миграция нужна только после включения шифрования, до этого не отмечаем ее примененной
+	encryptMessages := "encrypt_messages"
+	EncryptMessagesIsNeeded, err := repository.MigrationIsNeeded(context.Background(), encryptMessages)
+	if err != nil {
+		return nil, err
+	}
+	if EncryptMessagesIsNeeded && messageKeys != nil {
+		if err := migrations.DoEncryptMessagesMigrate(context.Background(), telegramMessages, botUsersCollection, customRegistry, messageKeys); err != nil {
+			return nil, err
+		}
+		if err := repository.ApplyMigration(context.Background(), encryptMessages); err != nil {
+			return nil, err
+		}
+	}
+
 	return &repository, nil
 }
 
@@ -204,6 +352,10 @@ func (r *MongoRepository) GetNextSequence(ctx context.Context, name string) (*Se
 	return &result, nil
 }
 
+func (r *MongoRepository) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx, nil)
+}
+
 func (r *MongoRepository) Disconnect(ctx context.Context) error {
 	if r.client == nil {
 		return nil
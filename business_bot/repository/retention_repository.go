@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type RetentionUser struct {
+	UserID        int64    `bson:"_id"`
+	RetentionDays int      `bson:"retention_days"`
+	ConnectionIDs []string `bson:"connection_ids"`
+}
+
+// UsersWithRetention возвращает пользователей с ограниченным сроком хранения
+// и все ID их бизнес подключений во всех ботах
+func (r *MongoRepository) UsersWithRetention(ctx context.Context) ([]*RetentionUser, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"settings.retention_days": bson.M{"$gt": 0},
+			},
+		},
+		{
+			"$lookup": bson.M{
+				"from":         r.botUsers.Name(),
+				"localField":   "_id",
+				"foreignField": "user_id",
+				"as":           "bot_users",
+			},
+		},
+		{
+			"$project": bson.M{
+				"retention_days": "$settings.retention_days",
+				"connection_ids": bson.M{
+					"$reduce": bson.M{
+						"input":        "$bot_users.business_connections.id",
+						"initialValue": bson.A{},
+						"in":           bson.M{"$concatArrays": bson.A{"$$value", "$$this"}},
+					},
+				},
+			},
+		},
+	}
+
+	cursor, err := r.users.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*RetentionUser
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// DeleteMessagesBefore удаляет одну пачку сообщений подключений, отправленных раньше before.
+// Возвращает сколько удалено, 0 - удалять больше нечего
+func (r *MongoRepository) DeleteMessagesBefore(ctx context.Context, connectionIDs []string, before time.Time, batchSize int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{
+		"message.business_connection_id": bson.M{"$in": connectionIDs},
+		"message.date":                   bson.M{"$lt": before.Unix()},
+	}
+
+	opts := options.Find().
+		SetProjection(storedMessageProjection).
+		SetLimit(int64(batchSize))
+
+	cursor, err := r.telegramMessages.Find(ctx, filter, opts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []*storedMessage
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, err
+	}
+	return r.deleteStoredMessages(ctx, rows)
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type DataSearch struct {
+	ID     int64  `bson:"_id"`
+	UserID int64  `bson:"user_id"`
+	Query  string `bson:"query"`
+
+	CreatedAt time.Time `bson:"created_at"`
+}
+
+func (r *MongoRepository) SetDataSearch(ctx context.Context, userID int64, query string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	id, err := r.GetNextSequence(ctx, r.callbackDataSearch.Name())
+	if err != nil {
+		return 0, fmt.Errorf("failed get next seq: %w", err)
+	}
+
+	row := DataSearch{
+		ID:        id.Value,
+		UserID:    userID,
+		Query:     query,
+		CreatedAt: time.Now(),
+	}
+
+	_, err = r.callbackDataSearch.InsertOne(ctx, row)
+	return id.Value, err
+}
+
+func (r *MongoRepository) GetDataSearch(ctx context.Context, userID int64, id int64) (*DataSearch, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"_id": id, "user_id": userID}
+
+	var data DataSearch
+	if err := r.callbackDataSearch.FindOne(ctx, filter).Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return &data, nil
+}
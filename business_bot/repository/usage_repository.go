@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Usage - сколько пользователь хранит в одном боте. Считается с момента появления учета,
+// сообщения, сохраненные раньше, в нем не видны
+type Usage struct {
+	Messages     int64 `bson:"messages"`
+	MessageBytes int64 `bson:"message_bytes"`
+	// MediaBytes - сумма file_size медиа в сообщениях (у платных медиа всех файлов), сами файлы хранит Telegram или архив
+	MediaBytes int64 `bson:"media_bytes"`
+}
+
+// Quota - ограничения usage, 0 - без ограничения
+type Quota struct {
+	MaxMessages     int64  `bson:"max_messages,omitempty"`
+	MaxMessageBytes int64  `bson:"max_message_bytes,omitempty"`
+	MaxMediaBytes   int64  `bson:"max_media_bytes,omitempty"`
+	Policy          string `bson:"policy,omitempty"`
+}
+
+// Exceeded - квота заполнена и новое сообщение в нее не помещается
+func (q *Quota) Exceeded(usage Usage) bool {
+	return q.MaxMessages > 0 && usage.Messages >= q.MaxMessages ||
+		q.MaxMessageBytes > 0 && usage.MessageBytes >= q.MaxMessageBytes ||
+		q.MaxMediaBytes > 0 && usage.MediaBytes >= q.MaxMediaBytes
+}
+
+type storedMessage struct {
+	ID        int64 `bson:"_id"`
+	Size      int64 `bson:"size"`
+	MediaSize int64 `bson:"media_size"`
+	Message   struct {
+		BusinessConnectionID string `bson:"business_connection_id"`
+	} `bson:"message"`
+}
+
+var storedMessageProjection = bson.M{
+	"_id":                            1,
+	"size":                           1,
+	"media_size":                     1,
+	"message.business_connection_id": 1,
+}
+
+// BotQuota - ограничения, заданные владельцем бота, nil - не заданы
+func (r *MongoRepository) BotQuota(ctx context.Context, botID int64) (*Quota, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var bot struct {
+		Quota *Quota `bson:"quota"`
+	}
+	err := r.bots.FindOne(
+		ctx,
+		bson.M{"_id": botID},
+		options.FindOne().SetProjection(bson.M{"quota": 1}),
+	).Decode(&bot)
+	if err != nil {
+		return nil, err
+	}
+	return bot.Quota, nil
+}
+
+// DropOldestMessages удаляет пачку самых старых сообщений подключений.
+// Возвращает сколько удалено, 0 - удалять больше нечего
+func (r *MongoRepository) DropOldestMessages(ctx context.Context, connectionIDs []string, batchSize int) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	opts := options.Find().
+		SetProjection(storedMessageProjection).
+		SetSort(bson.D{{Key: "message.date", Value: 1}}).
+		SetLimit(int64(batchSize))
+
+	cursor, err := r.telegramMessages.Find(
+		ctx,
+		bson.M{"message.business_connection_id": bson.M{"$in": connectionIDs}},
+		opts,
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var rows []*storedMessage
+	if err := cursor.All(ctx, &rows); err != nil {
+		return 0, err
+	}
+	return r.deleteStoredMessages(ctx, rows)
+}
+
+// deleteStoredMessages удаляет сообщения и вычитает их из usage владельцев подключений
+func (r *MongoRepository) deleteStoredMessages(ctx context.Context, rows []*storedMessage) (int64, error) {
+	if len(rows) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, len(rows))
+	freed := make(map[string]Usage)
+	for i, row := range rows {
+		ids[i] = row.ID
+
+		usage := freed[row.Message.BusinessConnectionID]
+		usage.Messages--
+		usage.MessageBytes -= row.Size
+		usage.MediaBytes -= row.MediaSize
+		freed[row.Message.BusinessConnectionID] = usage
+	}
+
+	res, err := r.telegramMessages.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+
+	// если часть сообщений успели удалить параллельно, usage немного занизится - это не страшно
+	for connectionID, usage := range freed {
+		if err := r.incUsage(ctx, connectionID, usage); err != nil {
+			return res.DeletedCount, err
+		}
+	}
+	return res.DeletedCount, nil
+}
+
+// incUsage меняет usage пользователя бота, которому принадлежит подключение.
+// Usage не уходит ниже нуля: сообщения, сохраненные до учета, в нем не считались
+func (r *MongoRepository) incUsage(ctx context.Context, connectionID string, delta Usage) error {
+	if connectionID == "" {
+		return nil
+	}
+
+	field := func(name string, delta int64) bson.M {
+		return bson.M{"$max": bson.A{0, bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$usage." + name, 0}}, delta}}}}
+	}
+
+	_, err := r.botUsers.UpdateOne(
+		ctx,
+		bson.M{"business_connections.id": connectionID},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"usage.messages":      field("messages", delta.Messages),
+			"usage.message_bytes": field("message_bytes", delta.MessageBytes),
+			"usage.media_bytes":   field("media_bytes", delta.MediaBytes),
+		}}}},
+	)
+	return err
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"slices"
 	"time"
 
 	"github.com/mymmrac/telego"
@@ -9,6 +10,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"ssuspy-bot/consts"
 )
 
 type IUser struct {
@@ -21,6 +24,93 @@ type UserSettings struct {
 	ShowPartnerEdits   bool `bson:"show_partner_edits"`   // need true default
 	ShowMyDeleted      bool `bson:"show_my_deleted"`      // need true default
 	ShowPartnerDeleted bool `bson:"show_partner_deleted"` // need true default
+	RetentionDays      int  `bson:"retention_days"`       // 0 - хранить всегда
+	DigestMinutes      int  `bson:"digest_minutes"`       // 0 - уведомлять сразу, иначе сводкой раз в окно
+	CaptureProtected   bool `bson:"capture_protected"`    // сохранять защищенные медиа собеседника сразу, без ответа на них
+
+	// ChatsMode - CHATS_MODE_*, пустой - denylist
+	ChatsMode string     `bson:"chats_mode,omitempty"`
+	ChatRules []ChatRule `bson:"chat_rules,omitempty"`
+}
+
+// ChatRule - правило для одного чата. Флаги nil берутся из общих настроек
+type ChatRule struct {
+	ChatID int64 `bson:"chat_id"`
+	// Listed - чат в списке: при denylist не отслеживается, при allowlist - отслеживается
+	Listed bool `bson:"listed,omitempty"`
+
+	ShowMyEdits        *bool `bson:"show_my_edits,omitempty"`
+	ShowPartnerEdits   *bool `bson:"show_partner_edits,omitempty"`
+	ShowMyDeleted      *bool `bson:"show_my_deleted,omitempty"`
+	ShowPartnerDeleted *bool `bson:"show_partner_deleted,omitempty"`
+}
+
+// HasOverrides - правило переопределяет хотя бы один флаг
+func (r *ChatRule) HasOverrides() bool {
+	return r.ShowMyEdits != nil || r.ShowPartnerEdits != nil ||
+		r.ShowMyDeleted != nil || r.ShowPartnerDeleted != nil
+}
+
+// IsEmpty - правило ничего не меняет и его можно не хранить
+func (r *ChatRule) IsEmpty() bool {
+	return !r.Listed && !r.HasOverrides()
+}
+
+// ChatRule - правило чата, nil - правила нет
+func (s *UserSettings) ChatRule(chatID int64) *ChatRule {
+	for i := range s.ChatRules {
+		if s.ChatRules[i].ChatID == chatID {
+			return &s.ChatRules[i]
+		}
+	}
+	return nil
+}
+
+// TracksChat - сохранять ли сообщения чата и уведомлять ли о них
+func (s *UserSettings) TracksChat(chatID int64) bool {
+	rule := s.ChatRule(chatID)
+	listed := rule != nil && rule.Listed
+	if s.ChatsMode == consts.CHATS_MODE_ALLOWLIST {
+		return listed
+	}
+	return !listed
+}
+
+// ForChat - настройки с переопределениями из правила чата
+func (s *UserSettings) ForChat(chatID int64) UserSettings {
+	settings := *s
+	rule := s.ChatRule(chatID)
+	if rule == nil {
+		return settings
+	}
+
+	override := func(value *bool, target *bool) {
+		if value != nil {
+			*target = *value
+		}
+	}
+	override(rule.ShowMyEdits, &settings.ShowMyEdits)
+	override(rule.ShowPartnerEdits, &settings.ShowPartnerEdits)
+	override(rule.ShowMyDeleted, &settings.ShowMyDeleted)
+	override(rule.ShowPartnerDeleted, &settings.ShowPartnerDeleted)
+	return settings
+}
+
+// SetChatRule заменяет правило чата, пустое правило удаляется
+func (s *UserSettings) SetChatRule(rule ChatRule) {
+	s.ChatRules = slices.DeleteFunc(s.ChatRules, func(r ChatRule) bool {
+		return r.ChatID == rule.ChatID
+	})
+	if !rule.IsEmpty() {
+		s.ChatRules = append(s.ChatRules, rule)
+	}
+}
+
+// DigestDueAt - когда отправить сводку с событием, случившимся в now. Окна выровнены по UTC:
+// часовая сводка приходит в начале часа, дневная - в полночь
+func (s *UserSettings) DigestDueAt(now time.Time) time.Time {
+	window := time.Duration(s.DigestMinutes) * time.Minute
+	return now.UTC().Truncate(window).Add(window)
 }
 
 type User struct {
@@ -43,6 +133,7 @@ type BotUser struct {
 	InternalID          int64                       `bson:"_id"`
 	BusinessConnections []BotUserBusinessConnection `bson:"business_connections"`
 	SendMessages        bool                        `bson:"send_messages"`
+	Usage               Usage                       `bson:"usage"`
 
 	UserID    int64 `bson:"user_id"`
 	BotID     int64 `bson:"bot_id"`
@@ -88,6 +179,7 @@ func (r *MongoRepository) updateUser(
 				"show_partner_edits":   true,
 				"show_my_deleted":      true,
 				"show_partner_deleted": true,
+				"retention_days":       0,
 			},
 			"created_at": time.Now().Unix(),
 		},
@@ -457,6 +549,11 @@ func (r *MongoRepository) UpdateUserSettings(ctx context.Context, userID int64,
 			"settings.show_partner_edits":   data.ShowPartnerEdits,
 			"settings.show_my_deleted":      data.ShowMyDeleted,
 			"settings.show_partner_deleted": data.ShowPartnerDeleted,
+			"settings.retention_days":       data.RetentionDays,
+			"settings.digest_minutes":       data.DigestMinutes,
+			"settings.capture_protected":    data.CaptureProtected,
+			"settings.chats_mode":           data.ChatsMode,
+			"settings.chat_rules":           data.ChatRules,
 		},
 	}
 
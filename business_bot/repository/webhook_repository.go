@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"ssuspy-common/crypto"
+)
+
+// Webhook - адрес пользователя, на который отправляются события об удаленных и измененных сообщениях.
+// У пользователя может быть только один вебхук, поэтому _id - его ID
+type Webhook struct {
+	UserID int64  `bson:"_id"`
+	URL    string `bson:"url"`
+	// Secret - ключ подписи HMAC, в базе лежит зашифрованным
+	Secret  string `bson:"secret"`
+	Deleted bool   `bson:"deleted"`
+	Edited  bool   `bson:"edited"`
+
+	// LastDeliveryAt - время последней попытки доставки, LastError - пусто, если она была успешной
+	LastDeliveryAt int64  `bson:"last_delivery_at,omitempty"`
+	LastError      string `bson:"last_error,omitempty"`
+
+	CreatedAt int64 `bson:"created_at"`
+}
+
+func (r *MongoRepository) GetWebhook(ctx context.Context, userID int64) (*Webhook, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var webhook Webhook
+	if err := r.webhooks.FindOne(ctx, bson.M{"_id": userID}).Decode(&webhook); err != nil {
+		return nil, err
+	}
+
+	secret, err := r.box.Decrypt(webhook.Secret, crypto.UserAAD(userID))
+	if err != nil {
+		return nil, fmt.Errorf("failed decrypt webhook secret of user %d: %w", userID, err)
+	}
+	webhook.Secret = secret
+
+	return &webhook, nil
+}
+
+// SetWebhookURL меняет адрес вебхука или создает его с секретом secret и всеми включенными событиями
+func (r *MongoRepository) SetWebhookURL(ctx context.Context, userID int64, url string, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	encrypted, err := r.box.Encrypt(secret, crypto.UserAAD(userID))
+	if err != nil {
+		return fmt.Errorf("failed encrypt webhook secret: %w", err)
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"url": url,
+		},
+		"$unset": bson.M{
+			"last_delivery_at": "",
+			"last_error":       "",
+		},
+		"$setOnInsert": bson.M{
+			"secret":     encrypted,
+			"deleted":    true,
+			"edited":     true,
+			"created_at": time.Now().Unix(),
+		},
+	}
+
+	_, err = r.webhooks.UpdateOne(ctx, bson.M{"_id": userID}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *MongoRepository) UpdateWebhookEvents(ctx context.Context, userID int64, deleted bool, edited bool) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"deleted": deleted,
+			"edited":  edited,
+		},
+	}
+	_, err := r.webhooks.UpdateOne(ctx, bson.M{"_id": userID}, update)
+	return err
+}
+
+func (r *MongoRepository) UpdateWebhookSecret(ctx context.Context, userID int64, secret string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	encrypted, err := r.box.Encrypt(secret, crypto.UserAAD(userID))
+	if err != nil {
+		return fmt.Errorf("failed encrypt webhook secret: %w", err)
+	}
+
+	_, err = r.webhooks.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"secret": encrypted,
+		},
+	})
+	return err
+}
+
+// SetWebhookDelivery запоминает результат последней попытки доставки, чтобы показать его в настройках
+func (r *MongoRepository) SetWebhookDelivery(ctx context.Context, userID int64, lastError string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.webhooks.UpdateOne(ctx, bson.M{"_id": userID}, bson.M{
+		"$set": bson.M{
+			"last_delivery_at": time.Now().Unix(),
+			"last_error":       lastError,
+		},
+	})
+	return err
+}
+
+func (r *MongoRepository) DeleteWebhook(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	_, err := r.webhooks.DeleteOne(ctx, bson.M{"_id": userID})
+	return err
+}
@@ -0,0 +1,94 @@
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/repository"
+)
+
+type Purger struct {
+	service   *repository.MongoRepository
+	interval  time.Duration
+	batchSize int
+}
+
+func NewPurger(service *repository.MongoRepository, cfg *config.RetentionConfig) *Purger {
+	return &Purger{
+		service:   service,
+		interval:  cfg.Interval,
+		batchSize: cfg.BatchSize,
+	}
+}
+
+// Run периодически удаляет сообщения старше срока хранения, выбранного пользователем
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *Purger) purge(ctx context.Context) {
+	start := time.Now()
+	defer func() {
+		metrics.RetentionRunDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	users, err := p.service.UsersWithRetention(ctx)
+	if err != nil {
+		metrics.RetentionErrorsTotal.Inc()
+		log.Warn().Err(err).Msg("failed get users with retention")
+		return
+	}
+
+	var total int64
+	for _, user := range users {
+		if len(user.ConnectionIDs) == 0 {
+			continue
+		}
+
+		deleted, err := p.purgeUser(ctx, user)
+		if err != nil {
+			metrics.RetentionErrorsTotal.Inc()
+			log.Warn().Err(err).Int64("userID", user.UserID).Msg("failed purge expired messages")
+		}
+		if deleted > 0 {
+			metrics.RetentionPurgedUsers.Inc()
+			log.Debug().Int64("userID", user.UserID).Int64("deleted", deleted).Msg("purged expired messages")
+		}
+		total += deleted
+	}
+
+	log.Info().Int("users", len(users)).Int64("deleted", total).Dur("took", time.Since(start)).Msg("retention purge done")
+}
+
+func (p *Purger) purgeUser(ctx context.Context, user *repository.RetentionUser) (total int64, err error) {
+	before := time.Now().AddDate(0, 0, -user.RetentionDays)
+
+	for {
+		deleted, err := p.service.DeleteMessagesBefore(ctx, user.ConnectionIDs, before, p.batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += deleted
+		metrics.RetentionDeletedMessages.Add(float64(deleted))
+
+		if deleted < int64(p.batchSize) {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package shard
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
+	proto "ssuspy-bot/pb"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/manager"
+)
+
+// Coordinator распределяет ботов между репликами business_bot.
+// Владелец бота выбирается rendezvous хешированием по живым репликам, а чтобы во время
+// смены состава бот не запустился на двух репликах сразу, запуск возможен только под арендой в redis
+type Coordinator struct {
+	rdb     *redis.Redis
+	service *repository.MongoRepository
+	manager *manager.BotManager
+
+	replicaID string
+	addr      string
+	leaseTTL  time.Duration
+	interval  time.Duration
+	// startConcurrency - сколько ботов запускается одновременно за проход ребалансировки
+	startConcurrency int
+
+	// mutex - ребалансировка и запросы gRPC не должны одновременно запускать/останавливать ботов
+	mutex sync.Mutex
+
+	peers       map[string]*grpc.ClientConn
+	peersMutex  sync.Mutex
+	dialOptions []grpc.DialOption
+
+	// startErrors - botID -> *manager.BotError, почему бот не запустился на этой реплике
+	startErrors sync.Map
+}
+
+func NewCoordinator(
+	rdb *redis.Redis,
+	service *repository.MongoRepository,
+	manager *manager.BotManager,
+	cfg *config.ShardConfig,
+	dialOptions []grpc.DialOption,
+) *Coordinator {
+	hostname, _ := os.Hostname()
+
+	replicaID := cfg.ReplicaID
+	if replicaID == "" {
+		replicaID = hostname
+	}
+	addr := cfg.AdvertiseAddr
+	if addr == "" {
+		addr = net.JoinHostPort(hostname, consts.GRPC_PORT)
+	}
+
+	return &Coordinator{
+		rdb:       rdb,
+		service:   service,
+		manager:   manager,
+		replicaID: replicaID,
+		addr:      addr,
+		leaseTTL:  cfg.LeaseTTL,
+		interval:  cfg.RebalanceInterval,
+		peers:     make(map[string]*grpc.ClientConn),
+
+		startConcurrency: cfg.StartConcurrency,
+
+		dialOptions: dialOptions,
+	}
+}
+
+func (c *Coordinator) ReplicaID() string {
+	return c.replicaID
+}
+
+// Run сразу забирает свою часть ботов и дальше периодически ребалансирует, пока жив ctx.
+// Heartbeat и аренды продлеваются отдельно (renewLoop): первый проход с запуском сотен ботов
+// идет дольше LeaseTTL, и за это время аренды не должны истечь
+func (c *Coordinator) Run(ctx context.Context) {
+	// до первой ребалансировки реплика должна быть в списке живых
+	if err := c.renew(ctx); err != nil {
+		metrics.ShardRebalanceErrorsTotal.Inc()
+		log.Warn().Err(err).Str("replicaID", c.replicaID).Msg("failed renew shard leases")
+	}
+	go c.renewLoop(ctx)
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.rebalance(ctx); err != nil {
+			metrics.ShardRebalanceErrorsTotal.Inc()
+			log.Warn().Err(err).Str("replicaID", c.replicaID).Msg("failed shard rebalance")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Leave останавливает ботов реплики и освобождает аренды, чтобы другие реплики забрали их сразу,
+// а не после истечения аренды
+func (c *Coordinator) Leave(ctx context.Context) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.rdb.ShardLeave(ctx, c.replicaID); err != nil {
+		log.Warn().Err(err).Msg("failed leave shard")
+	}
+	// боты переезжают на другие реплики, их вебхуки остаются
+	for _, botID := range c.manager.ListBots() {
+		c.stopBot(ctx, botID, false)
+	}
+
+	c.peersMutex.Lock()
+	for _, conn := range c.peers {
+		conn.Close()
+	}
+	c.peersMutex.Unlock()
+}
+
+// renewLoop продлевает heartbeat и аренды три раза за LeaseTTL. c.mutex он не берет,
+// иначе ждал бы конца долгого прохода ребалансировки
+func (c *Coordinator) renewLoop(ctx context.Context) {
+	ticker := time.NewTicker(c.leaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := c.renew(ctx); err != nil {
+			metrics.ShardRebalanceErrorsTotal.Inc()
+			log.Warn().Err(err).Str("replicaID", c.replicaID).Msg("failed renew shard leases")
+		}
+	}
+}
+
+func (c *Coordinator) renew(ctx context.Context) error {
+	if err := c.rdb.ShardHeartbeat(ctx, c.replicaID, c.addr, c.leaseTTL); err != nil {
+		return fmt.Errorf("heartbeat: %w", err)
+	}
+
+	// аренду могли потерять, например после долгой паузы процесса
+	lost, err := c.rdb.RenewBotLeases(ctx, c.manager.ListBots(), c.replicaID, c.leaseTTL)
+	if err != nil {
+		return fmt.Errorf("failed renew bot leases: %w", err)
+	}
+	for _, botID := range lost {
+		// бот мог остановиться сам между ListBots и продлением, тогда аренду отпустили мы
+		if _, running := c.manager.GetBot(botID); !running {
+			continue
+		}
+		log.Warn().Int64("botID", botID).Msg("bot lease lost, stopping")
+		if err := c.manager.StopBot(botID); err != nil {
+			log.Warn().Err(err).Int64("botID", botID).Msg("failed stop bot")
+		}
+	}
+	return nil
+}
+
+func (c *Coordinator) rebalance(ctx context.Context) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	replicas, err := c.replicas(ctx)
+	if err != nil {
+		return err
+	}
+	metrics.ShardReplicas.Set(float64(len(replicas)))
+
+	botIDs, err := c.service.AllBotIDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed get bot ids: %w", err)
+	}
+
+	known := make(map[int64]bool, len(botIDs))
+	var start []int64
+	for _, botID := range botIDs {
+		known[botID] = true
+		_, running := c.manager.GetBot(botID)
+
+		if owner(replicas, botID) != c.replicaID {
+			if running {
+				log.Info().Int64("botID", botID).Msg("bot moved to another replica")
+				c.stopBot(ctx, botID, false)
+			}
+			continue
+		}
+
+		if !running {
+			start = append(start, botID)
+		}
+	}
+	c.startBots(ctx, start)
+
+	for _, botID := range c.manager.ListBots() {
+		if !known[botID] {
+			log.Info().Int64("botID", botID).Msg("bot removed from database, stopping")
+			c.stopBot(ctx, botID, true)
+		}
+	}
+
+	metrics.ShardOwnedBots.Set(float64(len(c.manager.ListBots())))
+	return nil
+}
+
+// replicas - ID живых реплик, текущая всегда среди них, даже если redis еще не отдал ее запись
+func (c *Coordinator) replicas(ctx context.Context) ([]string, error) {
+	live, err := c.rdb.ShardReplicas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed get replicas: %w", err)
+	}
+
+	replicas := make([]string, 0, len(live)+1)
+	for id := range live {
+		replicas = append(replicas, id)
+	}
+	if !slices.Contains(replicas, c.replicaID) {
+		replicas = append(replicas, c.replicaID)
+	}
+	return replicas, nil
+}
+
+// startBots запускает ботов параллельно, не больше startConcurrency одновременно
+func (c *Coordinator) startBots(ctx context.Context, botIDs []int64) {
+	sem := make(chan struct{}, c.startConcurrency)
+	var wg sync.WaitGroup
+	for _, botID := range botIDs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := c.startBot(ctx, botID, false); err != nil {
+				log.Debug().Err(err).Int64("botID", botID).Msg("bot not started")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// startBot запускает бота под арендой. allowRemoved - явный запрос на запуск, он может занять
+// аренду только что удаленного бота
+func (c *Coordinator) startBot(ctx context.Context, botID int64, allowRemoved bool) error {
+	acquired, err := c.rdb.AcquireBotLease(ctx, botID, c.replicaID, c.leaseTTL, allowRemoved)
+	if err != nil {
+		return fmt.Errorf("failed acquire bot lease: %w", err)
+	}
+	if !acquired {
+		return fmt.Errorf("bot %d is leased by another replica", botID)
+	}
+
+	botData, err := c.service.BotByID(ctx, botID)
+	if err == nil {
+		err = c.manager.AddBot(ctx, botID, botData.SecretToken)
+	}
+	if err != nil {
+		c.startErrors.Store(botID, &manager.BotError{Message: err.Error(), At: time.Now()})
+		if errRelease := c.rdb.ReleaseBotLease(ctx, botID, c.replicaID); errRelease != nil {
+			log.Warn().Err(errRelease).Int64("botID", botID).Msg("failed release bot lease")
+		}
+		return err
+	}
+
+	c.startErrors.Delete(botID)
+	log.Info().Int64("botID", botID).Str("replicaID", c.replicaID).Msg("bot started on replica")
+	return nil
+}
+
+// stopBot останавливает бота и освобождает аренду. removed - бот удален, и его вебхук нужно снять.
+// Иначе бот переезжает, а вебхук могла уже поставить реплика, которая его забирает
+func (c *Coordinator) stopBot(ctx context.Context, botID int64, removed bool) {
+	stop := c.manager.StopBot
+	if removed {
+		stop = c.manager.RemoveBot
+	}
+	if err := stop(botID); err != nil {
+		log.Warn().Err(err).Int64("botID", botID).Msg("failed stop bot")
+	}
+	if err := c.rdb.ReleaseBotLease(ctx, botID, c.replicaID); err != nil {
+		log.Warn().Err(err).Int64("botID", botID).Msg("failed release bot lease")
+	}
+}
+
+// StartOwned запускает бота на этой реплике по запросу gRPC (после пересылки владельцу)
+func (c *Coordinator) StartOwned(ctx context.Context, botID int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, running := c.manager.GetBot(botID); running {
+		return nil
+	}
+	return c.startBot(ctx, botID, true)
+}
+
+// StopRemoved останавливает удаляемого бота, если он запущен здесь, и помечает аренду,
+// чтобы ребалансировка не подняла его, пока запись еще есть в базе
+func (c *Coordinator) StopRemoved(ctx context.Context, botID int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, running := c.manager.GetBot(botID); running {
+		if err := c.manager.RemoveBot(botID); err != nil {
+			return err
+		}
+	}
+	return c.rdb.MarkBotLeaseRemoved(ctx, botID, c.leaseTTL)
+}
+
+// Restart останавливает бота, если он запущен здесь, и запускает заново: заново ставится вебхук
+// или long polling. Аренда при этом остается за репликой
+func (c *Coordinator) Restart(ctx context.Context, botID int64) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, running := c.manager.GetBot(botID); running {
+		if err := c.manager.StopBot(botID); err != nil {
+			return err
+		}
+	}
+	return c.startBot(ctx, botID, false)
+}
+
+// StartError - последняя ошибка запуска бота на этой реплике, nil если бот запустился
+func (c *Coordinator) StartError(botID int64) *manager.BotError {
+	if value, ok := c.startErrors.Load(botID); ok {
+		return value.(*manager.BotError)
+	}
+	return nil
+}
+
+// Peers возвращает клиентов всех живых реплик, кроме текущей
+func (c *Coordinator) Peers(ctx context.Context) (map[string]proto.BotClient, error) {
+	replicas, err := c.replicas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]proto.BotClient, len(replicas))
+	for _, replicaID := range replicas {
+		if replicaID == c.replicaID {
+			continue
+		}
+		peer, err := c.peer(ctx, replicaID)
+		if err != nil {
+			log.Warn().Err(err).Str("replicaID", replicaID).Msg("skipping replica")
+			continue
+		}
+		peers[replicaID] = peer
+	}
+	return peers, nil
+}
+
+// Owner возвращает реплику, которая должна держать бота, nil клиент - это текущая реплика
+func (c *Coordinator) Owner(ctx context.Context, botID int64) (proto.BotClient, error) {
+	replicas, err := c.replicas(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c.peer(ctx, owner(replicas, botID))
+}
+
+// Holder возвращает реплику, на которой бот запущен сейчас, nil клиент - текущая реплика или нигде
+func (c *Coordinator) Holder(ctx context.Context, botID int64) (proto.BotClient, error) {
+	holder, err := c.rdb.BotLeaseHolder(ctx, botID)
+	if err != nil || holder == "" {
+		return nil, err
+	}
+	return c.peer(ctx, holder)
+}
+
+// HeldElsewhere - аренду бота держит другая реплика. Это один GET в redis, им вебхуки
+// отсекают неизвестные ID до поиска секрета бота в mongo
+func (c *Coordinator) HeldElsewhere(ctx context.Context, botID int64) (bool, error) {
+	holder, err := c.rdb.BotLeaseHolder(ctx, botID)
+	if err != nil {
+		return false, err
+	}
+	return holder != "" && holder != c.replicaID, nil
+}
+
+// ForwardUpdate отправляет вебхук бота реплике, которая держит его аренду
+func (c *Coordinator) ForwardUpdate(ctx context.Context, botID int64, data []byte) error {
+	peer, err := c.Holder(ctx, botID)
+	if err != nil {
+		return err
+	}
+	if peer == nil {
+		return manager.ErrBotNotHere
+	}
+
+	_, err = peer.ForwardUpdate(ctx, &proto.ForwardUpdateRequest{
+		BotId:  botID,
+		Update: data,
+	})
+	return err
+}
+
+func (c *Coordinator) peer(ctx context.Context, replicaID string) (proto.BotClient, error) {
+	if replicaID == c.replicaID {
+		return nil, nil
+	}
+
+	live, err := c.rdb.ShardReplicas(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed get replicas: %w", err)
+	}
+	addr, ok := live[replicaID]
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("replica %s is not alive", replicaID)
+	}
+
+	c.peersMutex.Lock()
+	defer c.peersMutex.Unlock()
+
+	conn, ok := c.peers[addr]
+	if !ok {
+		conn, err = grpc.NewClient(addr, c.dialOptions...)
+		if err != nil {
+			return nil, fmt.Errorf("failed connect to replica %s: %w", replicaID, err)
+		}
+		c.peers[addr] = conn
+	}
+	return proto.NewBotClient(conn), nil
+}
+
+// owner - rendezvous хеширование: у каждой пары (реплика, бот) свой вес, бот достается реплике
+// с максимальным. При уходе или приходе реплики переезжают только ее боты
+func owner(replicas []string, botID int64) string {
+	var (
+		best       string
+		bestWeight uint64
+	)
+	for _, replica := range replicas {
+		sum := sha256.Sum256([]byte(replica + "|" + strconv.FormatInt(botID, 10)))
+		weight := binary.BigEndian.Uint64(sum[:8])
+		if best == "" || weight > bestWeight || (weight == bestWeight && replica < best) {
+			best, bestWeight = replica, weight
+		}
+	}
+	return best
+}
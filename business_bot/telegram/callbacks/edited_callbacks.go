@@ -28,3 +28,39 @@ func NewHandleEditedLogDataFromString(s string) (data *types.HandleEditedData, e
 
 	return data, nil
 }
+
+func NewHandleEditedTimelineDataFromString(s string) (*types.HandleEditedTimelineData, error) {
+	expectedLen := 5
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChatID: %v", err)
+	}
+
+	dataID, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DataID: %v", err)
+	}
+
+	revision, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Revision: %v", err)
+	}
+
+	action, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Action: %v", err)
+	}
+
+	return &types.HandleEditedTimelineData{
+		ChatID:   chatID,
+		DataID:   dataID,
+		Revision: revision,
+		Action:   action,
+	}, nil
+}
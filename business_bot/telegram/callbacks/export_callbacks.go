@@ -0,0 +1,76 @@
+package callbacks
+
+import (
+	"fmt"
+	"ssuspy-bot/types"
+	"strconv"
+	"strings"
+)
+
+func NewHandleExportDataFromString(s string) (*types.HandleExportData, error) {
+	expectedLen := 3
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Offset: %v", err)
+	}
+
+	return &types.HandleExportData{
+		Offset:           offset,
+		TypeOfPagination: parts[2],
+	}, nil
+}
+
+func NewHandleExportFormatDataFromString(s string) (*types.HandleExportFormatData, error) {
+	expectedLen := 3
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	dataID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DataID: %v", err)
+	}
+
+	chatIndex, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChatIndex: %v", err)
+	}
+
+	return &types.HandleExportFormatData{
+		DataID:    dataID,
+		ChatIndex: chatIndex,
+	}, nil
+}
+
+func NewHandleExportStartDataFromString(s string) (*types.HandleExportStartData, error) {
+	expectedLen := 4
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	dataID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DataID: %v", err)
+	}
+
+	chatIndex, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChatIndex: %v", err)
+	}
+
+	return &types.HandleExportStartData{
+		DataID:    dataID,
+		ChatIndex: chatIndex,
+		Format:    parts[3],
+	}, nil
+}
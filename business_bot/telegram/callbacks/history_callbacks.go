@@ -0,0 +1,94 @@
+package callbacks
+
+import (
+	"fmt"
+	"ssuspy-bot/types"
+	"strconv"
+	"strings"
+)
+
+func NewHandleHistoryDataFromString(s string) (*types.HandleHistoryData, error) {
+	expectedLen := 3
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Offset: %v", err)
+	}
+
+	return &types.HandleHistoryData{
+		Offset:           offset,
+		TypeOfPagination: parts[2],
+	}, nil
+}
+
+func NewHandleHistoryChatDataFromString(s string) (*types.HandleHistoryChatData, error) {
+	expectedLen := 5
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	dataID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DataID: %v", err)
+	}
+
+	chatIndex, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChatIndex: %v", err)
+	}
+
+	offset, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Offset: %v", err)
+	}
+
+	return &types.HandleHistoryChatData{
+		DataID:           dataID,
+		ChatIndex:        chatIndex,
+		Offset:           offset,
+		TypeOfPagination: parts[4],
+	}, nil
+}
+
+func NewHandleHistoryMessageDataFromString(s string) (*types.HandleHistoryMessageData, error) {
+	expectedLen := 5
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	dataID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DataID: %v", err)
+	}
+
+	chatIndex, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChatIndex: %v", err)
+	}
+
+	messageID, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert MessageID: %v", err)
+	}
+
+	backOffset, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert BackOffset: %v", err)
+	}
+
+	return &types.HandleHistoryMessageData{
+		DataID:     dataID,
+		ChatIndex:  chatIndex,
+		MessageID:  messageID,
+		BackOffset: backOffset,
+	}, nil
+}
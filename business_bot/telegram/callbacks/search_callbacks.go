@@ -0,0 +1,33 @@
+package callbacks
+
+import (
+	"fmt"
+	"ssuspy-bot/types"
+	"strconv"
+	"strings"
+)
+
+func NewHandleSearchDataFromString(s string) (*types.HandleSearchData, error) {
+	expectedLen := 4
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	dataID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert DataID: %v", err)
+	}
+
+	offset, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Offset: %v", err)
+	}
+
+	return &types.HandleSearchData{
+		DataID:           dataID,
+		Offset:           offset,
+		TypeOfPagination: parts[3],
+	}, nil
+}
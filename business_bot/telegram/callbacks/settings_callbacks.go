@@ -0,0 +1,67 @@
+package callbacks
+
+import (
+	"fmt"
+	"ssuspy-bot/types"
+	"strconv"
+	"strings"
+)
+
+// NewHandleSettingsChatsDataFromString - кнопка из меню настроек приходит без параметров, это первая страница
+func NewHandleSettingsChatsDataFromString(s string) (*types.HandleSettingsChatsData, error) {
+	expectedLen := 4
+
+	parts := strings.Split(s, "|")
+	if len(parts) == 1 {
+		return &types.HandleSettingsChatsData{}, nil
+	}
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	offset, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Offset: %v", err)
+	}
+
+	toggleMode, err := strconv.ParseBool(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ToggleMode: %v", err)
+	}
+
+	return &types.HandleSettingsChatsData{
+		Offset:           offset,
+		TypeOfPagination: parts[2],
+		ToggleMode:       toggleMode,
+	}, nil
+}
+
+func NewHandleSettingsChatDataFromString(s string) (*types.HandleSettingsChatData, error) {
+	expectedLen := 4
+
+	parts := strings.Split(s, "|")
+	if len(parts) != expectedLen {
+		return nil, fmt.Errorf("wrong number of parameters: expected %d, received %d", expectedLen, len(parts))
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert ChatID: %v", err)
+	}
+
+	offset, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Offset: %v", err)
+	}
+
+	action, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert Action: %v", err)
+	}
+
+	return &types.HandleSettingsChatData{
+		ChatID: chatID,
+		Offset: offset,
+		Action: action,
+	}, nil
+}
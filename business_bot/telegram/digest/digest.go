@@ -0,0 +1,283 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/handlers"
+	"ssuspy-bot/telegram/locales"
+	"ssuspy-bot/telegram/manager"
+	"ssuspy-common/backoff"
+)
+
+type userKey struct {
+	userID int64
+	botID  int64
+}
+
+// chatGroup - события одного чата за окно
+type chatGroup struct {
+	chatID     int64
+	chatName   string
+	messageIDs []int
+	dataIDs    []int64
+	// deleted и edited - события, из которых собраны messageIDs и dataIDs
+	deleted []*repository.DigestEvent
+	edited  []*repository.DigestEvent
+}
+
+type Worker struct {
+	service    *repository.MongoRepository
+	botManager *manager.BotManager
+	cfg        *config.DigestConfig
+}
+
+func NewWorker(service *repository.MongoRepository, botManager *manager.BotManager, cfg *config.DigestConfig) *Worker {
+	return &Worker{
+		service:    service,
+		botManager: botManager,
+		cfg:        cfg,
+	}
+}
+
+// Run периодически отправляет сводки, окно которых закончилось. Работает на всех репликах,
+// события между ними делятся метками в базе
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		w.flush(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) flush(ctx context.Context) {
+	now := time.Now()
+	claim := primitive.NewObjectID().Hex()
+
+	events, err := w.service.ClaimDigestEvents(ctx, claim, now, now.Add(-w.cfg.ClaimTimeout))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed claim digest events")
+		return
+	}
+	if len(events) == 0 {
+		return
+	}
+
+	var order []userKey
+	users := make(map[userKey][]*repository.DigestEvent)
+	for _, event := range events {
+		key := userKey{userID: event.UserID, botID: event.BotID}
+		if _, exists := users[key]; !exists {
+			order = append(order, key)
+		}
+		users[key] = append(users[key], event)
+	}
+
+	for _, key := range order {
+		failed, err := w.sendUser(ctx, key, users[key])
+		if err != nil {
+			log.Warn().Err(err).Int64("userID", key.userID).Int64("botID", key.botID).Msg("failed send digest")
+		}
+		w.finish(ctx, key, users[key], failed)
+	}
+
+	log.Debug().Int("users", len(order)).Int("events", len(events)).Dur("took", time.Since(now)).Msg("digests sent")
+}
+
+// finish удаляет отправленные события, а неотправленные (failed) откладывает на повтор.
+// После MaxAttempts попыток они тоже удаляются
+func (w *Worker) finish(ctx context.Context, key userKey, events []*repository.DigestEvent, failed []*repository.DigestEvent) {
+	log := log.With().Int64("userID", key.userID).Int64("botID", key.botID).Logger()
+
+	retry := make(map[primitive.ObjectID]bool)
+	var (
+		retryIDs []primitive.ObjectID
+		attempt  int
+	)
+	for _, event := range failed {
+		if event.Attempts+1 >= w.cfg.MaxAttempts {
+			metrics.DigestEventsTotal.WithLabelValues("failed").Inc()
+			continue
+		}
+		retry[event.ID] = true
+		retryIDs = append(retryIDs, event.ID)
+		attempt = max(attempt, event.Attempts+1)
+	}
+
+	if len(retryIDs) > 0 {
+		metrics.DigestEventsTotal.WithLabelValues("retried").Add(float64(len(retryIDs)))
+		dueAt := time.Now().Add(backoff.Exponential(attempt, w.cfg.BackoffBase, w.cfg.BackoffMax))
+		if err := w.service.RetryDigestEvents(ctx, retryIDs, dueAt); err != nil {
+			// метка истечет через ClaimTimeout, и события возьмут снова
+			log.Warn().Err(err).Msg("failed schedule digest retry")
+		}
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(events)-len(retryIDs))
+	for _, event := range events {
+		if !retry[event.ID] {
+			ids = append(ids, event.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := w.service.DeleteDigestEvents(ctx, ids); err != nil {
+		log.Warn().Err(err).Msg("failed delete sent digest events")
+	}
+}
+
+// sendUser отправляет сводки пользователя по чатам и возвращает события, сводки которых не ушли
+func (w *Worker) sendUser(ctx context.Context, key userKey, events []*repository.DigestEvent) ([]*repository.DigestEvent, error) {
+	iUser, err := w.service.FindIUserByID(ctx, key.userID, key.botID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return events, fmt.Errorf("failed find user: %w", err)
+	}
+	if iUser.BotUser == nil || !iUser.BotUser.SendMessages {
+		return nil, nil
+	}
+
+	bot, err := w.botManager.Client(ctx, key.botID)
+	if err != nil {
+		return events, err
+	}
+	loc := locales.NewLocalizer(iUser.User.LanguageCode)
+
+	var (
+		failed []*repository.DigestEvent
+		errs   []error
+	)
+	for _, group := range groupByChat(events) {
+		if !iUser.User.Settings.TracksChat(group.chatID) {
+			continue
+		}
+
+		chatFailed, err := w.sendChat(ctx, bot, loc, iUser, group)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chat %d: %w", group.chatID, err))
+		}
+		failed = append(failed, chatFailed...)
+	}
+	return failed, errors.Join(errs...)
+}
+
+// sendChat отправляет сводку чата: удаленные сообщения и правки отдельными сообщениями,
+// у каждого свои кнопки лога и файлов. Возвращает события той части, что не ушла,
+// чтобы при повторе не прислать вторую часть дважды
+func (w *Worker) sendChat(
+	ctx context.Context,
+	bot *telego.Bot,
+	loc *i18n.Localizer,
+	iUser *repository.IUser,
+	group *chatGroup,
+) ([]*repository.DigestEvent, error) {
+	var (
+		failed []*repository.DigestEvent
+		errs   []error
+	)
+
+	if len(group.messageIDs) > 0 {
+		text, rows, err := handlers.DeletedDigest(ctx, w.service, loc, iUser, group.chatID, group.chatName, group.messageIDs)
+		if err != nil {
+			err = fmt.Errorf("failed build deleted digest: %w", err)
+		} else {
+			err = send(ctx, bot, iUser.User.ID, text, rows)
+		}
+		if err != nil {
+			failed = append(failed, group.deleted...)
+			errs = append(errs, err)
+		} else {
+			metrics.DigestEventsTotal.WithLabelValues("sent").Add(float64(len(group.deleted)))
+		}
+	}
+
+	if len(group.dataIDs) > 0 {
+		text, rows, err := handlers.EditedDigest(ctx, w.service, loc, iUser, group.chatID, group.chatName, group.dataIDs)
+		if err != nil {
+			err = fmt.Errorf("failed build edited digest: %w", err)
+		} else {
+			err = send(ctx, bot, iUser.User.ID, text, rows)
+		}
+		if err != nil {
+			failed = append(failed, group.edited...)
+			errs = append(errs, err)
+		} else {
+			metrics.DigestEventsTotal.WithLabelValues("sent").Add(float64(len(group.edited)))
+		}
+	}
+	return failed, errors.Join(errs...)
+}
+
+func send(ctx context.Context, bot *telego.Bot, userID int64, text string, rows [][]telego.InlineKeyboardButton) error {
+	if text == "" {
+		return nil
+	}
+
+	_, err := bot.SendMessage(ctx, tu.Message(
+		tu.ID(userID),
+		text,
+	).
+		WithParseMode(telego.ModeHTML).
+		WithReplyMarkup(tu.InlineKeyboard(rows...)),
+	)
+	return err
+}
+
+// groupByChat собирает события по чатам в порядке первого события, повторные ID удаленных
+// сообщений убираются
+func groupByChat(events []*repository.DigestEvent) []*chatGroup {
+	var groups []*chatGroup
+	byChat := make(map[int64]*chatGroup)
+	seen := make(map[int64]map[int]bool)
+
+	for _, event := range events {
+		group, exists := byChat[event.ChatID]
+		if !exists {
+			group = &chatGroup{chatID: event.ChatID}
+			byChat[event.ChatID] = group
+			seen[event.ChatID] = make(map[int]bool)
+			groups = append(groups, group)
+		}
+		// имя берем из последнего события, собеседник мог его сменить
+		if event.ChatName != "" {
+			group.chatName = event.ChatName
+		}
+		switch event.Kind {
+		case consts.DIGEST_KIND_DELETED:
+			group.deleted = append(group.deleted, event)
+			for _, messageID := range event.MessageIDs {
+				if !seen[event.ChatID][messageID] {
+					seen[event.ChatID][messageID] = true
+					group.messageIDs = append(group.messageIDs, messageID)
+				}
+			}
+		case consts.DIGEST_KIND_EDITED:
+			group.edited = append(group.edited, event)
+			group.dataIDs = append(group.dataIDs, event.EditedDataID)
+		}
+	}
+	return groups
+}
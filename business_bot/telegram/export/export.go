@@ -0,0 +1,221 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mymmrac/telego"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-common/telegram/format"
+)
+
+const (
+	FormatHTML   = "html"
+	FormatNDJSON = "ndjson"
+	FormatTXT    = "txt"
+)
+
+var Formats = []string{FormatHTML, FormatNDJSON, FormatTXT}
+
+var ErrNoMessages = errors.New("no messages to export")
+
+// item - одна версия сообщения, подготовленная для записи в любой из форматов
+type item struct {
+	Message  *telego.Message
+	Revision int
+	From     string
+	Date     string
+	Text     string
+	Media    string
+}
+
+type chatWriter interface {
+	Ext() string
+	Begin(w io.Writer, chatID int64, name string) error
+	Write(w io.Writer, it *item) error
+	End(w io.Writer) error
+}
+
+func newChatWriter(exportFormat string) (chatWriter, error) {
+	switch exportFormat {
+	case FormatHTML:
+		return &htmlWriter{}, nil
+	case FormatNDJSON:
+		return &ndjsonWriter{}, nil
+	case FormatTXT:
+		return &txtWriter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format: %s", exportFormat)
+	}
+}
+
+type Result struct {
+	// Path - временный zip файл, удаляется вызывающим
+	Path     string
+	Size     int64
+	Chats    int
+	Messages int
+}
+
+// Build собирает zip архив со всеми версиями сообщений, по файлу на чат.
+// userID - владелец подключений, его ключом расшифровываются сообщения
+func Build(
+	ctx context.Context,
+	service *repository.MongoRepository,
+	loc *i18n.Localizer,
+	userID int64,
+	opts *repository.StreamMessagesOptions,
+	exportFormat string,
+) (result *Result, err error) {
+	writer, err := newChatWriter(exportFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.CreateTemp("", "export-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed create temp file: %w", err)
+	}
+	defer func() {
+		f.Close()
+		if err != nil {
+			os.Remove(f.Name())
+		}
+	}()
+
+	result = &Result{Path: f.Name()}
+	zw := zip.NewWriter(f)
+
+	var (
+		entry         io.Writer
+		currentChatID int64
+		lastMessageID int
+		revision      int
+	)
+
+	err = service.StreamMessages(ctx, opts, func(msg *telego.Message) error {
+		if err := service.OpenMessages(userID, msg); err != nil {
+			return err
+		}
+
+		if entry == nil || msg.Chat.ID != currentChatID {
+			if entry != nil {
+				if err := writer.End(entry); err != nil {
+					return err
+				}
+			}
+
+			currentChatID, lastMessageID = msg.Chat.ID, 0
+			result.Chats++
+
+			name := strconv.FormatInt(msg.Chat.ID, 10)
+			chatResolve, err := service.FindChatName(ctx, msg.Chat.ID)
+			if err == nil {
+				name = html.UnescapeString(chatResolve.Name)
+			}
+
+			entry, err = zw.Create(fmt.Sprintf("%d.%s", msg.Chat.ID, writer.Ext()))
+			if err != nil {
+				return err
+			}
+			if err := writer.Begin(entry, msg.Chat.ID, name); err != nil {
+				return err
+			}
+		}
+
+		if msg.MessageID == lastMessageID {
+			revision++
+		} else {
+			lastMessageID, revision = msg.MessageID, 0
+		}
+		result.Messages++
+
+		return writer.Write(entry, newItem(msg, revision, loc))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if entry == nil {
+		return nil, ErrNoMessages
+	}
+	if err = writer.End(entry); err != nil {
+		return nil, err
+	}
+	if err = zw.Close(); err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	result.Size = stat.Size()
+
+	return result, nil
+}
+
+func newItem(msg *telego.Message, revision int, loc *i18n.Localizer) *item {
+	it := &item{
+		Message:  msg,
+		Revision: revision,
+	}
+
+	if msg.From != nil {
+		it.From = html.UnescapeString(format.Name(msg.From.FirstName, msg.From.LastName))
+	}
+
+	date := msg.Date
+	if revision > 0 && msg.EditDate != 0 {
+		date = msg.EditDate
+	}
+	it.Date = time.Unix(date, 0).UTC().Format(consts.DATETIME_FOR_MESSAGE)
+
+	switch {
+	case msg.Text != "":
+		it.Text = msg.Text
+	case msg.Caption != "":
+		it.Text = msg.Caption
+	}
+
+	if media := utils.GetFile(msg); media != nil {
+		it.Media = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: fmt.Sprintf("mediaTypes.%s", media.Type),
+		})
+	} else if msg.Location != nil {
+		it.Media = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "mediaTypes.location",
+		})
+	}
+
+	return it
+}
+
+// Part - кусок архива, отправляемый отдельным документом
+type Part struct {
+	Offset int64
+	Size   int64
+}
+
+// Split делит архив на части, чтобы каждая влезала в MAX_FILE_SIZE_BYTES
+func Split(size int64) []Part {
+	var parts []Part
+	for offset := int64(0); offset < size; offset += consts.MAX_FILE_SIZE_BYTES {
+		parts = append(parts, Part{
+			Offset: offset,
+			Size:   min(consts.MAX_FILE_SIZE_BYTES, size-offset),
+		})
+	}
+	return parts
+}
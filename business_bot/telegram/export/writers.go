@@ -0,0 +1,115 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/mymmrac/telego"
+)
+
+var htmlHeader = template.Must(template.New("header").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}}</title>
+<style>
+body { font-family: sans-serif; max-width: 760px; margin: 0 auto; padding: 16px; background: #f4f4f5; color: #18181b; }
+h1 { font-size: 20px; margin-bottom: 4px; }
+.meta { color: #71717a; font-size: 13px; margin-top: 0; }
+.msg { background: #fff; border-radius: 8px; padding: 8px 12px; margin: 8px 0; }
+.msg.edit { margin-left: 32px; border-left: 3px solid #a1a1aa; }
+.head { font-size: 13px; color: #52525b; margin-bottom: 4px; }
+.media { color: #2563eb; font-size: 14px; }
+.text { white-space: pre-wrap; word-wrap: break-word; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p class="meta">chat id: {{.ChatID}}</p>
+`))
+
+var htmlMessage = template.Must(template.New("message").Parse(`<div class="msg{{if .Revision}} edit{{end}}" id="m{{.Message.MessageID}}-{{.Revision}}">
+<div class="head"><b>{{.From}}</b> · {{.Date}}{{if .Revision}} · <i>edit #{{.Revision}}</i>{{end}}</div>
+{{- if .Media}}
+<div class="media">{{.Media}}</div>
+{{- end}}
+{{- if .Text}}
+<div class="text">{{.Text}}</div>
+{{- end}}
+</div>
+`))
+
+type htmlWriter struct{}
+
+func (htmlWriter) Ext() string { return "html" }
+
+func (htmlWriter) Begin(w io.Writer, chatID int64, name string) error {
+	return htmlHeader.Execute(w, map[string]any{
+		"ChatID": chatID,
+		"Name":   name,
+	})
+}
+
+func (htmlWriter) Write(w io.Writer, it *item) error {
+	return htmlMessage.Execute(w, it)
+}
+
+func (htmlWriter) End(w io.Writer) error {
+	_, err := io.WriteString(w, "</body>\n</html>\n")
+	return err
+}
+
+type ndjsonLine struct {
+	Revision int             `json:"revision"`
+	Message  *telego.Message `json:"message"`
+}
+
+type ndjsonWriter struct{}
+
+func (ndjsonWriter) Ext() string { return "ndjson" }
+
+func (ndjsonWriter) Begin(io.Writer, int64, string) error { return nil }
+
+func (ndjsonWriter) Write(w io.Writer, it *item) error {
+	// Encoder сам дописывает перевод строки после каждого объекта
+	return json.NewEncoder(w).Encode(ndjsonLine{
+		Revision: it.Revision,
+		Message:  it.Message,
+	})
+}
+
+func (ndjsonWriter) End(io.Writer) error { return nil }
+
+type txtWriter struct{}
+
+func (txtWriter) Ext() string { return "txt" }
+
+func (txtWriter) Begin(w io.Writer, chatID int64, name string) error {
+	_, err := fmt.Fprintf(w, "%s (chat id: %d)\n\n", name, chatID)
+	return err
+}
+
+func (txtWriter) Write(w io.Writer, it *item) error {
+	var b strings.Builder
+	if it.Revision > 0 {
+		fmt.Fprintf(&b, "    [%s, edit #%d] %s:", it.Date, it.Revision, it.From)
+	} else {
+		fmt.Fprintf(&b, "[%s] %s:", it.Date, it.From)
+	}
+	if it.Media != "" {
+		fmt.Fprintf(&b, " [%s]", it.Media)
+	}
+	if it.Text != "" {
+		b.WriteString(" ")
+		b.WriteString(it.Text)
+	}
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (txtWriter) End(io.Writer) error { return nil }
@@ -0,0 +1,163 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/mymmrac/telego"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+)
+
+var ErrFileTooBig = errors.New("file is bigger than fetcher limit")
+
+// Fetcher достает содержимое файла, путь к которому вернул getFile
+type Fetcher interface {
+	Fetch(ctx context.Context, bot *telego.Bot, file *telego.File) (*FetchedFile, error)
+}
+
+// FetchedFile - открытый файл. Close закрывает его и удаляет, если файл был скачан во временную папку
+type FetchedFile struct {
+	*os.File
+	cleanup func()
+}
+
+func (f *FetchedFile) Close() error {
+	err := f.File.Close()
+	if f.cleanup != nil {
+		f.cleanup()
+	}
+	return err
+}
+
+func NewFetcher(cfg *config.FilesFetcherConfig) Fetcher {
+	switch cfg.Mode {
+	case consts.FILES_FETCHER_MODE_LOCAL:
+		return LocalFetcher{}
+	case consts.FILES_FETCHER_MODE_HTTP:
+		return NewHTTPFetcher(cfg)
+	}
+	return autoFetcher{
+		local: LocalFetcher{},
+		http:  NewHTTPFetcher(cfg),
+	}
+}
+
+// LocalFetcher читает файл с диска. Работает, только если Bot API запущен с --local и его папка смонтирована в бота
+type LocalFetcher struct{}
+
+func (LocalFetcher) Fetch(_ context.Context, _ *telego.Bot, file *telego.File) (*FetchedFile, error) {
+	f, err := os.Open(file.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("open local file failed: %w", err)
+	}
+	return &FetchedFile{File: f}, nil
+}
+
+// HTTPFetcher скачивает файл через /file/bot<token>/ во временную папку
+type HTTPFetcher struct {
+	client  *http.Client
+	maxSize int64
+	tempDir string
+}
+
+func NewHTTPFetcher(cfg *config.FilesFetcherConfig) *HTTPFetcher {
+	return &HTTPFetcher{
+		client:  &http.Client{Timeout: cfg.Timeout},
+		maxSize: cfg.MaxSize,
+		tempDir: cfg.TempDir,
+	}
+}
+
+func (h *HTTPFetcher) Fetch(ctx context.Context, bot *telego.Bot, file *telego.File) (*FetchedFile, error) {
+	if file.FileSize > h.maxSize {
+		return nil, ErrFileTooBig
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bot.FileDownloadURL(file.FilePath), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed create file request: %w", redactURL(err))
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed download file: %w", redactURL(err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed download file: unexpected status %s", resp.Status)
+	}
+	if resp.ContentLength > h.maxSize {
+		return nil, ErrFileTooBig
+	}
+
+	// отдельная папка, чтобы файл ушел в Telegram под своим именем и не пересекся с другими воркерами
+	dir, err := os.MkdirTemp(h.tempDir, "fetch-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed create temp dir: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	f, err := os.Create(filepath.Join(dir, fileName(file)))
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("failed create temp file: %w", err)
+	}
+	fetched := &FetchedFile{File: f, cleanup: cleanup}
+
+	// читаем на байт больше лимита, чтобы отличить файл ровно в лимит от слишком большого
+	n, err := io.Copy(f, io.LimitReader(resp.Body, h.maxSize+1))
+	if err == nil && n > h.maxSize {
+		err = ErrFileTooBig
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		fetched.Close()
+		if errors.Is(err, ErrFileTooBig) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed save file: %w", redactURL(err))
+	}
+	return fetched, nil
+}
+
+// autoFetcher читает с диска, если Bot API вернул абсолютный путь (так делает только --local), иначе скачивает
+type autoFetcher struct {
+	local Fetcher
+	http  Fetcher
+}
+
+func (a autoFetcher) Fetch(ctx context.Context, bot *telego.Bot, file *telego.File) (*FetchedFile, error) {
+	if filepath.IsAbs(file.FilePath) {
+		return a.local.Fetch(ctx, bot, file)
+	}
+	return a.http.Fetch(ctx, bot, file)
+}
+
+func fileName(file *telego.File) string {
+	name := path.Base(file.FilePath)
+	if name == "." || name == "/" || name == ".." {
+		return file.FileUniqueID
+	}
+	return name
+}
+
+// redactURL убирает адрес из ошибки, в нем токен бота
+func redactURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/mymmrac/telego"
+	"github.com/rs/zerolog/log"
+
+	"ssuspy-bot/archive"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+)
+
+func (w Worker) processArchive(ctx context.Context, bot *telego.Bot, job *redis.Job) error {
+	if w.storage == nil {
+		// архив выключили, пока задача ждала в очереди
+		return nil
+	}
+	if job.File == nil {
+		return fmt.Errorf("archive job without file")
+	}
+
+	// пока задача ждала, файл мог сохранить другой воркер
+	found, err := w.service.AddArchivedFileUser(ctx, job.File.FileUniqueID, job.UserID)
+	if err != nil {
+		return err
+	}
+	if found {
+		metrics.ArchivedFilesTotal.WithLabelValues("duplicate").Inc()
+		return nil
+	}
+
+	fileNetPath, err := bot.GetFile(ctx, &telego.GetFileParams{FileID: job.File.FileID})
+	if err != nil {
+		return err
+	}
+
+	f, err := w.fetcher.Fetch(ctx, bot, fileNetPath)
+	if errors.Is(err, ErrFileTooBig) {
+		metrics.ArchivedFilesTotal.WithLabelValues("skipped").Inc()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed stat fetched file: %w", err)
+	}
+
+	key := archive.NewKey(job.File.FileUniqueID)
+	if err := w.storage.Put(ctx, key, f, info.Size()); err != nil {
+		return fmt.Errorf("failed put archived file: %w", err)
+	}
+
+	created, err := w.service.SaveArchivedFile(ctx, &repository.ArchivedFile{
+		FileUniqueID: job.File.FileUniqueID,
+		Key:          key,
+		Name:         job.File.FileUniqueID + path.Ext(fileNetPath.FilePath),
+		Type:         job.File.Type,
+		Size:         info.Size(),
+	}, job.UserID)
+	if err != nil || !created {
+		// без записи копию никто не найдет, а если запись чужая - у файла уже есть копия
+		if errDelete := w.storage.Delete(ctx, key); errDelete != nil {
+			log.Warn().Err(errDelete).Str("key", key).Msg("failed delete unused archived file")
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed save archived file: %w", err)
+	}
+
+	if created {
+		metrics.ArchivedFilesTotal.WithLabelValues("stored").Inc()
+	} else {
+		metrics.ArchivedFilesTotal.WithLabelValues("duplicate").Inc()
+	}
+	return nil
+}
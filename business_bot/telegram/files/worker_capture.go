@@ -0,0 +1,170 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog/log"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/types"
+	"ssuspy-common/telegram/format"
+)
+
+// processCapture присылает защищенные медиа чата, собранные за CaptureWindow, альбомами.
+// Файлы больше лимита и файлы, которые не удалось скачать, пропускаются, их число пишется в заголовке
+func (w Worker) processCapture(ctx context.Context, bot *telego.Bot, loc *i18n.Localizer, job *redis.Job) error {
+	if len(job.Captures) == 0 {
+		// пачка переносится в ключ задачи, а не удаляется: если воркер упадет до повтора,
+		// запись задачи в очереди останется без файлов, и они возьмутся оттуда снова
+		captures, err := w.rdb.TakeCaptures(ctx, job.UserID, job.ChatID, job.ID, consts.REDIS_TTL_CAPTURE)
+		if err != nil {
+			return err
+		}
+		// при обычном повторе файлы сохранены в самой задаче вместе с отметками об отправке
+		job.Captures = captures
+	}
+	if len(job.Captures) == 0 {
+		return nil
+	}
+
+	box, err := w.service.MessageBox(job.UserID)
+	if err != nil {
+		return err
+	}
+
+	limit := min(uint64(consts.MAX_FILE_SIZE_BYTES), uint64(config.Config.FilesFetcher.MaxSize))
+
+	var (
+		items   []*types.MediaItemProcess
+		fetched = make(map[*types.MediaItemProcess]*FetchedFile)
+		sources = make(map[*types.MediaItemProcess]*redis.CaptureItem)
+		skipped int
+		failed  int
+	)
+	defer func() {
+		for _, f := range fetched {
+			f.Close()
+		}
+	}()
+
+	for _, capture := range job.Captures {
+		if capture.Sent {
+			continue
+		}
+		if uint64(capture.File.FileSize) > limit {
+			skipped++
+			continue
+		}
+
+		// расшифровывается только в памяти: при повторе в задаче сохранится конверт
+		text, entities, err := capture.OpenCaption(box, job.UserID)
+		if err != nil {
+			return err
+		}
+
+		f, err := w.fetchCapture(ctx, bot, capture)
+		if errors.Is(err, ErrFileTooBig) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			// один недоступный файл не должен задерживать остальные, он считается в заголовке
+			log.Warn().Err(err).Int64("userID", job.UserID).Str("fileID", capture.File.FileID).Msg("failed fetch captured file")
+			failed++
+			continue
+		}
+
+		var caption string
+		if text != "" {
+			caption = loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sendMediaInGroups",
+				TemplateData: map[string]string{
+					"Result": format.Caption(text, entities),
+				},
+			})
+		}
+
+		item := &types.MediaItemProcess{
+			Type:         capture.File.Type,
+			FileID:       capture.File.FileID,
+			FileSize:     capture.File.FileSize,
+			Caption:      caption,
+			FileUniqueID: capture.File.FileUniqueID,
+		}
+		items = append(items, item)
+		fetched[item] = f
+		sources[item] = capture
+	}
+
+	// не скачался ни один файл - скорее всего недоступен Bot API, повтор отправит пачку целиком
+	if len(items) == 0 && failed > 0 && !job.HeaderSent {
+		return fmt.Errorf("failed fetch all %d captured files", failed)
+	}
+
+	// при повторе заголовок уже у пользователя, отправляются только не дошедшие альбомы
+	if !job.HeaderSent {
+		metrics.CapturedFilesTotal.WithLabelValues("too_big").Add(float64(skipped))
+		metrics.CapturedFilesTotal.WithLabelValues("failed").Add(float64(failed))
+
+		header := loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.capture.message",
+			TemplateData: map[string]any{
+				"ChatName": job.ChatName,
+				"Skipped":  skipped,
+				"Failed":   failed,
+				"Limit":    humanize.Bytes(limit),
+			},
+		})
+
+		// заголовок отдельным сообщением: подписи файлов уже могут занимать весь лимит
+		_, err := bot.SendMessage(ctx, tu.Message(tu.ID(job.UserID), header).WithParseMode(telego.ModeHTML))
+		if err != nil {
+			return err
+		}
+		job.HeaderSent = true
+	}
+
+	for _, group := range utils.SortFiles(items) {
+		// альбомы по MAX_MEDIA_GROUP_SIZE, чтобы отметить отправленные файлы до следующего альбома
+		for i := 0; i < len(group); i += consts.MAX_MEDIA_GROUP_SIZE {
+			album := group[i:min(i+consts.MAX_MEDIA_GROUP_SIZE, len(group))]
+
+			media := make([]telego.InputMedia, 0, len(album))
+			for _, item := range album {
+				media = append(media, utils.CreateInputMediaFromFileInfoByFile(tu.File(fetched[item]), item.Type, item.Caption))
+			}
+
+			if err := utils.SendMediaInGroups(bot, ctx, job.UserID, media, 0); err != nil {
+				return err
+			}
+			for _, item := range album {
+				sources[item].Sent = true
+			}
+			metrics.CapturedFilesTotal.WithLabelValues("sent").Add(float64(len(album)))
+		}
+	}
+
+	// все отправлено, повторять нечего
+	if err := w.rdb.DropCaptures(ctx, job.UserID, job.ID); err != nil {
+		log.Warn().Err(err).Int64("userID", job.UserID).Msg("failed drop captured files")
+	}
+	return nil
+}
+
+func (w Worker) fetchCapture(ctx context.Context, bot *telego.Bot, capture *redis.CaptureItem) (*FetchedFile, error) {
+	fileNetPath, err := bot.GetFile(ctx, &telego.GetFileParams{FileID: capture.File.FileID})
+	if err != nil {
+		return nil, err
+	}
+	return w.fetcher.Fetch(ctx, bot, fileNetPath)
+}
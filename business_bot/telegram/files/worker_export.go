@@ -0,0 +1,155 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog/log"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/export"
+)
+
+func (w Worker) processExport(ctx context.Context, bot *telego.Bot, loc *i18n.Localizer, job *redis.Job) error {
+	if job.Export == nil {
+		return fmt.Errorf("export job without export params")
+	}
+
+	replyParameters := &telego.ReplyParameters{
+		MessageID:                job.MessageID,
+		ChatID:                   tu.ID(job.UserID),
+		AllowSendingWithoutReply: true,
+	}
+
+	archive, err := w.exportArchive(ctx, loc, job)
+	if err != nil {
+		var messageID string
+		switch {
+		case errors.Is(err, export.ErrNoMessages):
+			// экспортировать нечего, повтор ничего не изменит
+			messageID = "export.empty"
+			err = nil
+		case job.Attempt+1 >= w.cfg.MaxAttempts:
+			// пользователю сообщаем только о последней попытке, иначе он получит ошибку на каждый повтор
+			messageID = "export.failed"
+		default:
+			return err
+		}
+
+		if _, sendErr := bot.SendMessage(ctx, tu.Message(
+			tu.ID(job.UserID),
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: messageID,
+			}),
+		).WithParseMode(telego.ModeHTML).WithReplyParameters(replyParameters)); sendErr != nil {
+			log.Warn().Err(sendErr).Int64("userID", job.UserID).Msg("failed send export error")
+		}
+		return err
+	}
+
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(archive.Path)
+		}
+	}()
+
+	f, err := os.Open(archive.Path)
+	if err != nil {
+		return fmt.Errorf("open export archive failed: %v", err)
+	}
+	defer f.Close()
+
+	parts := export.Split(archive.Size)
+	for i, part := range parts {
+		if i < archive.SentParts {
+			continue
+		}
+
+		partName := archive.FileName
+		if len(parts) > 1 {
+			partName = fmt.Sprintf("%s.%03d", archive.FileName, i+1)
+		}
+
+		document := tu.Document(
+			tu.ID(job.UserID),
+			tu.FileFromReader(io.NewSectionReader(f, part.Offset, part.Size), partName),
+		).WithReplyParameters(replyParameters)
+
+		if i == len(parts)-1 {
+			document = document.WithCaption(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "export.done",
+					TemplateData: map[string]any{
+						"Chats":    archive.Chats,
+						"Messages": archive.Messages,
+						"Size":     humanize.Bytes(uint64(archive.Size)),
+						"Parts":    len(parts),
+					},
+				}),
+			).WithParseMode(telego.ModeHTML)
+		}
+
+		if _, err := bot.SendDocument(ctx, document); err != nil {
+			// отправленные части другой сборки архива не подойдут, поэтому при повторе дослать остаток
+			// можно только из этого же файла
+			if archive.SentParts > 0 && job.Attempt+1 < w.cfg.MaxAttempts {
+				keep = true
+				job.Export.Archive = archive
+			}
+			return fmt.Errorf("failed send export part %d: %w", i+1, err)
+		}
+		archive.SentParts++
+	}
+
+	return nil
+}
+
+// exportArchive возвращает архив, оставшийся от прошлой попытки, или собирает новый
+func (w Worker) exportArchive(ctx context.Context, loc *i18n.Localizer, job *redis.Job) (*redis.ExportArchive, error) {
+	if archive := job.Export.Archive; archive != nil {
+		if _, err := os.Stat(archive.Path); err == nil {
+			return archive, nil
+		}
+		log.Warn().Int64("userID", job.UserID).Int("sentParts", archive.SentParts).Msg("export archive is gone, building it again")
+		job.Export.Archive = nil
+	}
+
+	result, err := export.Build(
+		ctx,
+		w.service,
+		loc,
+		job.UserID,
+		&repository.StreamMessagesOptions{
+			ChatID:        job.Export.ChatID,
+			ConnectionIDs: job.Export.ConnectionIDs,
+		},
+		job.Export.Format,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "all"
+	if job.Export.ChatID != 0 {
+		name = fmt.Sprint(job.Export.ChatID)
+	}
+
+	return &redis.ExportArchive{
+		Path:     result.Path,
+		FileName: fmt.Sprintf("export-%s-%s-%s.zip", name, job.Export.Format, time.Now().Format(consts.DATETIME_FOR_FILES)),
+		Size:     result.Size,
+		Chats:    result.Chats,
+		Messages: result.Messages,
+	}, nil
+}
@@ -2,14 +2,17 @@ package files
 
 import (
 	"context"
-	"fmt"
-	"os"
+	"errors"
+	"ssuspy-bot/archive"
+	"ssuspy-bot/config"
 	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
 	"ssuspy-bot/redis"
 	"ssuspy-bot/repository"
 	"ssuspy-bot/telegram/locales"
 	"ssuspy-bot/telegram/manager"
 	"ssuspy-bot/telegram/utils"
+	"ssuspy-common/backoff"
 	"ssuspy-common/telegram/format"
 	"time"
 
@@ -20,29 +23,46 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+const reapBatch = 100
+
+var errJobTimeout = errors.New("job was not acknowledged before visibility timeout")
+
 type Worker struct {
 	service    *repository.MongoRepository
 	rdb        *redis.Redis
 	botManager *manager.BotManager
+	cfg        *config.FilesQueueConfig
+	fetcher    Fetcher
+	// storage - nil, если архив медиа выключен
+	storage archive.Storage
 }
 
 func NewWorker(
 	service *repository.MongoRepository,
 	rdb *redis.Redis,
 	botManager *manager.BotManager,
+	cfg *config.FilesQueueConfig,
+	fetcher Fetcher,
+	storage archive.Storage,
 ) *Worker {
 	return &Worker{
 		service:    service,
 		rdb:        rdb,
 		botManager: botManager,
+		cfg:        cfg,
+		fetcher:    fetcher,
+		storage:    storage,
 	}
 }
 
 func (w Worker) Work(ctx context.Context) {
 	for {
-		res, err := w.rdb.DequeueJob(ctx, consts.REDIS_QUEUE_FILES, 5*time.Second)
+		res, err := w.rdb.DequeueJob(ctx, consts.REDIS_QUEUE_FILES, 5*time.Second, w.cfg.VisibilityTimeout)
+		if ctx.Err() != nil {
+			return
+		}
 		if err != nil {
-			log.Printf("Ошибка при чтении из Redis: %v", err)
+			log.Warn().Err(err).Msg("failed dequeue files job")
 			time.Sleep(time.Second)
 			continue
 		}
@@ -50,9 +70,107 @@ func (w Worker) Work(ctx context.Context) {
 			continue
 		}
 
-		if err := w.process(res); err != nil {
-			log.Warn().Err(err).Int64("userID", res.UserID).Msg("failed send protected files")
+		// начатую задачу доводим до конца, при остановке она не должна вернуться в очередь по таймауту
+		jobCtx := context.WithoutCancel(ctx)
+		stop := w.keepAlive(jobCtx, res)
+		err = w.process(res)
+		stop()
+
+		if err != nil {
+			log.Warn().Err(err).Int64("userID", res.UserID).Int("type", int(res.Type)).Msg("failed process files job")
+			w.fail(jobCtx, res, err, "error")
+			continue
+		}
+		if err := w.rdb.AckJob(jobCtx, consts.REDIS_QUEUE_FILES, res); err != nil {
+			log.Error().Err(err).Str("jobID", res.ID).Msg("failed ack files job")
+		}
+	}
+}
+
+// keepAlive продлевает срок задачи, пока она обрабатывается, чтобы долгие загрузки не считались зависшими
+func (w Worker) keepAlive(ctx context.Context, job *redis.Job) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		ticker := time.NewTicker(w.cfg.VisibilityTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := w.rdb.TouchJob(ctx, consts.REDIS_QUEUE_FILES, job, w.cfg.VisibilityTimeout); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Str("jobID", job.ID).Msg("failed extend files job deadline")
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// fail откладывает задачу на повтор, а после последней попытки переносит ее в dead letter
+func (w Worker) fail(ctx context.Context, job *redis.Job, cause error, reason string) {
+	job.Attempt++
+	job.LastError = cause.Error()
+
+	if job.Attempt >= w.cfg.MaxAttempts {
+		metrics.FilesJobFailuresTotal.Inc()
+		log.Error().Err(cause).Str("jobID", job.ID).Int64("userID", job.UserID).Int("attempts", job.Attempt).Msg("files job failed, moving to dead letter")
+
+		if err := w.rdb.DeadJob(ctx, consts.REDIS_QUEUE_FILES, job, w.cfg.DeadLetterSize); err != nil {
+			log.Error().Err(err).Str("jobID", job.ID).Msg("failed move files job to dead letter")
+		}
+		return
+	}
+
+	metrics.FilesJobRetriesTotal.WithLabelValues(reason).Inc()
+	retryAt := time.Now().Add(backoff.Exponential(job.Attempt, w.cfg.BackoffBase, w.cfg.BackoffMax))
+	if err := w.rdb.RetryJob(ctx, consts.REDIS_QUEUE_FILES, job, retryAt); err != nil {
+		log.Error().Err(err).Str("jobID", job.ID).Msg("failed schedule files job retry")
+	}
+}
+
+// RunReaper возвращает в очередь отложенные задачи, у которых подошло время, считает упавшими
+// задачи с истекшим сроком и обновляет метрики очереди. Запускается один раз на реплику
+func (w Worker) RunReaper(ctx context.Context) {
+	ticker := time.NewTicker(w.cfg.ReapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if _, err := w.rdb.PromoteJobs(ctx, consts.REDIS_QUEUE_FILES, now, reapBatch); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("failed promote files job retries")
+		}
+
+		expired, err := w.rdb.ExpiredJobs(ctx, consts.REDIS_QUEUE_FILES, now, w.cfg.VisibilityTimeout, reapBatch)
+		if err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("failed get expired files jobs")
+		}
+		for _, job := range expired {
+			log.Warn().Str("jobID", job.ID).Int64("userID", job.UserID).Msg("files job timed out")
+			w.fail(ctx, job, errJobTimeout, "timeout")
+		}
+
+		stats, err := w.rdb.QueueStats(ctx, consts.REDIS_QUEUE_FILES)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Warn().Err(err).Msg("failed get files queue stats")
+			}
+			continue
 		}
+		metrics.FilesQueueDepth.WithLabelValues("pending").Set(float64(stats.Pending))
+		metrics.FilesQueueDepth.WithLabelValues("processing").Set(float64(stats.Processing))
+		metrics.FilesQueueDepth.WithLabelValues("retry").Set(float64(stats.Retry))
+		metrics.FilesQueueDepth.WithLabelValues("dead").Set(float64(stats.Dead))
 	}
 }
 
@@ -60,47 +178,55 @@ func (w Worker) process(job *redis.Job) (err error) {
 	ctx := context.TODO()
 	loc := locales.NewLocalizer(job.UserLanguageCode)
 
-	bot, ok := w.botManager.GetBot(job.BotID)
-	if !ok {
-		log.Error().Int64("botID", job.BotID).Msg("no bot found")
-		return fmt.Errorf("no bot found")
+	bot, err := w.botManager.Client(ctx, job.BotID)
+	if err != nil {
+		log.Error().Err(err).Int64("botID", job.BotID).Msg("no bot found")
+		return err
+	}
+
+	switch job.Type {
+	case redis.JobTypeExport:
+		return w.processExport(ctx, bot, loc, job)
+	case redis.JobTypeArchive:
+		return w.processArchive(ctx, bot, job)
+	case redis.JobTypeCapture:
+		return w.processCapture(ctx, bot, loc, job)
 	}
 
 	if job.File.FileSize > consts.MAX_FILE_SIZE_BYTES {
-		_, err = bot.Bot.SendMessage(ctx, tu.Message(
-			tu.ID(job.UserID),
-			loc.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "errors.errorFileTooBig",
-				TemplateData: map[string]any{
-					"FileSize":  job.File.FileSize,
-					"FileLimit": humanize.Bytes(consts.MAX_FILE_SIZE_BYTES),
-				},
-			}),
-		).
-			WithReplyParameters(&telego.ReplyParameters{
-				MessageID: job.MessageID,
-				ChatID:    tu.ID(job.UserID),
-			}))
-		return err
+		return sendFileTooBig(ctx, bot, loc, job, consts.MAX_FILE_SIZE_BYTES)
 	}
 
-	fileNetPath, err := bot.Bot.GetFile(ctx, &telego.GetFileParams{FileID: job.File.FileID})
+	fileNetPath, err := bot.GetFile(ctx, &telego.GetFileParams{FileID: job.File.FileID})
 	if err != nil {
 		return err
 	}
 
-	f, err := os.Open(fileNetPath.FilePath)
+	f, err := w.fetcher.Fetch(ctx, bot, fileNetPath)
+	if errors.Is(err, ErrFileTooBig) {
+		// повтор не поможет, сообщаем пользователю и завершаем задачу
+		return sendFileTooBig(ctx, bot, loc, job, uint64(config.Config.FilesFetcher.MaxSize))
+	}
 	if err != nil {
-		return fmt.Errorf("open local file failed: %v", err)
+		return err
 	}
 	defer f.Close()
 
+	box, err := w.service.MessageBox(job.UserID)
+	if err != nil {
+		return err
+	}
+	text, entities, err := job.OpenCaption(box)
+	if err != nil {
+		return err
+	}
+
 	var caption string
-	if job.Caption != "" {
+	if text != "" {
 		caption = loc.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "sendMediaInGroups",
 			TemplateData: map[string]string{
-				"Result": format.Caption(job.Caption),
+				"Result": format.Caption(text, entities),
 			},
 		})
 	}
@@ -108,5 +234,23 @@ func (w Worker) process(job *redis.Job) (err error) {
 	file := tu.File(f)
 	inputMedia := utils.CreateInputMediaFromFileInfoByFile(file, job.File.Type, caption)
 
-	return utils.SendMediaInGroups(bot.Bot, ctx, job.UserID, []telego.InputMedia{inputMedia}, job.MessageID)
+	return utils.SendMediaInGroups(bot, ctx, job.UserID, []telego.InputMedia{inputMedia}, job.MessageID)
+}
+
+func sendFileTooBig(ctx context.Context, bot *telego.Bot, loc *i18n.Localizer, job *redis.Job, limit uint64) error {
+	_, err := bot.SendMessage(ctx, tu.Message(
+		tu.ID(job.UserID),
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "errors.errorFileTooBig",
+			TemplateData: map[string]any{
+				"FileSize":  job.File.FileSize,
+				"FileLimit": humanize.Bytes(limit),
+			},
+		}),
+	).
+		WithReplyParameters(&telego.ReplyParameters{
+			MessageID: job.MessageID,
+			ChatID:    tu.ID(job.UserID),
+		}))
+	return err
 }
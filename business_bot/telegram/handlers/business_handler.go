@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -14,22 +15,41 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"ssuspy-bot/archive"
+	"ssuspy-bot/config"
 	"ssuspy-bot/consts"
+	"ssuspy-bot/events"
+	"ssuspy-bot/metrics"
 	"ssuspy-bot/redis"
 	"ssuspy-bot/repository"
 	"ssuspy-bot/telegram/callbacks"
 	"ssuspy-bot/telegram/utils"
 	"ssuspy-bot/types"
+	"ssuspy-bot/webhooks"
 	"ssuspy-common/telegram/format"
 )
 
 func (h *Handler) HandleMessage(c *th.Context, update telego.Update) error {
 	message := update.BusinessMessage
+	iUser := c.Value("iUser").(*repository.IUser)
 
 	message.Text = format.TruncateText(message.Text, consts.MAX_USER_MESSAGE_TEXT_LEN, false)
 	message.Caption = format.TruncateText(message.Caption, consts.MAX_USER_MESSAGE_TEXT_LEN, false)
 
-	err := h.service.SaveMessage(context.Background(), message)
+	name := format.Name(
+		message.Chat.FirstName,
+		message.Chat.LastName,
+	)
+
+	if !iUser.User.Settings.TracksChat(message.Chat.ID) {
+		// имя обновляем и для неотслеживаемых чатов, иначе чат не выбрать в настройках чатов
+		if err := h.service.UpdateChatName(c, iUser.User.ID, message.Chat.ID, name); err != nil {
+			log.Warn().Err(err).Msg("failed save/update chat name")
+		}
+		return nil
+	}
+
+	saved, err := h.saveMessage(c, iUser, message)
 	if err != nil {
 		log.Warn().
 			Err(err).
@@ -38,14 +58,23 @@ func (h *Handler) HandleMessage(c *th.Context, update telego.Update) error {
 			Msg("error saving message")
 		return nil
 	}
+	if !saved {
+		log.Debug().Int64("userID", iUser.User.ID).Msg("skip message, storage quota exceeded")
+		return nil
+	}
 
-	name := format.Name(
-		message.Chat.FirstName,
-		message.Chat.LastName,
-	)
+	h.bus.Publish(events.Event{
+		Kind:     events.KindMessage,
+		BotID:    c.Value("botID").(int64),
+		UserID:   iUser.User.ID,
+		ChatID:   message.Chat.ID,
+		ChatName: name,
+		Message:  message,
+	})
 
 	err = h.service.UpdateChatName(
 		c,
+		iUser.User.ID,
 		message.Chat.ID,
 		name,
 	)
@@ -53,6 +82,20 @@ func (h *Handler) HandleMessage(c *th.Context, update telego.Update) error {
 		log.Warn().Err(err).Msg("failed save/update chat name")
 	}
 
+	if h.storage != nil {
+		for _, media := range utils.GetFiles(message) {
+			err = archive.Enqueue(c, h.service, h.rdb, config.Config.Archive, c.Value("botID").(int64), iUser.User.ID, media)
+			if err != nil {
+				log.Warn().Err(err).Str("fileUniqueID", media.FileUniqueID).Msg("failed enqueue media archive")
+			}
+		}
+	}
+
+	if iUser.User.Settings.CaptureProtected && message.HasProtectedContent &&
+		message.From != nil && message.From.ID != iUser.User.ID {
+		h.captureProtected(c, iUser, message, name)
+	}
+
 	replyToMessage := message.ReplyToMessage
 	if replyToMessage == nil {
 		return nil
@@ -64,7 +107,6 @@ func (h *Handler) HandleMessage(c *th.Context, update telego.Update) error {
 
 	botID := c.Value("botID").(int64)
 	loc := c.Value("loc").(*i18n.Localizer)
-	iUser := c.Value("iUser").(*repository.IUser)
 
 	file := utils.GetFile(replyToMessage)
 	if file == nil {
@@ -86,15 +128,25 @@ func (h *Handler) HandleMessage(c *th.Context, update telego.Update) error {
 		return err
 	}
 
-	err = h.rdb.EnqueueJob(c, consts.REDIS_QUEUE_FILES, redis.Job{
+	job := redis.Job{
 		File:             file,
 		UserID:           iUser.User.ID,
 		ChatID:           message.Chat.ID,
 		MessageID:        protectedMessage.MessageID,
 		Caption:          replyToMessage.Caption,
+		CaptionEntities:  replyToMessage.CaptionEntities,
 		UserLanguageCode: iUser.User.LanguageCode,
 		BotID:            botID,
-	})
+	}
+	box, err := h.service.MessageBox(iUser.User.ID)
+	if err != nil {
+		return err
+	}
+	if err := job.SealCaption(box); err != nil {
+		return err
+	}
+
+	err = h.rdb.EnqueueJob(c, consts.REDIS_QUEUE_FILES, job)
 	if err != nil {
 		return err
 	}
@@ -102,6 +154,61 @@ func (h *Handler) HandleMessage(c *th.Context, update telego.Update) error {
 	return err
 }
 
+// captureProtected добавляет защищенные медиа собеседника в пачку чата. Медиа с таймером
+// тоже приходят с HasProtectedContent. Пачку одним альбомом отправит задача, поставленная
+// первым файлом через CaptureWindow
+func (h *Handler) captureProtected(c *th.Context, iUser *repository.IUser, message *telego.Message, name string) {
+	box, err := h.service.MessageBox(iUser.User.ID)
+	if err != nil {
+		log.Warn().Err(err).Int64("userID", iUser.User.ID).Msg("failed get message key")
+		return
+	}
+
+	for i, file := range utils.GetFiles(message) {
+		fileExists, err := h.service.CreateFileIfNotExists(c, file.FileID, iUser.User.ID, message.Chat.ID)
+		if err != nil {
+			log.Warn().Err(err).Str("fileID", file.FileID).Msg("failed check captured file")
+			continue
+		}
+		if !fileExists {
+			metrics.CapturedFilesTotal.WithLabelValues("duplicate").Inc()
+			continue
+		}
+
+		item := &redis.CaptureItem{File: file}
+		if i == 0 {
+			item.Caption = message.Caption
+			item.CaptionEntities = message.CaptionEntities
+			if err := item.SealCaption(box, iUser.User.ID); err != nil {
+				log.Warn().Err(err).Str("fileID", file.FileID).Msg("failed seal captured caption")
+				continue
+			}
+		}
+
+		first, err := h.rdb.AddCapture(c, iUser.User.ID, message.Chat.ID, item, consts.REDIS_TTL_CAPTURE)
+		if err != nil {
+			log.Warn().Err(err).Str("fileID", file.FileID).Msg("failed add captured file")
+			continue
+		}
+		metrics.CapturedFilesTotal.WithLabelValues("queued").Inc()
+		if !first {
+			continue
+		}
+
+		err = h.rdb.ScheduleJob(c, consts.REDIS_QUEUE_FILES, redis.Job{
+			Type:             redis.JobTypeCapture,
+			UserID:           iUser.User.ID,
+			ChatID:           message.Chat.ID,
+			ChatName:         name,
+			UserLanguageCode: iUser.User.LanguageCode,
+			BotID:            c.Value("botID").(int64),
+		}, time.Now().Add(config.Config.FilesQueue.CaptureWindow))
+		if err != nil {
+			log.Warn().Err(err).Int64("chatID", message.Chat.ID).Msg("failed schedule capture job")
+		}
+	}
+}
+
 func (h *Handler) HandleDeleted(c *th.Context, update telego.Update) error {
 	loc := c.Value("loc").(*i18n.Localizer)
 	iUser := c.Value("iUser").(*repository.IUser)
@@ -111,13 +218,32 @@ func (h *Handler) HandleDeleted(c *th.Context, update telego.Update) error {
 	chatID := c.Value("chatID").(int64)
 	messageIDs := c.Value("messageIDs").([]int)
 
+	if !itsCallbackQuery && !iUser.User.Settings.TracksChat(chatID) {
+		log.Debug().Int64("chatID", chatID).Msg("skip due chat rules")
+		return nil
+	}
+
+	if !itsCallbackQuery {
+		h.bus.Publish(events.Event{
+			Kind:   events.KindDeleted,
+			BotID:  c.Value("botID").(int64),
+			UserID: iUser.User.ID,
+			ChatID: chatID,
+			ChatName: format.Name(
+				update.DeletedBusinessMessages.Chat.FirstName,
+				update.DeletedBusinessMessages.Chat.LastName,
+			),
+			MessageIDs: messageIDs,
+		})
+	}
+
 	var (
 		limit              int
 		offset             int
 		typeOfPagination   string
 		dataID             int64
-		correctMessagesLen uint8
-		correctFilesLen    uint8
+		correctMessagesLen int
+		correctFilesLen    int
 	)
 	if itsCallbackQuery {
 		data, err := callbacks.NewHandleDeletedPaginationDataFromString(update.CallbackQuery.Data)
@@ -154,41 +280,54 @@ func (h *Handler) HandleDeleted(c *th.Context, update telego.Update) error {
 			Offset:        offset,
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, unfilteredOldMsgs...)
+	}
 	if err != nil {
 		return err
 	}
 
+	if !itsCallbackQuery {
+		h.sendWebhook(c, &webhooks.Payload{
+			Event:  webhooks.EventDeleted,
+			UserID: iUser.User.ID,
+			ChatID: chatID,
+			ChatName: format.Name(
+				update.DeletedBusinessMessages.Chat.FirstName,
+				update.DeletedBusinessMessages.Chat.LastName,
+			),
+			MessageIDs: messageIDs,
+			Messages:   unfilteredOldMsgs,
+		})
+	}
+
 	if len(unfilteredOldMsgs) == 0 {
 		log.Warn().Ints("messageIDs", messageIDs).Int("offset", offset).Str("typeOfPagination", typeOfPagination).Msg("no messages found in the database")
 		return nil
 	}
 
-	var oldMsgs []*telego.Message
-	filesLen := 0
-	for _, msg := range unfilteredOldMsgs {
-		switch {
-		case !iUser.User.Settings.ShowMyDeleted && iUser.User.ID == msg.From.ID:
-			log.Debug().Msg("skip due user settings (self)")
-			continue
-		case !iUser.User.Settings.ShowPartnerDeleted && iUser.User.ID != msg.From.ID:
-			log.Debug().Msg("skip due user settings (partner)")
-			continue
-		}
-
-		media := utils.GetFile(msg)
-		if media != nil {
-			filesLen++
-		}
+	settings := iUser.User.Settings.ForChat(chatID)
 
-		oldMsgs = append(oldMsgs, msg)
-	}
+	oldMsgs, filesLen := filterDeleted(unfilteredOldMsgs, iUser.User.ID, settings)
 	if len(oldMsgs) == 0 {
 		log.Warn().Msg("no messages found after filter by user settings")
 		return nil
 	}
 
 	if !itsCallbackQuery {
-		correctMessagesLen, correctFilesLen = uint8(len(oldMsgs)), uint8(filesLen)
+		if settings.DigestMinutes > 0 {
+			return h.addDigestEvent(c, iUser, &repository.DigestEvent{
+				Kind:   consts.DIGEST_KIND_DELETED,
+				ChatID: chatID,
+				ChatName: format.Name(
+					update.DeletedBusinessMessages.Chat.FirstName,
+					update.DeletedBusinessMessages.Chat.LastName,
+				),
+				MessageIDs: messageIDs,
+			})
+		}
+
+		correctMessagesLen, correctFilesLen = len(oldMsgs), filesLen
 		dataID, err = h.service.SetDataDeleted(context.TODO(), iUser.User.ID, messageIDs, correctMessagesLen, correctFilesLen)
 		if err != nil {
 			return err
@@ -201,116 +340,7 @@ func (h *Handler) HandleDeleted(c *th.Context, update telego.Update) error {
 		pagination.Forward = true
 	}
 
-	rows := [][]telego.InlineKeyboardButton{}
-	if len(oldMsgs) > 0 {
-		data := types.HandleDeletedLogData{
-			DataID: dataID,
-			ChatID: chatID,
-			Offset: offset,
-		}
-		rows = append(rows,
-			tu.InlineKeyboardRow(
-				tu.InlineKeyboardButton(
-					loc.MustLocalize(&i18n.LocalizeConfig{
-						MessageID: "business.deleted.fullMessages",
-					}),
-				).WithCallbackData(data.ToString()),
-			),
-		)
-
-		if correctFilesLen != 0 {
-			callbackData := types.HandleDeletedFilesData{
-				DataID: dataID,
-				ChatID: chatID,
-				Type:   types.HandleDeletedFilesDataTypeData,
-			}
-			rows = append(rows,
-				tu.InlineKeyboardRow(
-					tu.InlineKeyboardButton(
-						loc.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "business.deleted.request.files",
-							TemplateData: map[string]int{
-								"Count": int(correctFilesLen),
-							},
-							PluralCount: int(correctFilesLen),
-						}),
-					).WithCallbackData(callbackData.ToString()),
-				),
-			)
-		}
-
-		if len(oldMsgs) > 1 {
-			for i := 0; i < len(oldMsgs); i += 2 {
-				row := make([]telego.InlineKeyboardButton, 0, 2)
-				data := types.HandleDeletedMessageData{
-					MessageID:  oldMsgs[i].MessageID,
-					ChatID:     chatID,
-					DataID:     dataID,
-					BackOffset: offset,
-				}
-
-				row = append(row, tu.InlineKeyboardButton(
-					loc.MustLocalize(&i18n.LocalizeConfig{
-						MessageID: "business.deleted.messageItem",
-						TemplateData: map[string]int{
-							"Count": i + 1 + offset,
-						},
-					}),
-				).WithCallbackData(data.ToString(types.HandleDeletedMessageDataTypeMessage)))
-
-				if i+1 < len(oldMsgs) {
-					data.MessageID = oldMsgs[i+1].MessageID
-					row = append(row, tu.InlineKeyboardButton(
-						loc.MustLocalize(&i18n.LocalizeConfig{
-							MessageID: "business.deleted.messageItem",
-							TemplateData: map[string]int{
-								"Count": i + 2 + offset,
-							},
-						}),
-					).WithCallbackData(data.ToString(types.HandleDeletedMessageDataTypeMessage)))
-				}
-
-				rows = append(rows, row)
-			}
-
-			if len(oldMsgs) > consts.MAX_BUTTONS || pagination.Backward || pagination.Forward {
-				row := make([]telego.InlineKeyboardButton, 0, 2)
-
-				paginationData := types.HandleDeletedPaginationData{
-					DataID: dataID,
-					ChatID: chatID,
-					Offset: offset,
-				}
-
-				if pagination.Backward {
-					paginationData.TypeOfPagination = "b"
-					row = append(
-						row,
-						tu.InlineKeyboardButton(
-							loc.MustLocalize(&i18n.LocalizeConfig{
-								MessageID: "arrow.backward",
-							}),
-						).
-							WithCallbackData(paginationData.ToString()),
-					)
-				}
-				if pagination.Forward {
-					paginationData.TypeOfPagination = "f"
-					row = append(
-						row,
-						tu.InlineKeyboardButton(
-							loc.MustLocalize(&i18n.LocalizeConfig{
-								MessageID: "arrow.forward",
-							}),
-						).
-							WithCallbackData(paginationData.ToString()),
-					)
-				}
-
-				rows = append(rows, row)
-			}
-		}
-	}
+	rows := deletedRows(loc, oldMsgs, chatID, dataID, offset, correctFilesLen, pagination)
 
 	var name string
 	if itsCallbackQuery {
@@ -326,15 +356,7 @@ func (h *Handler) HandleDeleted(c *th.Context, update telego.Update) error {
 			update.DeletedBusinessMessages.Chat.LastName,
 		)
 	}
-	summaryText := format.SummarizeDeletedMessages(oldMsgs, name, loc, true, offset, int(correctMessagesLen))
-	summaryText = format.CustomTruncateText(
-		summaryText,
-		consts.MAX_LEN,
-		loc.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "business.deleted.overflowDescription",
-		}),
-		false,
-	)
+	summaryText := deletedText(loc, oldMsgs, name, offset, correctMessagesLen)
 
 	if itsCallbackQuery {
 		_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
@@ -368,6 +390,11 @@ func (h *Handler) HandleEdited(c *th.Context, update telego.Update) error {
 	iUser := c.Value("iUser").(*repository.IUser)
 	log := c.Value("log").(*zerolog.Logger)
 
+	if !iUser.User.Settings.TracksChat(message.Chat.ID) {
+		log.Debug().Int64("chatID", message.Chat.ID).Msg("skip due chat rules")
+		return nil
+	}
+
 	oldMsg, err := h.service.GetMessage(
 		context.Background(),
 		&repository.GetMessageOptions{
@@ -376,30 +403,39 @@ func (h *Handler) HandleEdited(c *th.Context, update telego.Update) error {
 			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, oldMsg)
+	}
 	if err != nil {
+		h.publishEdited(c, iUser.User.ID, nil, message, nil)
+
 		log.Error().Err(err).
 			Int("message_id", message.MessageID).
 			Msg("failed GetMessage")
-		errSave := h.service.SaveMessage(context.Background(), message)
+		_, errSave := h.saveMessage(c, iUser, message)
 		if errSave != nil {
 			log.Error().Err(errSave).Msg("error saving edited business message after failing to retrieve old message")
 		}
 		return err
 	}
 
+	changes, mediaDiff := format.EditedDiff(oldMsg, message, loc, true)
+	if len(changes) != 0 {
+		h.publishEdited(c, iUser.User.ID, oldMsg, message, changes)
+	}
+
+	settings := iUser.User.Settings.ForChat(message.Chat.ID)
 	switch {
-	case !iUser.User.Settings.ShowMyEdits && iUser.User.ID == oldMsg.From.ID:
+	case !settings.ShowMyEdits && iUser.User.ID == oldMsg.From.ID:
 		log.Debug().Msg("skip due user settings (self)")
 		return nil
-	case !iUser.User.Settings.ShowPartnerEdits && iUser.User.ID != oldMsg.From.ID:
+	case !settings.ShowPartnerEdits && iUser.User.ID != oldMsg.From.ID:
 		log.Debug().Msg("skip due user settings (partner)")
 		return nil
 	}
 
-	changes, mediaDiff := format.EditedDiff(oldMsg, message, loc, true)
-
 	if len(changes) == 0 {
-		err = h.service.SaveMessage(context.Background(), message)
+		_, err = h.saveMessage(c, iUser, message)
 		if err != nil {
 			log.Error().Err(err).
 				Int("message_id", message.MessageID).
@@ -447,6 +483,26 @@ func (h *Handler) HandleEdited(c *th.Context, update telego.Update) error {
 		return err
 	}
 
+	if settings.DigestMinutes > 0 {
+		err = h.addDigestEvent(c, iUser, &repository.DigestEvent{
+			Kind:         consts.DIGEST_KIND_EDITED,
+			ChatID:       message.Chat.ID,
+			ChatName:     name,
+			EditedDataID: dataID,
+		})
+		if err != nil {
+			log.Error().Err(err).Int("message_id", message.MessageID).Msg("error adding edit to digest")
+		}
+
+		if _, errSave := h.saveMessage(c, iUser, message); errSave != nil {
+			log.Error().Err(errSave).
+				Int("message_id", message.MessageID).
+				Msg("error saving edited message to database")
+			return errSave
+		}
+		return err
+	}
+
 	callbackData := types.HandleEditedData{
 		DataID: dataID,
 		ChatID: message.Chat.ID,
@@ -459,6 +515,18 @@ func (h *Handler) HandleEdited(c *th.Context, update telego.Update) error {
 				}),
 			).WithCallbackData(callbackData.ToString(types.HandleEditedDataTypeLog)),
 		),
+		tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "business.edited.buttons.timeline",
+				}),
+			).WithCallbackData(types.HandleEditedTimelineData{
+				ChatID:   message.Chat.ID,
+				DataID:   dataID,
+				Revision: -1,
+				Action:   consts.EDITED_TIMELINE_OPEN,
+			}.ToString()),
+		),
 	)
 
 	if mediaDiff.Removed != nil {
@@ -496,7 +564,7 @@ func (h *Handler) HandleEdited(c *th.Context, update telego.Update) error {
 			WithParseMode(telego.ModeHTML).WithReplyMarkup(replyMarkup))
 	}
 
-	errSave := h.service.SaveMessage(context.Background(), message)
+	_, errSave := h.saveMessage(c, iUser, message)
 	if errSave != nil {
 		log.Error().Err(errSave).
 			Int("message_id", message.MessageID).
@@ -513,6 +581,106 @@ func (h *Handler) HandleEdited(c *th.Context, update telego.Update) error {
 	return err
 }
 
+// publishEdited отправляет правку в шину событий и на вебхук пользователя, oldMsg - nil, если старой версии нет в базе
+func (h *Handler) publishEdited(c *th.Context, userID int64, oldMsg *telego.Message, message *telego.Message, changes []string) {
+	chatName := format.Name(message.Chat.FirstName, message.Chat.LastName)
+
+	h.bus.Publish(events.Event{
+		Kind:     events.KindEdited,
+		BotID:    c.Value("botID").(int64),
+		UserID:   userID,
+		ChatID:   message.Chat.ID,
+		ChatName: chatName,
+		Message:  message,
+		Changes:  changes,
+	})
+
+	h.sendWebhook(c, &webhooks.Payload{
+		Event:      webhooks.EventEdited,
+		UserID:     userID,
+		ChatID:     message.Chat.ID,
+		ChatName:   chatName,
+		Message:    message,
+		OldMessage: oldMsg,
+		Changes:    changes,
+	})
+}
+
+// saveMessage сохраняет сообщение, если оно помещается в квоту пользователя. false - квота заполнена
+// и политика бота reject, сообщение не сохранено
+func (h *Handler) saveMessage(c *th.Context, iUser *repository.IUser, message *telego.Message) (bool, error) {
+	allowed, err := h.quota.Allow(c, iUser.BotUser)
+	if err != nil || !allowed {
+		return false, err
+	}
+	return true, h.service.SaveMessage(context.Background(), iUser.User.ID, message, utils.FilesSize(message))
+}
+
+func (h *Handler) sendWebhook(c *th.Context, payload *webhooks.Payload) {
+	log := c.Value("log").(*zerolog.Logger)
+	payload.BotID = c.Value("botID").(int64)
+
+	if err := webhooks.Enqueue(c, h.service, h.rdb, payload); err != nil {
+		log.Warn().Err(err).Str("event", payload.Event).Msg("failed enqueue webhook")
+	}
+}
+
+// sendMedia отправляет файлы по file_id, а если Telegram их не принял (файл удален или file_id устарел),
+// повторяет отправку, загружая копии из архива. Файлы без копии остаются с file_id
+func (h *Handler) sendMedia(c *th.Context, userID int64, items []*types.MediaItemProcess, replyToMessageID int) error {
+	// повторяется только не отправленная группа, иначе уже отправленные файлы придут дважды
+	for i := 0; i < len(items); i += consts.MAX_MEDIA_GROUP_SIZE {
+		end := min(i+consts.MAX_MEDIA_GROUP_SIZE, len(items))
+		if err := h.sendMediaGroup(c, userID, items[i:end], replyToMessageID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *Handler) sendMediaGroup(c *th.Context, userID int64, items []*types.MediaItemProcess, replyToMessageID int) error {
+	media := make([]telego.InputMedia, 0, len(items))
+	for _, item := range items {
+		media = append(media, utils.CreateInputMediaFromFileInfo(item.FileID, item.Type, item.Caption))
+	}
+
+	err := utils.SendMediaInGroups(c.Bot(), c, userID, media, replyToMessageID)
+	if err == nil || h.storage == nil {
+		return err
+	}
+
+	log := c.Value("log").(*zerolog.Logger)
+	archived := 0
+	for i, item := range items {
+		if item.FileUniqueID == "" {
+			continue
+		}
+
+		r, file, errOpen := archive.Open(c, h.service, h.storage, userID, item.FileUniqueID)
+		if errOpen != nil {
+			if !errors.Is(errOpen, archive.ErrNotFound) {
+				log.Warn().Err(errOpen).Str("fileUniqueID", item.FileUniqueID).Msg("failed open archived file")
+			}
+			continue
+		}
+		defer r.Close()
+
+		media[i] = utils.CreateInputMediaFromFileInfoByFile(tu.FileFromReader(r, file.Name), item.Type, item.Caption)
+		archived++
+	}
+	if archived == 0 {
+		metrics.ArchiveFallbacksTotal.WithLabelValues("missing").Inc()
+		return err
+	}
+
+	if errArchive := utils.SendMediaInGroups(c.Bot(), c, userID, media, replyToMessageID); errArchive != nil {
+		metrics.ArchiveFallbacksTotal.WithLabelValues("failed").Inc()
+		return fmt.Errorf("%w, resend from archive: %w", err, errArchive)
+	}
+	metrics.ArchiveFallbacksTotal.WithLabelValues("sent").Inc()
+	return nil
+}
+
 func (h *Handler) HandleConnection(c *th.Context, update telego.Update) error {
 	connection := update.BusinessConnection
 	loc := c.Value("loc").(*i18n.Localizer)
@@ -523,6 +691,13 @@ func (h *Handler) HandleConnection(c *th.Context, update telego.Update) error {
 		return err
 	}
 
+	h.bus.Publish(events.Event{
+		Kind:              events.KindConnection,
+		BotID:             botID,
+		UserID:            connection.User.ID,
+		ConnectionEnabled: connection.IsEnabled,
+	})
+
 	var text string
 	if connection.IsEnabled {
 		name := format.Name(connection.User.FirstName, connection.User.LastName)
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/callbacks"
+	"ssuspy-bot/telegram/keyboard"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/types"
+	"ssuspy-common/telegram/format"
+)
+
+// cycleOverride переключает переопределение флага: как в настройках -> вкл -> выкл -> как в настройках
+func cycleOverride(value *bool) *bool {
+	switch {
+	case value == nil:
+		on := true
+		return &on
+	case *value:
+		off := false
+		return &off
+	}
+	return nil
+}
+
+func chatsModeLabel(loc *i18n.Localizer, mode string) string {
+	messageID := "settings.chats.mode.denylist"
+	if mode == consts.CHATS_MODE_ALLOWLIST {
+		messageID = "settings.chats.mode.allowlist"
+	}
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: messageID,
+	})
+}
+
+func (h *Handler) HandleSettingsChats(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleSettingsChatsDataFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	settings := iUser.User.Settings
+	if data.ToggleMode {
+		if settings.ChatsMode == consts.CHATS_MODE_ALLOWLIST {
+			settings.ChatsMode = consts.CHATS_MODE_DENYLIST
+		} else {
+			settings.ChatsMode = consts.CHATS_MODE_ALLOWLIST
+		}
+
+		if err := h.service.UpdateUserSettings(c, iUser.User.ID, settings); err != nil {
+			return err
+		}
+	}
+
+	offset := applyPagination(data.Offset, data.TypeOfPagination)
+	chats, pagination, err := h.service.UserChats(c, iUser.User.ID, offset, consts.MAX_BUTTONS)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed UserChats")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	modeData := types.HandleSettingsChatsData{Offset: offset, ToggleMode: true}
+	rows := make([][]telego.InlineKeyboardButton, 0, len(chats)+3)
+	rows = append(rows, tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "settings.chats.buttons.mode",
+				TemplateData: map[string]string{
+					"Mode": chatsModeLabel(loc, settings.ChatsMode),
+				},
+			}),
+		).WithCallbackData(modeData.ToString()),
+	))
+
+	for _, chat := range chats {
+		name := html.UnescapeString(chat.Name)
+		if name == "" {
+			name = strconv.FormatInt(chat.ID, 10)
+		}
+
+		rule := settings.ChatRule(chat.ID)
+		chatData := types.HandleSettingsChatData{
+			ChatID: chat.ID,
+			Offset: offset,
+			Action: consts.CHAT_RULE_SHOW,
+		}
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "settings.chats.item",
+					TemplateData: map[string]any{
+						"Name":      format.TruncateText(name, consts.MAX_BUTTON_TEXT_LEN, true),
+						"Listed":    rule != nil && rule.Listed,
+						"Allowlist": settings.ChatsMode == consts.CHATS_MODE_ALLOWLIST,
+						"Custom":    rule != nil && rule.HasOverrides(),
+					},
+				}),
+			).WithCallbackData(chatData.ToString()),
+		))
+	}
+
+	if pagination.Backward || pagination.Forward {
+		backwardData := types.HandleSettingsChatsData{Offset: offset, TypeOfPagination: "b"}
+		forwardData := types.HandleSettingsChatsData{Offset: offset, TypeOfPagination: "f"}
+		rows = append(rows, keyboard.BuildPaginationRow(
+			loc,
+			pagination.Backward, backwardData.ToString(),
+			pagination.Forward, forwardData.ToString(),
+		))
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_SETTINGS),
+	))
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.chats.message",
+		TemplateData: map[string]any{
+			"Mode":  chatsModeLabel(loc, settings.ChatsMode),
+			"Empty": len(chats) == 0 && offset == 0,
+		},
+	}), rows)
+}
+
+func (h *Handler) HandleSettingsChat(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleSettingsChatDataFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	settings := iUser.User.Settings
+	rule := repository.ChatRule{ChatID: data.ChatID}
+	existing := settings.ChatRule(data.ChatID)
+	if existing != nil {
+		rule = *existing
+	}
+
+	if data.Action != consts.CHAT_RULE_SHOW {
+		switch data.Action {
+		case consts.CHAT_RULE_LISTED:
+			rule.Listed = !rule.Listed
+		case consts.CHAT_RULE_RESET:
+			rule = repository.ChatRule{ChatID: data.ChatID}
+		case consts.SETTINGS_SHOW_MY_EDITS:
+			rule.ShowMyEdits = cycleOverride(rule.ShowMyEdits)
+		case consts.SETTINGS_SHOW_PARTNER_EDITS:
+			rule.ShowPartnerEdits = cycleOverride(rule.ShowPartnerEdits)
+		case consts.SETTINGS_SHOW_MY_DELETED:
+			rule.ShowMyDeleted = cycleOverride(rule.ShowMyDeleted)
+		case consts.SETTINGS_SHOW_PARTNER_DELETED:
+			rule.ShowPartnerDeleted = cycleOverride(rule.ShowPartnerDeleted)
+		default:
+			utils.OnDataError(c, query.ID, loc)
+			return fmt.Errorf("no chat rule action found")
+		}
+
+		if existing == nil && len(settings.ChatRules) >= consts.MAX_CHAT_RULES {
+			return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(query.ID).WithText(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "settings.chats.tooMany",
+					TemplateData: map[string]int{
+						"Max": consts.MAX_CHAT_RULES,
+					},
+				}),
+			).WithShowAlert())
+		}
+
+		settings.SetChatRule(rule)
+		if err := h.service.UpdateUserSettings(c, iUser.User.ID, settings); err != nil {
+			return err
+		}
+	}
+
+	name := strconv.FormatInt(data.ChatID, 10)
+	chatResolve, err := h.service.FindChatName(c, data.ChatID)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		log.Warn().Err(err).Int64("chatID", data.ChatID).Msg("failed FindChatName")
+	}
+	if err == nil && chatResolve.Name != "" {
+		name = chatResolve.Name
+	}
+
+	status := map[bool]string{
+		true: loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.on",
+		}),
+		false: loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.off",
+		}),
+	}
+	effective := settings.ForChat(data.ChatID)
+
+	overrideValue := func(value *bool) string {
+		messageID := "settings.chats.chat.default"
+		if value != nil && *value {
+			messageID = "settings.chats.chat.on"
+		} else if value != nil {
+			messageID = "settings.chats.chat.off"
+		}
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: messageID,
+		})
+	}
+	button := func(messageID string, action int, value string) []telego.InlineKeyboardButton {
+		actionData := types.HandleSettingsChatData{
+			ChatID: data.ChatID,
+			Offset: data.Offset,
+			Action: action,
+		}
+		return tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: messageID,
+					TemplateData: map[string]any{
+						"Value":  value,
+						"Status": rule.Listed,
+					},
+				}),
+			).WithCallbackData(actionData.ToString()),
+		)
+	}
+
+	listedMessageID := "settings.chats.chat.buttons.mute"
+	if settings.ChatsMode == consts.CHATS_MODE_ALLOWLIST {
+		listedMessageID = "settings.chats.chat.buttons.track"
+	}
+
+	rows := [][]telego.InlineKeyboardButton{
+		button(listedMessageID, consts.CHAT_RULE_LISTED, ""),
+		button("settings.chats.chat.buttons.myDeleted", consts.SETTINGS_SHOW_MY_DELETED, overrideValue(rule.ShowMyDeleted)),
+		button("settings.chats.chat.buttons.partnerDeleted", consts.SETTINGS_SHOW_PARTNER_DELETED, overrideValue(rule.ShowPartnerDeleted)),
+		button("settings.chats.chat.buttons.myEdits", consts.SETTINGS_SHOW_MY_EDITS, overrideValue(rule.ShowMyEdits)),
+		button("settings.chats.chat.buttons.partnerEdits", consts.SETTINGS_SHOW_PARTNER_EDITS, overrideValue(rule.ShowPartnerEdits)),
+	}
+	if !rule.IsEmpty() {
+		rows = append(rows, button("settings.chats.chat.buttons.reset", consts.CHAT_RULE_RESET, ""))
+	}
+	backData := types.HandleSettingsChatsData{Offset: data.Offset}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, backData.ToString()),
+	))
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.chats.chat.message",
+		TemplateData: map[string]string{
+			"Name":           name,
+			"Tracked":        status[settings.TracksChat(data.ChatID)],
+			"MyDeleted":      status[effective.ShowMyDeleted],
+			"PartnerDeleted": status[effective.ShowPartnerDeleted],
+			"MyEdit":         status[effective.ShowMyEdits],
+			"PartnerEdit":    status[effective.ShowPartnerEdits],
+		},
+	}), rows)
+}
@@ -51,6 +51,9 @@ func (h *Handler) HandleDeletedLog(c *th.Context, update telego.Update) error {
 			WithEdits:     true,
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
 	if err != nil {
 		log.Warn().Err(err).Msg("failed GetMessages")
 		utils.OnDataError(c, query.ID, loc)
@@ -128,6 +131,9 @@ func (h *Handler) HandleDeletedMessage(c *th.Context, update telego.Update) erro
 			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msg)
+	}
 	if err != nil {
 		log.Error().
 			Err(err).
@@ -186,6 +192,16 @@ func (h *Handler) HandleDeletedMessage(c *th.Context, update telego.Update) erro
 }
 
 func (h *Handler) HandleDeletedMessageDetails(c *th.Context, update telego.Update) error {
+	return h.sendMessageDetails(c, update, false)
+}
+
+// HandleStoredMessageDetails - подробности сообщения из поиска или истории. Оно может быть
+// не удалено, поэтому подписи нейтральные
+func (h *Handler) HandleStoredMessageDetails(c *th.Context, update telego.Update) error {
+	return h.sendMessageDetails(c, update, true)
+}
+
+func (h *Handler) sendMessageDetails(c *th.Context, update telego.Update, stored bool) error {
 	query := update.CallbackQuery
 	loc := c.Value("loc").(*i18n.Localizer)
 	iUser := c.Value("iUser").(*repository.IUser)
@@ -207,6 +223,9 @@ func (h *Handler) HandleDeletedMessageDetails(c *th.Context, update telego.Updat
 			WithEdits:     true,
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
 	if err != nil || len(msgs) == 0 {
 		log.Warn().Err(err).Int("messageID", data.MessageID).Msg("failed GetMessages")
 		utils.OnDataError(c, query.ID, loc)
@@ -226,6 +245,11 @@ func (h *Handler) HandleDeletedMessageDetails(c *th.Context, update telego.Updat
 
 	now := time.Now().Format(consts.DATETIME_FOR_FILES)
 	summaryText := format.SummarizeDeletedMessages(msgs, name, loc, false, data.BackOffset, len(msgs))
+	requestMessageID := "business.deleted.request.message"
+	if stored {
+		summaryText = format.SummarizeStoredMessages(msgs, name, loc)
+		requestMessageID = "business.stored.request.message"
+	}
 	files := []telego.InputMedia{
 		tu.MediaDocument(format.GetMDInputFile(summaryText, fmt.Sprintf("msg-%d-summary-%s", data.MessageID, now))),
 	}
@@ -241,7 +265,7 @@ func (h *Handler) HandleDeletedMessageDetails(c *th.Context, update telego.Updat
 		tu.MediaDocument(tu.FileFromBytes(jsonBytesLatest, fmt.Sprintf("msg-%d-latest-%s.json", data.MessageID, now))).
 			WithCaption(
 				loc.MustLocalize(&i18n.LocalizeConfig{
-					MessageID: "business.deleted.request.message",
+					MessageID: requestMessageID,
 					TemplateData: map[string]bool{
 						"WithEdits": len(msgs) > 1,
 					},
@@ -292,6 +316,9 @@ func (h *Handler) HandleGetDeletedFiles(c *th.Context, update telego.Update) err
 			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
 	if err != nil || len(msgs) == 0 {
 		log.Warn().Err(err).Int64("userID", iUser.User.ID).Msg("Error GetMessages for get deleted files log")
 		utils.OnDataError(c, query.ID, loc)
@@ -316,16 +343,12 @@ func (h *Handler) HandleGetDeletedFiles(c *th.Context, update telego.Update) err
 				}
 			}
 
-			groupCaption := ""
-			if len(currentMediaGroupItems) > 0 {
-				groupCaption = currentMediaGroupItems[0].Caption
-				if groupCaption == "" {
-					for _, mGroupItem := range currentMediaGroupItems {
-						if mGroupItem.Caption != "" {
-							groupCaption = mGroupItem.Caption
-							break
-						}
-					}
+			// подпись альбома у первого сообщения с подписью, вместе с ее разметкой
+			var captionMsg *telego.Message
+			for _, mGroupItem := range currentMediaGroupItems {
+				if mGroupItem.Caption != "" {
+					captionMsg = mGroupItem
+					break
 				}
 			}
 
@@ -336,34 +359,34 @@ func (h *Handler) HandleGetDeletedFiles(c *th.Context, update telego.Update) err
 				}
 
 				caption := ""
-				if i == 0 && groupCaption != "" {
+				if i == 0 && captionMsg != nil {
 					caption = loc.MustLocalize(&i18n.LocalizeConfig{
 						MessageID: "sendMediaInGroups",
 						TemplateData: map[string]string{
-							"Text": format.Caption(groupCaption),
+							"Result": format.Caption(captionMsg.Caption, captionMsg.CaptionEntities),
 						},
 					})
 				}
 
 				files = append(files, &types.MediaItemProcess{
-					Type:     mediaFile.Type,
-					FileID:   mediaFile.FileID,
-					FileSize: mediaFile.FileSize,
-					Caption:  caption,
+					Type:         mediaFile.Type,
+					FileID:       mediaFile.FileID,
+					FileSize:     mediaFile.FileSize,
+					Caption:      caption,
+					FileUniqueID: mediaFile.FileUniqueID,
 				})
 			}
 			processedMediaGroupIDs[msg.MediaGroupID] = true
 		} else {
-			mediaFile := utils.GetFile(msg)
-			if mediaFile == nil {
-				continue
+			// у платных медиа в одном сообщении несколько файлов
+			for _, mediaFile := range utils.GetFiles(msg) {
+				files = append(files, &types.MediaItemProcess{
+					Type:         mediaFile.Type,
+					FileID:       mediaFile.FileID,
+					FileSize:     mediaFile.FileSize,
+					FileUniqueID: mediaFile.FileUniqueID,
+				})
 			}
-
-			files = append(files, &types.MediaItemProcess{
-				Type:     mediaFile.Type,
-				FileID:   mediaFile.FileID,
-				FileSize: mediaFile.FileSize,
-			})
 		}
 	}
 
@@ -371,9 +394,8 @@ func (h *Handler) HandleGetDeletedFiles(c *th.Context, update telego.Update) err
 		utils.OnDataError(c, query.ID, loc)
 	} else {
 		sort := utils.SortFiles(files)
-		converted := utils.ConvertFileInfosGroupsToInputMediaGroups(sort)
-		for i, sortFiles := range converted {
-			if err = utils.SendMediaInGroups(c.Bot(), c, iUser.User.ID, sortFiles, query.Message.GetMessageID()); err != nil {
+		for i, sortFiles := range sort {
+			if err = h.sendMedia(c, iUser.User.ID, sortFiles, query.Message.GetMessageID()); err != nil {
 				log.Warn().Err(err).Int("batchIndex", i).Msg("failed sending files for get deleted files")
 			}
 			if err != nil {
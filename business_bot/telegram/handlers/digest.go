@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog/log"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/types"
+	"ssuspy-common/telegram/format"
+)
+
+// filterDeleted убирает удаленные сообщения, о которых пользователь не хочет знать, и считает файлы
+func filterDeleted(msgs []*telego.Message, userID int64, settings repository.UserSettings) ([]*telego.Message, int) {
+	var filtered []*telego.Message
+	filesLen := 0
+	for _, msg := range msgs {
+		switch {
+		case !settings.ShowMyDeleted && userID == msg.From.ID:
+			log.Debug().Msg("skip due user settings (self)")
+			continue
+		case !settings.ShowPartnerDeleted && userID != msg.From.ID:
+			log.Debug().Msg("skip due user settings (partner)")
+			continue
+		}
+
+		if utils.GetFile(msg) != nil {
+			filesLen++
+		}
+		filtered = append(filtered, msg)
+	}
+	return filtered, filesLen
+}
+
+// deletedRows - кнопки сводки удаленных сообщений: лог, файлы, отдельные сообщения и страницы
+func deletedRows(
+	loc *i18n.Localizer,
+	oldMsgs []*telego.Message,
+	chatID int64,
+	dataID int64,
+	offset int,
+	correctFilesLen int,
+	pagination *repository.PaginationAnswer,
+) [][]telego.InlineKeyboardButton {
+	rows := [][]telego.InlineKeyboardButton{}
+	if len(oldMsgs) > 0 {
+		data := types.HandleDeletedLogData{
+			DataID: dataID,
+			ChatID: chatID,
+			Offset: offset,
+		}
+		rows = append(rows,
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "business.deleted.fullMessages",
+					}),
+				).WithCallbackData(data.ToString()),
+			),
+		)
+
+		if correctFilesLen != 0 {
+			callbackData := types.HandleDeletedFilesData{
+				DataID: dataID,
+				ChatID: chatID,
+				Type:   types.HandleDeletedFilesDataTypeData,
+			}
+			rows = append(rows,
+				tu.InlineKeyboardRow(
+					tu.InlineKeyboardButton(
+						loc.MustLocalize(&i18n.LocalizeConfig{
+							MessageID: "business.deleted.request.files",
+							TemplateData: map[string]int{
+								"Count": correctFilesLen,
+							},
+							PluralCount: correctFilesLen,
+						}),
+					).WithCallbackData(callbackData.ToString()),
+				),
+			)
+		}
+
+		if len(oldMsgs) > 1 {
+			for i := 0; i < len(oldMsgs); i += 2 {
+				row := make([]telego.InlineKeyboardButton, 0, 2)
+				data := types.HandleDeletedMessageData{
+					MessageID:  oldMsgs[i].MessageID,
+					ChatID:     chatID,
+					DataID:     dataID,
+					BackOffset: offset,
+				}
+
+				row = append(row, tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "business.deleted.messageItem",
+						TemplateData: map[string]int{
+							"Count": i + 1 + offset,
+						},
+					}),
+				).WithCallbackData(data.ToString(types.HandleDeletedMessageDataTypeMessage)))
+
+				if i+1 < len(oldMsgs) {
+					data.MessageID = oldMsgs[i+1].MessageID
+					row = append(row, tu.InlineKeyboardButton(
+						loc.MustLocalize(&i18n.LocalizeConfig{
+							MessageID: "business.deleted.messageItem",
+							TemplateData: map[string]int{
+								"Count": i + 2 + offset,
+							},
+						}),
+					).WithCallbackData(data.ToString(types.HandleDeletedMessageDataTypeMessage)))
+				}
+
+				rows = append(rows, row)
+			}
+
+			if len(oldMsgs) > consts.MAX_BUTTONS || pagination.Backward || pagination.Forward {
+				row := make([]telego.InlineKeyboardButton, 0, 2)
+
+				paginationData := types.HandleDeletedPaginationData{
+					DataID: dataID,
+					ChatID: chatID,
+					Offset: offset,
+				}
+
+				if pagination.Backward {
+					paginationData.TypeOfPagination = "b"
+					row = append(
+						row,
+						tu.InlineKeyboardButton(
+							loc.MustLocalize(&i18n.LocalizeConfig{
+								MessageID: "arrow.backward",
+							}),
+						).
+							WithCallbackData(paginationData.ToString()),
+					)
+				}
+				if pagination.Forward {
+					paginationData.TypeOfPagination = "f"
+					row = append(
+						row,
+						tu.InlineKeyboardButton(
+							loc.MustLocalize(&i18n.LocalizeConfig{
+								MessageID: "arrow.forward",
+							}),
+						).
+							WithCallbackData(paginationData.ToString()),
+					)
+				}
+
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+func deletedText(loc *i18n.Localizer, oldMsgs []*telego.Message, name string, offset int, messagesLen int) string {
+	return format.SummarizeDeletedMessagesFit(
+		oldMsgs, name, loc, true, offset, messagesLen,
+		consts.MAX_LEN,
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.deleted.overflowDescription",
+		}),
+	)
+}
+
+// addDigestEvent откладывает уведомление до сводки, окно берется из настроек пользователя
+func (h *Handler) addDigestEvent(c *th.Context, iUser *repository.IUser, event *repository.DigestEvent) error {
+	event.UserID = iUser.User.ID
+	event.BotID = c.Value("botID").(int64)
+	event.DueAt = iUser.User.Settings.DigestDueAt(time.Now())
+
+	if err := h.service.AddDigestEvent(c, event); err != nil {
+		return fmt.Errorf("failed add digest event: %w", err)
+	}
+	metrics.DigestEventsTotal.WithLabelValues("buffered").Inc()
+	return nil
+}
+
+// DeletedDigest собирает сводку удаленных в чате сообщений за окно. Пустой текст - после фильтра
+// по настройкам показывать нечего
+func DeletedDigest(
+	ctx context.Context,
+	service *repository.MongoRepository,
+	loc *i18n.Localizer,
+	iUser *repository.IUser,
+	chatID int64,
+	chatName string,
+	messageIDs []int,
+) (string, [][]telego.InlineKeyboardButton, error) {
+	unfilteredOldMsgs, pagination, err := service.GetMessages(ctx, &repository.GetMessagesOptions{
+		ChatID:        chatID,
+		MessageIDs:    messageIDs,
+		ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+	})
+	if err == nil {
+		err = service.OpenMessages(iUser.User.ID, unfilteredOldMsgs...)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	oldMsgs, filesLen := filterDeleted(unfilteredOldMsgs, iUser.User.ID, iUser.User.Settings.ForChat(chatID))
+	if len(oldMsgs) == 0 {
+		return "", nil, nil
+	}
+
+	messagesLen := len(oldMsgs)
+	dataID, err := service.SetDataDeleted(ctx, iUser.User.ID, messageIDs, messagesLen, filesLen)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(oldMsgs) > consts.MAX_BUTTONS {
+		oldMsgs = oldMsgs[:consts.MAX_BUTTONS]
+	}
+	pagination.Forward = true
+
+	rows := deletedRows(loc, oldMsgs, chatID, dataID, 0, filesLen, pagination)
+	return deletedText(loc, oldMsgs, chatName, 0, messagesLen), rows, nil
+}
+
+// EditedDigest собирает сводку правок в чате за окно: все изменения одним сообщением
+// и кнопки лога и файла каждой правки. Пустой текст - показывать нечего
+func EditedDigest(
+	ctx context.Context,
+	service *repository.MongoRepository,
+	loc *i18n.Localizer,
+	iUser *repository.IUser,
+	chatID int64,
+	chatName string,
+	dataIDs []int64,
+) (string, [][]telego.InlineKeyboardButton, error) {
+	var (
+		items []string
+		rows  [][]telego.InlineKeyboardButton
+		count int
+	)
+	for _, dataID := range dataIDs {
+		data, err := service.GetDataEdited(ctx, iUser.User.ID, dataID)
+		if err != nil {
+			return "", nil, fmt.Errorf("failed get edited data %d: %w", dataID, err)
+		}
+
+		msgs, _, err := service.GetMessages(ctx, &repository.GetMessagesOptions{
+			ChatID:        chatID,
+			MessageIDs:    []int{data.MessageID},
+			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+			WithEdits:     true,
+		})
+		if err == nil {
+			err = service.OpenMessages(iUser.User.ID, msgs...)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+
+		// версии могли удалить срок хранения или квота, такую правку пропускаем
+		oldMsg, newMsg := data.Versions(msgs)
+		if oldMsg == nil || newMsg == nil {
+			continue
+		}
+
+		changes, mediaDiff := format.EditedDiff(oldMsg, newMsg, loc, true)
+		if len(changes) == 0 {
+			continue
+		}
+		count++
+
+		items = append(items, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.digest.editedItem",
+			TemplateData: map[string]any{
+				"Count": count,
+				"Date":  time.Unix(data.NewDate, 0).Format(consts.DATETIME_FOR_MESSAGE),
+				"Diff":  strings.Join(changes, "\n\n"),
+			},
+		}))
+
+		if count > consts.MAX_BUTTONS {
+			continue
+		}
+		callbackData := types.HandleEditedData{
+			DataID: dataID,
+			ChatID: chatID,
+		}
+		row := tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "business.digest.buttons.log",
+					TemplateData: map[string]int{
+						"Count": count,
+					},
+				}),
+			).WithCallbackData(callbackData.ToString(types.HandleEditedDataTypeLog)),
+		)
+		if mediaDiff.Removed != nil {
+			row = append(row, tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "business.digest.buttons.getFile",
+					TemplateData: map[string]int{
+						"Count": count,
+					},
+				}),
+			).WithCallbackData(callbackData.ToString(types.HandleEditedDataTypeFiles)))
+		}
+		rows = append(rows, row)
+	}
+	if count == 0 {
+		return "", nil, nil
+	}
+
+	// правки не режутся посередине, иначе можно разрезать HTML теги
+	text := format.FitItems(
+		items,
+		consts.MAX_LEN,
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.digest.overflowDescription",
+		}),
+		func(result string) string {
+			return loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "business.digest.edited",
+				TemplateData: map[string]any{
+					"Count":            count,
+					"ChatID":           chatID,
+					"ResolvedChatName": chatName,
+					"Result":           result,
+				},
+				PluralCount: count,
+			})
+		},
+	)
+	return text, rows, nil
+}
@@ -1,8 +1,10 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -10,9 +12,13 @@ import (
 	th "github.com/mymmrac/telego/telegohandler"
 	tu "github.com/mymmrac/telego/telegoutil"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/callbacks"
+	"ssuspy-bot/telegram/keyboard"
 	"ssuspy-bot/telegram/utils"
 	"ssuspy-bot/types"
 	"ssuspy-common/telegram/format"
@@ -44,6 +50,9 @@ func (h *Handler) HandleEditedLog(c *th.Context, update telego.Update) error {
 
 	now := time.Now().Format(consts.DATETIME_FOR_FILES)
 	diffText := strings.Join(changes, "\n\n")
+	if len(msgs) > 2 {
+		diffText += "\n\n" + revisionsTimeline(loc, format.SortRevisions(msgs))
+	}
 	files := []telego.InputMedia{
 		tu.MediaDocument(format.GetMDInputFile(diffText, fmt.Sprintf("%d-diff-%s", chatID, now))),
 	}
@@ -93,14 +102,257 @@ func (h *Handler) HandleEditedFiles(c *th.Context, update telego.Update) error {
 		caption = loc.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "sendMediaInGroups",
 			TemplateData: map[string]string{
-				"Text": format.Caption(oldMsg.Caption),
+				"Result": format.Caption(oldMsg.Caption, oldMsg.CaptionEntities),
+			},
+		})
+	}
+
+	file := &types.MediaItemProcess{
+		Type:         mediaDiff.Removed.Type,
+		FileID:       mediaDiff.Removed.FileID,
+		Caption:      caption,
+		FileUniqueID: mediaDiff.Removed.FileUniqueID,
+	}
+	if err := h.sendMedia(c, internalUser.ID, []*types.MediaItemProcess{file}, query.Message.GetMessageID()); err != nil {
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(query.ID))
+}
+
+func revisionDate(msg *telego.Message) string {
+	date := msg.Date
+	if msg.EditDate != 0 {
+		date = msg.EditDate
+	}
+	return time.Unix(date, 0).Format(consts.DATETIME_FOR_MESSAGE)
+}
+
+// revisionChanges - чем версия i отличается от предыдущей, у исходной версии - ее содержимое
+func revisionChanges(loc *i18n.Localizer, revisions []*telego.Message, i int, truncate bool) string {
+	if i == 0 {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.timeline.original",
+			TemplateData: map[string]string{
+				"Message": format.SummarizeDeletedMessage(revisions[0], loc, truncate),
 			},
 		})
 	}
 
-	file := utils.CreateInputMediaFromFileInfo(mediaDiff.Removed.FileID, mediaDiff.Removed.Type, caption)
-	if err := utils.SendMediaInGroups(c.Bot(), c, internalUser.ID, []telego.InputMedia{file}, query.Message.GetMessageID()); err != nil {
+	changes, _ := format.EditedDiff(revisions[i-1], revisions[i], loc, truncate)
+	if len(changes) == 0 {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.timeline.noChanges",
+		})
+	}
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "business.edited.timeline.changes",
+		TemplateData: map[string]any{
+			"From": i,
+			"To":   i + 1,
+			"Diff": strings.Join(changes, "\n\n"),
+		},
+	})
+}
+
+// revisionsTimeline - все версии по порядку с изменениями между соседними, для файла с логом
+func revisionsTimeline(loc *i18n.Localizer, revisions []*telego.Message) string {
+	items := make([]string, len(revisions))
+	for i, revision := range revisions {
+		items[i] = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.timeline.fileItem",
+			TemplateData: map[string]any{
+				"Number":  i + 1,
+				"Date":    revisionDate(revision),
+				"Changes": revisionChanges(loc, revisions, i, false),
+			},
+		})
+	}
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "business.edited.timeline.file",
+		TemplateData: map[string]string{
+			"Revisions": strings.Join(items, "\n\n"),
+		},
+	})
+}
+
+func (h *Handler) HandleEditedTimeline(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleEditedTimelineDataFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
 		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	result, err := h.service.GetDataEdited(context.Background(), iUser.User.ID, data.DataID)
+	if err != nil {
+		log.Error().Err(err).Int64("dataID", data.DataID).Msg("error GetDataEdited")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	msgs, _, err := h.service.GetMessages(
+		context.Background(),
+		&repository.GetMessagesOptions{
+			ChatID:        data.ChatID,
+			MessageIDs:    []int{result.MessageID},
+			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+			WithEdits:     true,
+		},
+	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
+	if err != nil {
+		log.Error().Err(err).Int64("userID", iUser.User.ID).Msg("Error GetMessages for edited timeline")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	revisions := format.SortRevisions(msgs)
+	if len(revisions) == 0 {
+		utils.OnDataError(c, query.ID, loc)
+		return nil
+	}
+
+	current := data.Revision
+	if current < 0 || current >= len(revisions) {
+		current = len(revisions) - 1
+	}
+	revision := revisions[current]
+
+	if data.Action == consts.EDITED_TIMELINE_FILES {
+		media := utils.GetFile(revision)
+		if media == nil {
+			utils.OnDataError(c, query.ID, loc)
+			return fmt.Errorf("HandleEditedTimeline error: no file found")
+		}
+
+		caption := ""
+		if revision.Caption != "" {
+			caption = loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "sendMediaInGroups",
+				TemplateData: map[string]string{
+					"Result": format.Caption(revision.Caption, revision.CaptionEntities),
+				},
+			})
+		}
+
+		file := &types.MediaItemProcess{
+			Type:         media.Type,
+			FileID:       media.FileID,
+			Caption:      caption,
+			FileUniqueID: media.FileUniqueID,
+		}
+		if err := h.sendMedia(c, iUser.User.ID, []*types.MediaItemProcess{file}, query.Message.GetMessageID()); err != nil {
+			utils.OnDataError(c, query.ID, loc)
+			return err
+		}
+		return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(query.ID))
+	}
+
+	var list strings.Builder
+	for i, rev := range revisions {
+		list.WriteString(loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.timeline.item",
+			TemplateData: map[string]any{
+				"Number":   i + 1,
+				"Date":     revisionDate(rev),
+				"Original": rev.EditDate == 0,
+				"Current":  i == current,
+				"Media":    utils.GetFile(rev) != nil,
+			},
+		}))
+	}
+
+	name := strconv.FormatInt(data.ChatID, 10)
+	if chatResolve, err := h.service.FindChatName(c, data.ChatID); err == nil {
+		name = chatResolve.Name
+	}
+
+	text := format.CustomTruncateText(
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.timeline.message",
+			TemplateData: map[string]any{
+				"ChatID":           data.ChatID,
+				"ResolvedChatName": name,
+				"Revisions":        list.String(),
+				"Changes":          revisionChanges(loc, revisions, current, true),
+			},
+		}),
+		consts.MAX_LEN,
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.timeline.overflow",
+		}),
+		false,
+	)
+
+	revisionData := func(revision int, action int) string {
+		return types.HandleEditedTimelineData{
+			ChatID:   data.ChatID,
+			DataID:   data.DataID,
+			Revision: revision,
+			Action:   action,
+		}.ToString()
+	}
+
+	var rows [][]telego.InlineKeyboardButton
+	if len(revisions) > 1 {
+		rows = append(rows, keyboard.BuildPaginationRow(
+			loc,
+			current > 0, revisionData(current-1, consts.EDITED_TIMELINE_SHOW),
+			current < len(revisions)-1, revisionData(current+1, consts.EDITED_TIMELINE_SHOW),
+		))
+	}
+	if utils.GetFile(revision) != nil {
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "business.edited.timeline.buttons.media",
+					TemplateData: map[string]int{
+						"Number": current + 1,
+					},
+				}),
+			).WithCallbackData(revisionData(current, consts.EDITED_TIMELINE_FILES)),
+		))
+	}
+	logData := types.HandleEditedData{
+		DataID: data.DataID,
+		ChatID: data.ChatID,
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "business.edited.buttons.log",
+			}),
+		).WithCallbackData(logData.ToString(types.HandleEditedDataTypeLog)),
+	))
+
+	if data.Action == consts.EDITED_TIMELINE_OPEN {
+		_, err = c.Bot().SendMessage(c, tu.Message(
+			tu.ID(iUser.User.ID),
+			text,
+		).
+			WithParseMode(telego.ModeHTML).
+			WithReplyMarkup(tu.InlineKeyboard(rows...)),
+		)
+	} else {
+		_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
+			tu.ID(iUser.User.ID),
+			query.Message.GetMessageID(),
+			text,
+		).
+			WithParseMode(telego.ModeHTML).
+			WithReplyMarkup(tu.InlineKeyboard(rows...)),
+		)
+	}
+	if err != nil {
 		return err
 	}
 
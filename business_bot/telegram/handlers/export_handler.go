@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/callbacks"
+	"ssuspy-bot/telegram/export"
+	"ssuspy-bot/telegram/keyboard"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/types"
+	"ssuspy-common/telegram/format"
+)
+
+func (h *Handler) HandleExport(c *th.Context, update telego.Update) error {
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	offset := 0
+	if update.CallbackQuery != nil {
+		data, err := callbacks.NewHandleExportDataFromString(update.CallbackQuery.Data)
+		if err != nil {
+			log.Warn().Err(err).Str("data", update.CallbackQuery.Data).Msg("invalid callback data")
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+			return fmt.Errorf("invalid callback data")
+		}
+
+		offset = applyPagination(data.Offset, data.TypeOfPagination)
+	}
+
+	chats, pagination, err := h.service.GetHistoryChats(
+		context.Background(),
+		iUser.BotUser.GetUserCurrentConnectionIDs(),
+		offset,
+		consts.MAX_BUTTONS,
+	)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed GetHistoryChats")
+		if update.CallbackQuery != nil {
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+		}
+		return err
+	}
+
+	if len(chats) == 0 {
+		return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "history.empty",
+		}), nil)
+	}
+
+	chatIDs := make([]int64, 0, len(chats))
+	for _, chat := range chats {
+		chatIDs = append(chatIDs, chat.ID)
+	}
+
+	dataID, err := h.service.SetDataHistory(context.Background(), iUser.User.ID, chatIDs, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("failed SetDataHistory")
+		if update.CallbackQuery != nil {
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+		}
+		return err
+	}
+
+	allData := types.HandleExportFormatData{
+		DataID:    dataID,
+		ChatIndex: types.ExportAllChats,
+	}
+	rows := make([][]telego.InlineKeyboardButton, 0, len(chats)+2)
+	rows = append(rows, tu.InlineKeyboardRow(
+		tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "export.buttons.all",
+			}),
+		).WithCallbackData(allData.ToString()),
+	))
+
+	for i, chat := range chats {
+		name := html.UnescapeString(chat.Name)
+		if name == "" {
+			name = strconv.FormatInt(chat.ID, 10)
+		}
+
+		data := types.HandleExportFormatData{
+			DataID:    dataID,
+			ChatIndex: i,
+		}
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "history.chatItem",
+					TemplateData: map[string]any{
+						"Name":  format.TruncateText(name, consts.MAX_BUTTON_TEXT_LEN, true),
+						"Count": chat.MessagesCount,
+					},
+				}),
+			).WithCallbackData(data.ToString()),
+		))
+	}
+
+	if pagination.Backward || pagination.Forward {
+		backwardData := types.HandleExportData{Offset: offset, TypeOfPagination: "b"}
+		forwardData := types.HandleExportData{Offset: offset, TypeOfPagination: "f"}
+		rows = append(rows, keyboard.BuildPaginationRow(
+			loc,
+			pagination.Backward, backwardData.ToString(),
+			pagination.Forward, forwardData.ToString(),
+		))
+	}
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "export.message",
+	}), rows)
+}
+
+// exportChatID достает ID чата из сохраненного списка, 0 - все чаты
+func (h *Handler) exportChatID(ctx context.Context, userID int64, dataID int64, chatIndex int) (chatID int64, result *repository.DataHistory, err error) {
+	result, err = h.service.GetDataHistory(ctx, userID, dataID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if chatIndex == types.ExportAllChats {
+		return 0, result, nil
+	}
+	if chatIndex < 0 || chatIndex >= len(result.ChatIDs) {
+		return 0, nil, fmt.Errorf("chat index %d out of range", chatIndex)
+	}
+	return result.ChatIDs[chatIndex], result, nil
+}
+
+func (h *Handler) HandleExportFormat(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleExportFormatDataFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	chatID, result, err := h.exportChatID(context.Background(), iUser.User.ID, data.DataID, data.ChatIndex)
+	if err != nil {
+		log.Error().Err(err).Int64("dataID", data.DataID).Msg("error GetDataHistory")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	name := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "export.allChats",
+	})
+	if chatID != 0 {
+		chatResolve, err := h.service.FindChatName(c, chatID)
+		if err != nil {
+			name = strconv.FormatInt(chatID, 10)
+		} else {
+			name = chatResolve.Name
+		}
+	}
+
+	rows := make([][]telego.InlineKeyboardButton, 0, len(export.Formats)+1)
+	for _, exportFormat := range export.Formats {
+		startData := types.HandleExportStartData{
+			DataID:    data.DataID,
+			ChatIndex: data.ChatIndex,
+			Format:    exportFormat,
+		}
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: fmt.Sprintf("export.formats.%s", exportFormat),
+				}),
+			).WithCallbackData(startData.ToString()),
+		))
+	}
+
+	backData := types.HandleExportData{Offset: result.ChatsOffset}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, backData.ToString()),
+	))
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "export.format",
+		TemplateData: map[string]string{
+			"ResolvedChatName": name,
+		},
+	}), rows)
+}
+
+func (h *Handler) HandleExportStart(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	botID := c.Value("botID").(int64)
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleExportStartDataFromString(query.Data)
+	if err != nil || !slices.Contains(export.Formats, data.Format) {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	chatID, _, err := h.exportChatID(context.Background(), iUser.User.ID, data.DataID, data.ChatIndex)
+	if err != nil {
+		log.Error().Err(err).Int64("dataID", data.DataID).Msg("error GetDataHistory")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	err = h.rdb.EnqueueJob(c, consts.REDIS_QUEUE_FILES, redis.Job{
+		Type: redis.JobTypeExport,
+		Export: &redis.ExportJob{
+			ChatID:        chatID,
+			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+			Format:        data.Format,
+		},
+		UserID:           iUser.User.ID,
+		ChatID:           chatID,
+		MessageID:        query.Message.GetMessageID(),
+		UserLanguageCode: iUser.User.LanguageCode,
+		BotID:            botID,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("failed enqueue export job")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "export.queued",
+	}), nil)
+}
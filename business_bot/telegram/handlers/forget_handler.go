@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/keyboard"
+	"ssuspy-bot/telegram/utils"
+)
+
+func (h *Handler) HandleForgetMe(c *th.Context, update telego.Update) error {
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	if update.CallbackQuery == nil {
+		rows := [][]telego.InlineKeyboardButton{
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "forgetMe.buttons.confirm",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_FORGET_ME),
+			),
+			tu.InlineKeyboardRow(
+				keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_BACK_TO_START),
+			),
+		}
+
+		return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "forgetMe.confirm",
+		}), rows)
+	}
+
+	report, err := h.service.ForgetUser(context.Background(), iUser.User.ID)
+	if err != nil {
+		log.Error().Err(err).Msg("failed ForgetUser")
+		utils.OnDataError(c, update.CallbackQuery.ID, loc)
+		return err
+	}
+
+	redisDeleted, err := h.rdb.ForgetUser(context.Background(), iUser.User.ID, report.ChatIDs)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed clear user keys in redis")
+	}
+	report.Counts = append(report.Counts, repository.ForgetCount{
+		Collection: "redis",
+		Deleted:    redisDeleted,
+	})
+
+	var result strings.Builder
+	for _, count := range report.Counts {
+		result.WriteString(loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "forgetMe.reportItem",
+			TemplateData: map[string]any{
+				"Collection": count.Collection,
+				"Count":      count.Deleted,
+			},
+		}))
+	}
+
+	log.Info().Interface("report", report.Counts).Msg("user data wiped")
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "forgetMe.done",
+		TemplateData: map[string]string{
+			"Result": result.String(),
+		},
+	}), nil)
+}
@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/callbacks"
+	"ssuspy-bot/telegram/keyboard"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/types"
+	"ssuspy-common/telegram/format"
+)
+
+func applyPagination(offset int, typeOfPagination string) int {
+	switch typeOfPagination {
+	case "f":
+		return offset + consts.MAX_BUTTONS
+	case "b":
+		return max(offset-consts.MAX_BUTTONS, 0)
+	}
+	return offset
+}
+
+// editOrSend редактирует сообщение с кнопками, если это callback, иначе отправляет новое
+func editOrSend(c *th.Context, update telego.Update, userID int64, text string, rows [][]telego.InlineKeyboardButton) error {
+	var replyMarkup *telego.InlineKeyboardMarkup
+	if len(rows) > 0 {
+		replyMarkup = tu.InlineKeyboard(rows...)
+	}
+
+	if update.CallbackQuery != nil {
+		_, err := c.Bot().EditMessageText(c, tu.EditMessageText(
+			tu.ID(userID),
+			update.CallbackQuery.Message.GetMessageID(),
+			text,
+		).
+			WithParseMode(telego.ModeHTML).
+			WithReplyMarkup(replyMarkup),
+		)
+		if err != nil {
+			return err
+		}
+
+		return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(update.CallbackQuery.ID))
+	}
+
+	_, err := c.Bot().SendMessage(c, tu.Message(
+		tu.ID(userID),
+		text,
+	).
+		WithParseMode(telego.ModeHTML).
+		WithReplyMarkup(replyMarkup),
+	)
+	return err
+}
+
+func (h *Handler) HandleHistory(c *th.Context, update telego.Update) error {
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	offset := 0
+	if update.CallbackQuery != nil {
+		data, err := callbacks.NewHandleHistoryDataFromString(update.CallbackQuery.Data)
+		if err != nil {
+			log.Warn().Err(err).Str("data", update.CallbackQuery.Data).Msg("invalid callback data")
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+			return fmt.Errorf("invalid callback data")
+		}
+
+		offset = applyPagination(data.Offset, data.TypeOfPagination)
+	}
+
+	chats, pagination, err := h.service.GetHistoryChats(
+		context.Background(),
+		iUser.BotUser.GetUserCurrentConnectionIDs(),
+		offset,
+		consts.MAX_BUTTONS,
+	)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed GetHistoryChats")
+		if update.CallbackQuery != nil {
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+		}
+		return err
+	}
+
+	if len(chats) == 0 {
+		return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "history.empty",
+		}), nil)
+	}
+
+	chatIDs := make([]int64, 0, len(chats))
+	for _, chat := range chats {
+		chatIDs = append(chatIDs, chat.ID)
+	}
+
+	dataID, err := h.service.SetDataHistory(context.Background(), iUser.User.ID, chatIDs, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("failed SetDataHistory")
+		if update.CallbackQuery != nil {
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+		}
+		return err
+	}
+
+	rows := make([][]telego.InlineKeyboardButton, 0, len(chats)+1)
+	for i, chat := range chats {
+		name := html.UnescapeString(chat.Name)
+		if name == "" {
+			name = strconv.FormatInt(chat.ID, 10)
+		}
+
+		data := types.HandleHistoryChatData{
+			DataID:    dataID,
+			ChatIndex: i,
+		}
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "history.chatItem",
+					TemplateData: map[string]any{
+						"Name":  format.TruncateText(name, consts.MAX_BUTTON_TEXT_LEN, true),
+						"Count": chat.MessagesCount,
+					},
+				}),
+			).WithCallbackData(data.ToString()),
+		))
+	}
+
+	if pagination.Backward || pagination.Forward {
+		backwardData := types.HandleHistoryData{Offset: offset, TypeOfPagination: "b"}
+		forwardData := types.HandleHistoryData{Offset: offset, TypeOfPagination: "f"}
+		rows = append(rows, keyboard.BuildPaginationRow(
+			loc,
+			pagination.Backward, backwardData.ToString(),
+			pagination.Forward, forwardData.ToString(),
+		))
+	}
+
+	return editOrSend(c, update, iUser.User.ID, loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "history.message",
+	}), rows)
+}
+
+func (h *Handler) HandleHistoryChat(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleHistoryChatDataFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	result, err := h.service.GetDataHistory(context.Background(), iUser.User.ID, data.DataID)
+	if err != nil {
+		log.Error().Err(err).Int64("dataID", data.DataID).Msg("error GetDataHistory")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+	if data.ChatIndex < 0 || data.ChatIndex >= len(result.ChatIDs) {
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("chat index %d out of range", data.ChatIndex)
+	}
+	chatID := result.ChatIDs[data.ChatIndex]
+
+	offset := applyPagination(data.Offset, data.TypeOfPagination)
+	msgs, pagination, err := h.service.GetMessages(
+		context.Background(),
+		&repository.GetMessagesOptions{
+			ChatID:        chatID,
+			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+			AllMessages:   true,
+			Offset:        offset,
+			Limit:         consts.MAX_BUTTONS,
+		},
+	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
+	if err != nil || len(msgs) == 0 {
+		log.Warn().Err(err).Int64("chatID", chatID).Int("offset", offset).Msg("failed GetMessages for history")
+		utils.OnDataError(c, query.ID, loc)
+		if err == nil {
+			err = fmt.Errorf("no messages found for history")
+		}
+		return err
+	}
+
+	var name string
+	chatResolve, err := h.service.FindChatName(c, chatID)
+	if err != nil {
+		name = strconv.FormatInt(chatID, 10)
+	} else {
+		name = chatResolve.Name
+	}
+
+	var summary strings.Builder
+	for i, msg := range msgs {
+		from := ""
+		if msg.From != nil {
+			from = format.Name(msg.From.FirstName, msg.From.LastName)
+		}
+
+		summary.WriteString(loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "history.chat.item",
+			TemplateData: map[string]any{
+				"Count":   i + 1 + offset,
+				"From":    from,
+				"Date":    time.Unix(msg.Date, 0).UTC().Format(consts.DATETIME_FOR_MESSAGE),
+				"Message": format.SummarizeDeletedMessage(msg, loc, true),
+			},
+		}))
+	}
+
+	text := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "history.chat.message",
+		TemplateData: map[string]string{
+			"ResolvedChatName": name,
+			"Result":           summary.String(),
+		},
+	})
+	text = format.CustomTruncateText(
+		text,
+		consts.MAX_LEN,
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "history.chat.overflowDescription",
+		}),
+		false,
+	)
+
+	rows := [][]telego.InlineKeyboardButton{}
+	for i := 0; i < len(msgs); i += 2 {
+		row := make([]telego.InlineKeyboardButton, 0, 2)
+		for j := i; j < i+2 && j < len(msgs); j++ {
+			messageData := types.HandleHistoryMessageData{
+				DataID:     data.DataID,
+				ChatIndex:  data.ChatIndex,
+				MessageID:  msgs[j].MessageID,
+				BackOffset: offset,
+			}
+
+			row = append(row, tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "business.deleted.messageItem",
+					TemplateData: map[string]int{
+						"Count": j + 1 + offset,
+					},
+				}),
+			).WithCallbackData(messageData.ToString()))
+		}
+		rows = append(rows, row)
+	}
+
+	if pagination.Backward || pagination.Forward {
+		backwardData := types.HandleHistoryChatData{DataID: data.DataID, ChatIndex: data.ChatIndex, Offset: offset, TypeOfPagination: "b"}
+		forwardData := types.HandleHistoryChatData{DataID: data.DataID, ChatIndex: data.ChatIndex, Offset: offset, TypeOfPagination: "f"}
+		rows = append(rows, keyboard.BuildPaginationRow(
+			loc,
+			pagination.Backward, backwardData.ToString(),
+			pagination.Forward, forwardData.ToString(),
+		))
+	}
+
+	backData := types.HandleHistoryData{Offset: result.ChatsOffset}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, backData.ToString()),
+	))
+
+	return editOrSend(c, update, iUser.User.ID, text, rows)
+}
+
+func (h *Handler) HandleHistoryMessage(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	data, err := callbacks.NewHandleHistoryMessageDataFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Str("data", query.Data).Msg("invalid callback data")
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("invalid callback data")
+	}
+
+	result, err := h.service.GetDataHistory(context.Background(), iUser.User.ID, data.DataID)
+	if err != nil {
+		log.Error().Err(err).Int64("dataID", data.DataID).Msg("error GetDataHistory")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+	if data.ChatIndex < 0 || data.ChatIndex >= len(result.ChatIDs) {
+		utils.OnDataError(c, query.ID, loc)
+		return fmt.Errorf("chat index %d out of range", data.ChatIndex)
+	}
+	chatID := result.ChatIDs[data.ChatIndex]
+
+	// все версии сообщения, первая - самая свежая
+	msgs, _, err := h.service.GetMessages(
+		context.Background(),
+		&repository.GetMessagesOptions{
+			ChatID:        chatID,
+			MessageIDs:    []int{data.MessageID},
+			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+			WithEdits:     true,
+		},
+	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
+	if err != nil || len(msgs) == 0 {
+		log.Warn().Err(err).Int("messageID", data.MessageID).Msg("failed GetMessages for history message")
+		utils.OnDataError(c, query.ID, loc)
+		if err == nil {
+			err = fmt.Errorf("no messages found for history message")
+		}
+		return err
+	}
+	msg := msgs[0]
+
+	var name string
+	chatResolve, err := h.service.FindChatName(c, chatID)
+	if err != nil {
+		name = strconv.FormatInt(chatID, 10)
+	} else {
+		name = chatResolve.Name
+	}
+
+	rows := [][]telego.InlineKeyboardButton{}
+	if len(msgs) > 1 {
+		detailsData := types.HandleDeletedMessageData{
+			MessageID: data.MessageID,
+			ChatID:    chatID,
+		}
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "history.buttons.edits",
+					TemplateData: map[string]int{
+						"Count": len(msgs) - 1,
+					},
+				}),
+			).WithCallbackData(detailsData.ToString(types.HandleDeletedMessageDataTypeStoredDetails)),
+		))
+	}
+
+	if file := utils.GetFile(msg); file != nil {
+		filesData := types.HandleDeletedFilesData{
+			MessageID: data.MessageID,
+			ChatID:    chatID,
+			Type:      types.HandleDeletedFilesDataTypeMessage,
+		}
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "business.deleted.request.file",
+				}),
+			).WithCallbackData(filesData.ToString()),
+		))
+	}
+
+	backData := types.HandleHistoryChatData{
+		DataID:    data.DataID,
+		ChatIndex: data.ChatIndex,
+		Offset:    data.BackOffset,
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, backData.ToString()),
+	))
+
+	from := ""
+	if msg.From != nil {
+		from = format.Name(msg.From.FirstName, msg.From.LastName)
+	}
+
+	text := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "history.messageView",
+		TemplateData: map[string]any{
+			"ResolvedChatName": name,
+			"From":             from,
+			"Date":             time.Unix(msg.Date, 0).UTC().Format(consts.DATETIME_FOR_MESSAGE),
+			"Edits":            len(msgs) - 1,
+			"Message":          format.SummarizeDeletedMessage(msg, loc, false),
+		},
+	})
+	text = format.CustomTruncateText(
+		text,
+		consts.MAX_LEN,
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.deleted.overflowDescription",
+		}),
+		false,
+	)
+
+	return editOrSend(c, update, iUser.User.ID, text, rows)
+}
@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"ssuspy-bot/archive"
+	"ssuspy-bot/events"
+	"ssuspy-bot/quota"
 	"ssuspy-bot/redis"
 	"ssuspy-bot/repository"
 )
@@ -8,11 +11,18 @@ import (
 type Handler struct {
 	service *repository.MongoRepository
 	rdb     *redis.Redis
+	bus     *events.Bus
+	// storage - nil, если архив медиа выключен
+	storage archive.Storage
+	quota   *quota.Enforcer
 }
 
-func NewHandlerGroup(service *repository.MongoRepository, rdb *redis.Redis) *Handler {
+func NewHandlerGroup(service *repository.MongoRepository, rdb *redis.Redis, bus *events.Bus, storage archive.Storage, quota *quota.Enforcer) *Handler {
 	return &Handler{
 		service: service,
 		rdb:     rdb,
+		bus:     bus,
+		storage: storage,
+		quota:   quota,
 	}
 }
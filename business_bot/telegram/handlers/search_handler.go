@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/callbacks"
+	"ssuspy-bot/telegram/keyboard"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/types"
+	"ssuspy-common/telegram/format"
+)
+
+func (h *Handler) HandleSearch(c *th.Context, update telego.Update) error {
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+
+	var (
+		query            string
+		offset           int
+		typeOfPagination string
+		dataID           int64
+	)
+
+	// с шифрованием текстовый индекс видит только шифротекст и ничего бы не нашел
+	if config.Config.MessageEncryption.Enabled {
+		text := loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "search.unavailable",
+		})
+		if update.CallbackQuery != nil {
+			return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(update.CallbackQuery.ID).WithText(text))
+		}
+
+		_, err := c.Bot().SendMessage(c, tu.Message(
+			tu.ID(iUser.User.ID),
+			text,
+		))
+		return err
+	}
+
+	if update.CallbackQuery != nil {
+		data, err := callbacks.NewHandleSearchDataFromString(update.CallbackQuery.Data)
+		if err != nil {
+			log.Warn().Err(err).Str("data", update.CallbackQuery.Data).Msg("invalid callback data")
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+			return fmt.Errorf("invalid callback data")
+		}
+
+		result, err := h.service.GetDataSearch(context.Background(), iUser.User.ID, data.DataID)
+		if err != nil {
+			log.Error().Err(err).Int64("dataID", data.DataID).Msg("error GetDataSearch")
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+			return err
+		}
+
+		query, offset, typeOfPagination, dataID = result.Query, data.Offset, data.TypeOfPagination, data.DataID
+	} else {
+		_, _, payload := tu.ParseCommandPayload(update.Message.Text)
+		query = format.TruncateText(strings.TrimSpace(payload), consts.MAX_MESSAGE_TEXT_LEN, true)
+		if query == "" {
+			_, err := c.Bot().SendMessage(c, tu.Message(
+				tu.ID(iUser.User.ID),
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "search.usage",
+				}),
+			).WithParseMode(telego.ModeHTML))
+			return err
+		}
+
+		var err error
+		dataID, err = h.service.SetDataSearch(context.Background(), iUser.User.ID, query)
+		if err != nil {
+			log.Error().Err(err).Msg("failed SetDataSearch")
+			return err
+		}
+	}
+
+	offset = applyPagination(offset, typeOfPagination)
+
+	msgs, pagination, err := h.service.SearchMessages(
+		context.Background(),
+		&repository.SearchMessagesOptions{
+			Query:         query,
+			ConnectionIDs: iUser.BotUser.GetUserCurrentConnectionIDs(),
+			Offset:        offset,
+			Limit:         consts.MAX_BUTTONS,
+		},
+	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
+	if err != nil {
+		log.Warn().Err(err).Str("query", query).Msg("failed SearchMessages")
+		if update.CallbackQuery != nil {
+			utils.OnDataError(c, update.CallbackQuery.ID, loc)
+		}
+		return err
+	}
+
+	var text string
+	rows := [][]telego.InlineKeyboardButton{}
+	if len(msgs) == 0 {
+		text = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "search.notFound",
+			TemplateData: map[string]string{
+				"Query": html.EscapeString(query),
+			},
+		})
+	} else {
+		names := make(map[int64]string)
+
+		var result strings.Builder
+		for i, msg := range msgs {
+			name, ok := names[msg.Chat.ID]
+			if !ok {
+				chatResolve, err := h.service.FindChatName(c, msg.Chat.ID)
+				if err != nil {
+					name = strconv.FormatInt(msg.Chat.ID, 10)
+				} else {
+					name = chatResolve.Name
+				}
+				names[msg.Chat.ID] = name
+			}
+
+			result.WriteString(loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "search.item",
+				TemplateData: map[string]any{
+					"Count":            i + 1 + offset,
+					"ResolvedChatName": name,
+					"Date":             time.Unix(msg.Date, 0).UTC().Format(consts.DATETIME_FOR_MESSAGE),
+					"Message":          format.SummarizeDeletedMessage(msg, loc, true),
+				},
+			}))
+		}
+
+		text = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "search.message",
+			TemplateData: map[string]string{
+				"Query":  html.EscapeString(query),
+				"Result": result.String(),
+			},
+		})
+		text = format.CustomTruncateText(
+			text,
+			consts.MAX_LEN,
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "search.overflowDescription",
+			}),
+			false,
+		)
+
+		for i := 0; i < len(msgs); i += 2 {
+			row := make([]telego.InlineKeyboardButton, 0, 2)
+			for j := i; j < i+2 && j < len(msgs); j++ {
+				data := types.HandleDeletedMessageData{
+					MessageID: msgs[j].MessageID,
+					ChatID:    msgs[j].Chat.ID,
+				}
+
+				row = append(row, tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "search.resultItem",
+						TemplateData: map[string]int{
+							"Count": j + 1 + offset,
+						},
+					}),
+				).WithCallbackData(data.ToString(types.HandleDeletedMessageDataTypeStoredDetails)))
+			}
+			rows = append(rows, row)
+		}
+
+		if pagination.Backward || pagination.Forward {
+			backwardData := types.HandleSearchData{DataID: dataID, Offset: offset, TypeOfPagination: "b"}
+			forwardData := types.HandleSearchData{DataID: dataID, Offset: offset, TypeOfPagination: "f"}
+			rows = append(rows, keyboard.BuildPaginationRow(
+				loc,
+				pagination.Backward, backwardData.ToString(),
+				pagination.Forward, forwardData.ToString(),
+			))
+		}
+	}
+
+	return editOrSend(c, update, iUser.User.ID, text, rows)
+}
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"fmt"
+	"slices"
 	"ssuspy-bot/consts"
 	"ssuspy-bot/repository"
 	"ssuspy-bot/telegram/callbacks"
 	"ssuspy-bot/telegram/keyboard"
 	"ssuspy-bot/telegram/utils"
 
+	"github.com/dustin/go-humanize"
 	"github.com/mymmrac/telego"
 	th "github.com/mymmrac/telego/telegohandler"
 	tu "github.com/mymmrac/telego/telegoutil"
@@ -65,6 +67,9 @@ func (h *Handler) HandleSettings(c *th.Context, update telego.Update) error {
 			"PartnerDel":  status[iUser.User.Settings.ShowPartnerDeleted],
 			"MyEdit":      status[iUser.User.Settings.ShowMyEdits],
 			"PartnerEdit": status[iUser.User.Settings.ShowPartnerEdits],
+			"Retention":   retentionLabel(loc, iUser.User.Settings.RetentionDays),
+			"Digest":      digestLabel(loc, iUser.User.Settings.DigestMinutes),
+			"Capture":     status[iUser.User.Settings.CaptureProtected],
 		},
 	})
 
@@ -88,6 +93,48 @@ func (h *Handler) HandleSettings(c *th.Context, update telego.Update) error {
 					}),
 				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_EDITED),
 			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.buttons.chats",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_CHATS),
+			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.buttons.digest",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_DIGEST),
+			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.buttons.capture",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_CAPTURE),
+			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.buttons.retention",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_RETENTION),
+			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.buttons.webhook",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_WEBHOOK),
+			),
+			tu.InlineKeyboardRow(
+				tu.InlineKeyboardButton(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.buttons.usage",
+					}),
+				).WithCallbackData(consts.CALLBACK_PREFIX_SETTINGS_USAGE),
+			),
 			tu.InlineKeyboardRow(
 				keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_BACK_TO_START),
 			),
@@ -243,3 +290,309 @@ func (h *Handler) HandleSettingsEdited(c *th.Context, update telego.Update) erro
 	).WithParseMode(telego.ModeHTML).WithReplyMarkup(tu.InlineKeyboard(makeSettingsRows(loc, consts.CALLBACK_PREFIX_SETTINGS_EDITED, settings)...)))
 	return err
 }
+
+func retentionLabel(loc *i18n.Localizer, days int) string {
+	if days == 0 {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.retention.forever",
+		})
+	}
+
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.retention.days",
+		TemplateData: map[string]int{
+			"Count": days,
+		},
+		PluralCount: days,
+	})
+}
+
+func (h *Handler) HandleSettingsRetention(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+
+	needUpdate := true
+	data, err := callbacks.NewHandleSettingsDataFromString(query.Data)
+	if err != nil {
+		if err == callbacks.NoSettingsPartsError {
+			needUpdate = false
+		} else {
+			return err
+		}
+	}
+
+	if needUpdate {
+		if !slices.Contains(consts.RETENTION_DAYS, data) {
+			utils.OnDataError(c, query.ID, loc)
+			return fmt.Errorf("no retention option found")
+		}
+
+		iUser.User.Settings.RetentionDays = data
+		err = h.service.UpdateUserSettings(
+			c,
+			iUser.User.ID,
+			iUser.User.Settings,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	var rows [][]telego.InlineKeyboardButton
+	for _, days := range consts.RETENTION_DAYS {
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "settings.retention.option",
+					TemplateData: map[string]any{
+						"Label":  retentionLabel(loc, days),
+						"Status": iUser.User.Settings.RetentionDays == days,
+					},
+				}),
+			).WithCallbackData(fmt.Sprintf("%s|%d", consts.CALLBACK_PREFIX_SETTINGS_RETENTION, days)),
+		))
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_SETTINGS),
+	))
+
+	messageText := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.retention.message",
+		TemplateData: map[string]string{
+			"Retention": retentionLabel(loc, iUser.User.Settings.RetentionDays),
+		},
+	})
+
+	_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
+		tu.ID(iUser.User.ID),
+		query.Message.GetMessageID(),
+		messageText,
+	).WithParseMode(telego.ModeHTML).WithReplyMarkup(tu.InlineKeyboard(rows...)))
+	return err
+}
+
+func digestLabel(loc *i18n.Localizer, minutes int) string {
+	switch {
+	case minutes == 0:
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.digest.instant",
+		})
+	case minutes%(24*60) == 0:
+		days := minutes / (24 * 60)
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.digest.days",
+			TemplateData: map[string]int{
+				"Count": days,
+			},
+			PluralCount: days,
+		})
+	case minutes%60 == 0:
+		hours := minutes / 60
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.digest.hours",
+			TemplateData: map[string]int{
+				"Count": hours,
+			},
+			PluralCount: hours,
+		})
+	}
+
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.digest.minutes",
+		TemplateData: map[string]int{
+			"Count": minutes,
+		},
+		PluralCount: minutes,
+	})
+}
+
+func (h *Handler) HandleSettingsDigest(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+
+	needUpdate := true
+	data, err := callbacks.NewHandleSettingsDataFromString(query.Data)
+	if err != nil {
+		if err == callbacks.NoSettingsPartsError {
+			needUpdate = false
+		} else {
+			return err
+		}
+	}
+
+	if needUpdate {
+		if !slices.Contains(consts.DIGEST_WINDOWS, data) {
+			utils.OnDataError(c, query.ID, loc)
+			return fmt.Errorf("no digest window found")
+		}
+
+		iUser.User.Settings.DigestMinutes = data
+		err = h.service.UpdateUserSettings(
+			c,
+			iUser.User.ID,
+			iUser.User.Settings,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	var rows [][]telego.InlineKeyboardButton
+	for _, minutes := range consts.DIGEST_WINDOWS {
+		rows = append(rows, tu.InlineKeyboardRow(
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "settings.digest.option",
+					TemplateData: map[string]any{
+						"Label":  digestLabel(loc, minutes),
+						"Status": iUser.User.Settings.DigestMinutes == minutes,
+					},
+				}),
+			).WithCallbackData(fmt.Sprintf("%s|%d", consts.CALLBACK_PREFIX_SETTINGS_DIGEST, minutes)),
+		))
+	}
+	rows = append(rows, tu.InlineKeyboardRow(
+		keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_SETTINGS),
+	))
+
+	messageText := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.digest.message",
+		TemplateData: map[string]string{
+			"Digest": digestLabel(loc, iUser.User.Settings.DigestMinutes),
+		},
+	})
+
+	_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
+		tu.ID(iUser.User.ID),
+		query.Message.GetMessageID(),
+		messageText,
+	).WithParseMode(telego.ModeHTML).WithReplyMarkup(tu.InlineKeyboard(rows...)))
+	return err
+}
+
+func (h *Handler) HandleSettingsCapture(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+
+	needUpdate := true
+	data, err := callbacks.NewHandleSettingsDataFromString(query.Data)
+	if err != nil {
+		if err == callbacks.NoSettingsPartsError {
+			needUpdate = false
+		} else {
+			return err
+		}
+	}
+
+	if needUpdate {
+		if data != consts.SETTINGS_CAPTURE_PROTECTED {
+			utils.OnDataError(c, query.ID, loc)
+			return fmt.Errorf("no seting found")
+		}
+
+		iUser.User.Settings.CaptureProtected = !iUser.User.Settings.CaptureProtected
+		err = h.service.UpdateUserSettings(
+			c,
+			iUser.User.ID,
+			iUser.User.Settings,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	settings := []settingMeta{
+		{
+			messageID: "settings.capture.toggle",
+			status:    iUser.User.Settings.CaptureProtected,
+			data:      consts.SETTINGS_CAPTURE_PROTECTED,
+		},
+	}
+
+	status := map[bool]string{
+		true: loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.on",
+		}),
+		false: loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.off",
+		}),
+	}
+
+	messageText := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.capture.message",
+		TemplateData: map[string]string{
+			"Capture": status[iUser.User.Settings.CaptureProtected],
+		},
+	})
+
+	_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
+		tu.ID(iUser.User.ID),
+		query.Message.GetMessageID(),
+		messageText,
+	).WithParseMode(telego.ModeHTML).WithReplyMarkup(tu.InlineKeyboard(makeSettingsRows(loc, consts.CALLBACK_PREFIX_SETTINGS_CAPTURE, settings)...)))
+	return err
+}
+
+func usageLabel(loc *i18n.Localizer, used string, limit int64, format func(int64) string) string {
+	if limit == 0 {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.usage.unlimited",
+			TemplateData: map[string]string{
+				"Used": used,
+			},
+		})
+	}
+
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.usage.value",
+		TemplateData: map[string]string{
+			"Used":  used,
+			"Limit": format(limit),
+		},
+	})
+}
+
+func (h *Handler) HandleSettingsUsage(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+
+	limits, err := h.quota.Limits(c, iUser.BotUser.BotID)
+	if err != nil {
+		return err
+	}
+
+	bytes := func(n int64) string { return humanize.Bytes(uint64(n)) }
+	usage := iUser.BotUser.Usage
+
+	policy := "settings.usage.policyReject"
+	if limits.Policy == consts.QUOTA_POLICY_DROP_OLDEST {
+		policy = "settings.usage.policyDropOldest"
+	}
+
+	messageText := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.usage.message",
+		TemplateData: map[string]string{
+			"Messages":     usageLabel(loc, humanize.Comma(usage.Messages), limits.MaxMessages, humanize.Comma),
+			"MessageBytes": usageLabel(loc, bytes(usage.MessageBytes), limits.MaxMessageBytes, bytes),
+			"MediaBytes":   usageLabel(loc, bytes(usage.MediaBytes), limits.MaxMediaBytes, bytes),
+			"Policy": loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: policy,
+			}),
+		},
+	})
+
+	_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
+		tu.ID(iUser.User.ID),
+		query.Message.GetMessageID(),
+		messageText,
+	).WithParseMode(telego.ModeHTML).WithReplyMarkup(tu.InlineKeyboard(
+		tu.InlineKeyboardRow(
+			keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_SETTINGS),
+		),
+	)))
+	return err
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/repository"
+	"ssuspy-bot/telegram/callbacks"
+	"ssuspy-bot/telegram/keyboard"
+	"ssuspy-bot/telegram/utils"
+	"ssuspy-bot/webhooks"
+	"ssuspy-common/telegram/format"
+)
+
+// HandleSettingsWebhook - экран вебхука в настройках. Адрес задается командой /webhook <url>,
+// остальное кнопками
+func (h *Handler) HandleSettingsWebhook(c *th.Context, update telego.Update) error {
+	loc := c.Value("loc").(*i18n.Localizer)
+	iUser := c.Value("iUser").(*repository.IUser)
+	log := c.Value("log").(*zerolog.Logger)
+	ctx := context.Background()
+
+	if update.CallbackQuery == nil {
+		_, _, payload := tu.ParseCommandPayload(update.Message.Text)
+		webhookURL := strings.TrimSpace(payload)
+
+		if webhookURL != "" {
+			if err := webhooks.ValidateURL(webhookURL); err != nil {
+				_, err = c.Bot().SendMessage(c, tu.Message(
+					tu.ID(iUser.User.ID),
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.webhook.invalid",
+						TemplateData: map[string]string{
+							"Error": html.EscapeString(err.Error()),
+						},
+					}),
+				).WithParseMode(telego.ModeHTML))
+				return err
+			}
+
+			secret, err := webhooks.NewSecret()
+			if err != nil {
+				return err
+			}
+			if err := h.service.SetWebhookURL(ctx, iUser.User.ID, webhookURL, secret); err != nil {
+				log.Error().Err(err).Msg("failed SetWebhookURL")
+				return err
+			}
+		}
+	}
+
+	webhook, err := h.service.GetWebhook(ctx, iUser.User.ID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		webhook, err = nil, nil
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("failed GetWebhook")
+		return err
+	}
+
+	if update.CallbackQuery != nil {
+		query := update.CallbackQuery
+
+		data, err := callbacks.NewHandleSettingsDataFromString(query.Data)
+		switch {
+		case err == callbacks.NoSettingsPartsError:
+		case err != nil:
+			return err
+		case webhook == nil:
+			utils.OnDataError(c, query.ID, loc)
+			return fmt.Errorf("no webhook found")
+		default:
+			switch data {
+			case consts.WEBHOOK_TOGGLE_DELETED:
+				webhook.Deleted = !webhook.Deleted
+				err = h.service.UpdateWebhookEvents(ctx, iUser.User.ID, webhook.Deleted, webhook.Edited)
+			case consts.WEBHOOK_TOGGLE_EDITED:
+				webhook.Edited = !webhook.Edited
+				err = h.service.UpdateWebhookEvents(ctx, iUser.User.ID, webhook.Deleted, webhook.Edited)
+			case consts.WEBHOOK_ROTATE_SECRET:
+				webhook.Secret, err = webhooks.NewSecret()
+				if err == nil {
+					err = h.service.UpdateWebhookSecret(ctx, iUser.User.ID, webhook.Secret)
+				}
+			case consts.WEBHOOK_TEST:
+				if err := webhooks.EnqueueTest(ctx, h.service, h.rdb, c.Value("botID").(int64), iUser.User.ID); err != nil {
+					log.Error().Err(err).Msg("failed enqueue test webhook")
+					utils.OnDataError(c, query.ID, loc)
+					return err
+				}
+				// экран не меняется, поэтому только показываем уведомление
+				return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(query.ID).WithText(
+					loc.MustLocalize(&i18n.LocalizeConfig{
+						MessageID: "settings.webhook.testQueued",
+					}),
+				))
+			case consts.WEBHOOK_REMOVE:
+				err = h.service.DeleteWebhook(ctx, iUser.User.ID)
+				webhook = nil
+			default:
+				utils.OnDataError(c, query.ID, loc)
+				return fmt.Errorf("no webhook action found")
+			}
+			if err != nil {
+				log.Error().Err(err).Int("action", data).Msg("failed update webhook")
+				utils.OnDataError(c, query.ID, loc)
+				return err
+			}
+		}
+	}
+
+	text, rows := webhookSettingsView(loc, webhook)
+	return editOrSend(c, update, iUser.User.ID, text, rows)
+}
+
+func webhookSettingsView(loc *i18n.Localizer, webhook *repository.Webhook) (string, [][]telego.InlineKeyboardButton) {
+	if webhook == nil {
+		text := loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.webhook.empty",
+		})
+		return text, [][]telego.InlineKeyboardButton{
+			tu.InlineKeyboardRow(
+				keyboard.BuildBackButton(loc, consts.CALLBACK_PREFIX_SETTINGS),
+			),
+		}
+	}
+
+	status := map[bool]string{
+		true: loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.on",
+		}),
+		false: loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.off",
+		}),
+	}
+
+	var lastDelivery string
+	switch {
+	case webhook.LastDeliveryAt == 0:
+		lastDelivery = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.webhook.never",
+		})
+	case webhook.LastError == "":
+		lastDelivery = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.webhook.delivered",
+			TemplateData: map[string]string{
+				"Date": time.Unix(webhook.LastDeliveryAt, 0).UTC().Format(consts.DATETIME_FOR_MESSAGE),
+			},
+		})
+	default:
+		lastDelivery = loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "settings.webhook.failed",
+			TemplateData: map[string]string{
+				"Date":  time.Unix(webhook.LastDeliveryAt, 0).UTC().Format(consts.DATETIME_FOR_MESSAGE),
+				"Error": html.EscapeString(format.TruncateText(webhook.LastError, consts.MAX_MESSAGE_TEXT_LEN, true)),
+			},
+		})
+	}
+
+	text := loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "settings.webhook.message",
+		TemplateData: map[string]string{
+			"URL":          html.EscapeString(webhook.URL),
+			"Secret":       webhook.Secret,
+			"Deleted":      status[webhook.Deleted],
+			"Edited":       status[webhook.Edited],
+			"LastDelivery": lastDelivery,
+		},
+	})
+
+	rows := makeSettingsRows(loc, consts.CALLBACK_PREFIX_SETTINGS_WEBHOOK, []settingMeta{
+		{
+			messageID: "settings.webhook.deleted",
+			status:    webhook.Deleted,
+			data:      consts.WEBHOOK_TOGGLE_DELETED,
+		},
+		{
+			messageID: "settings.webhook.edited",
+			status:    webhook.Edited,
+			data:      consts.WEBHOOK_TOGGLE_EDITED,
+		},
+	})
+
+	actionButton := func(messageID string, action int) telego.InlineKeyboardButton {
+		return tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: messageID,
+			}),
+		).WithCallbackData(fmt.Sprintf("%s|%d", consts.CALLBACK_PREFIX_SETTINGS_WEBHOOK, action))
+	}
+
+	// кнопка "назад" из makeSettingsRows остается последней
+	rows = slices.Insert(rows, len(rows)-1,
+		tu.InlineKeyboardRow(
+			actionButton("settings.webhook.buttons.test", consts.WEBHOOK_TEST),
+			actionButton("settings.webhook.buttons.rotate", consts.WEBHOOK_ROTATE_SECRET),
+		),
+		tu.InlineKeyboardRow(
+			actionButton("settings.webhook.buttons.remove", consts.WEBHOOK_REMOVE),
+		),
+	)
+
+	return text, rows
+}
@@ -36,3 +36,29 @@ func BuildBackButton(loc *i18n.Localizer, data string) telego.InlineKeyboardButt
 		}),
 	).WithCallbackData(data)
 }
+
+// BuildPaginationRow собирает ряд из стрелок назад/вперед, пустой ряд если листать некуда
+func BuildPaginationRow(loc *i18n.Localizer, backward bool, backwardData string, forward bool, forwardData string) []telego.InlineKeyboardButton {
+	row := make([]telego.InlineKeyboardButton, 0, 2)
+	if backward {
+		row = append(
+			row,
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "arrow.backward",
+				}),
+			).WithCallbackData(backwardData),
+		)
+	}
+	if forward {
+		row = append(
+			row,
+			tu.InlineKeyboardButton(
+				loc.MustLocalize(&i18n.LocalizeConfig{
+					MessageID: "arrow.forward",
+				}),
+			).WithCallbackData(forwardData),
+		)
+	}
+	return row
+}
@@ -5,14 +5,19 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"ssuspy-bot/archive"
 	"ssuspy-bot/config"
 	"ssuspy-bot/consts"
+	"ssuspy-bot/events"
+	"ssuspy-bot/quota"
 	"ssuspy-bot/redis"
 	"ssuspy-bot/repository"
 	"ssuspy-bot/telegram/handlers"
 	"ssuspy-bot/telegram/middleware"
 	"ssuspy-bot/telegram/utils"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mymmrac/telego"
@@ -27,48 +32,135 @@ type BotInstance struct {
 	Handler *th.BotHandler
 	Updates <-chan telego.Update
 	Cancel  context.CancelFunc
-	Running bool
+
+	StartedAt time.Time
+	// running читается из обработчиков статуса без блокировки менеджера
+	running atomic.Bool
+	// lastUpdate - unix nano последнего полученного обновления, 0 - еще не было
+	lastUpdate atomic.Int64
+	lastError  atomic.Pointer[BotError]
+}
+
+type BotError struct {
+	Message string
+	At      time.Time
+}
+
+func (i *BotInstance) Running() bool {
+	return i.running.Load()
+}
+
+func (i *BotInstance) LastUpdate() time.Time {
+	nano := i.lastUpdate.Load()
+	if nano == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nano)
+}
+
+// LastError - последняя ошибка обработки обновлений, nil если ошибок не было
+func (i *BotInstance) LastError() *BotError {
+	return i.lastError.Load()
+}
+
+var allowedUpdates = []string{
+	"update_id",
+	"message",
+	"business_connection",
+	"business_message",
+	"edited_business_message",
+	"deleted_business_messages",
+	"my_chat_member",
+	"callback_query",
+	"inline_query",
+	"chosen_inline_result",
 }
 
 type BotManager struct {
 	service *repository.MongoRepository
 	rdb     *redis.Redis
-
-	bots    map[int64]*BotInstance
-	mutex   sync.RWMutex
-	mux     *http.ServeMux
-	baseURL string
+	bus     *events.Bus
+	// storage - архив медиа, nil если он выключен
+	storage archive.Storage
+	quota   *quota.Enforcer
+
+	bots map[int64]*BotInstance
+	// starting - боты, которые сейчас запускаются. AddBot ходит в API без блокировки,
+	// и по starting второй запуск того же бота отклоняется
+	starting map[int64]bool
+	mutex    sync.RWMutex
+	webhooks *webhookRouter
+	updates  *config.UpdatesConfig
+
+	// clients - API клиенты ботов, запущенных на других репликах
+	clients      map[int64]*telego.Bot
+	clientsMutex sync.Mutex
 }
 
-func NewBotManager(service *repository.MongoRepository, rdb *redis.Redis, mux *http.ServeMux, baseURL string) *BotManager {
-	return &BotManager{
+func NewBotManager(
+	service *repository.MongoRepository,
+	rdb *redis.Redis,
+	bus *events.Bus,
+	storage archive.Storage,
+	quota *quota.Enforcer,
+	mux *http.ServeMux,
+	updates *config.UpdatesConfig,
+) *BotManager {
+	b := &BotManager{
 		bots:    make(map[int64]*BotInstance),
-		mux:     mux,
-		baseURL: baseURL,
+		clients: make(map[int64]*telego.Bot),
+		updates: updates,
 		service: service,
 		rdb:     rdb,
+		bus:     bus,
+		storage: storage,
+		quota:   quota,
+
+		starting: make(map[int64]bool),
 	}
+	b.webhooks = newWebhookRouter(mux, b.webhookSecret)
+	return b
+}
+
+// SetWebhookForwarder включает пересылку вебхуков ботов, запущенных на других репликах.
+// Пересылаются только обновления ботов, для которых held вернул true
+func (b *BotManager) SetWebhookForwarder(forward WebhookForwarder, held WebhookHeld) {
+	b.webhooks.setForwarder(forward, held)
+}
+
+// HandleForwardedUpdate передает запущенному здесь боту обновление, пересланное другой репликой
+func (b *BotManager) HandleForwardedUpdate(ctx context.Context, botID int64, data []byte) error {
+	route, exists := b.webhooks.route(botID)
+	if !exists {
+		return ErrBotNotHere
+	}
+	return route.handler(ctx, data)
+}
+
+func (b *BotManager) webhookSecret(ctx context.Context, botID int64) (string, error) {
+	bot, err := b.Client(ctx, botID)
+	if err != nil {
+		return "", err
+	}
+	return bot.SecretToken(), nil
 }
 
 func (b *BotManager) AddBot(ctx context.Context, botID int64, token string) error {
 	b.mutex.Lock()
-	defer b.mutex.Unlock()
-
-	if _, exists := b.bots[botID]; exists {
+	if _, exists := b.bots[botID]; exists || b.starting[botID] {
+		b.mutex.Unlock()
 		return fmt.Errorf("bot with ID %d already exists", botID)
 	}
+	b.starting[botID] = true
+	b.mutex.Unlock()
 
-	bot, err := telego.NewBot(
-		token,
-		telego.WithAPICaller(&ta.RetryCaller{
-			Caller:       ta.DefaultFastHTTPCaller,
-			MaxAttempts:  4,
-			ExponentBase: 2,
-			StartDelay:   time.Millisecond * 10,
-			MaxDelay:     time.Second,
-		}),
-		telego.WithAPIServer(config.Config.TelegramBot.ApiURL),
-	)
+	defer func() {
+		b.mutex.Lock()
+		delete(b.starting, botID)
+		b.mutex.Unlock()
+	}()
+
+	bot, err := newBot(token)
 	if err != nil {
 		return fmt.Errorf("failed to create bot: %w", err)
 	}
@@ -87,6 +179,26 @@ func (b *BotManager) AddBot(ctx context.Context, botID int64, token string) erro
 			Command:     "start",
 			Description: "main menu",
 		},
+		{
+			Command:     "search",
+			Description: "search stored messages",
+		},
+		{
+			Command:     "history",
+			Description: "browse stored chats",
+		},
+		{
+			Command:     "export",
+			Description: "export chats to an archive",
+		},
+		{
+			Command:     "webhook",
+			Description: "send deletions and edits to my url",
+		},
+		{
+			Command:     "forget_me",
+			Description: "delete all my data",
+		},
 	}
 
 	if config.Config.BusinessGithubURL != "" {
@@ -103,36 +215,12 @@ func (b *BotManager) AddBot(ctx context.Context, botID int64, token string) erro
 		log.Warn().Int64("botID", botID).Err(err).Msg("failed set bot commands")
 	}
 
-	webhookURL := fmt.Sprintf("%s/bot_%d", b.baseURL, botID)
-	webhookPath := fmt.Sprintf("POST /bot_%d", botID)
-
 	botCtx, botCancel := context.WithCancel(context.Background())
 
-	updates, err := bot.UpdatesViaWebhook(
-		botCtx,
-		telego.WebhookHTTPServeMux(b.mux, webhookPath, bot.SecretToken()),
-		telego.WithWebhookBuffer(128),
-		telego.WithWebhookSet(ctx, &telego.SetWebhookParams{
-			URL:         webhookURL,
-			SecretToken: bot.SecretToken(),
-			AllowedUpdates: []string{
-				"update_id",
-				"message",
-				"business_connection",
-				"business_message",
-				"edited_business_message",
-				"deleted_business_messages",
-				"my_chat_member",
-				"callback_query",
-				"inline_query",
-				"chosen_inline_result",
-			},
-			DropPendingUpdates: false,
-		}),
-	)
+	updates, err := b.startUpdates(ctx, botCtx, bot, botID)
 	if err != nil {
 		botCancel()
-		return fmt.Errorf("failed to setup webhook updates: %w", err)
+		return err
 	}
 
 	botHandler, err := th.NewBotHandler(bot, updates)
@@ -147,8 +235,10 @@ func (b *BotManager) AddBot(ctx context.Context, botID int64, token string) erro
 		Handler: botHandler,
 		Updates: updates,
 		Cancel:  botCancel,
-		Running: true,
+
+		StartedAt: time.Now(),
 	}
+	instance.running.Store(true)
 
 	b.setupBotHandlers(instance)
 
@@ -162,13 +252,109 @@ func (b *BotManager) AddBot(ctx context.Context, botID int64, token string) erro
 		}
 	}()
 
+	b.mutex.Lock()
 	b.bots[botID] = instance
+	b.mutex.Unlock()
 
-	log.Debug().Int64("botID", botID).Str("webhookURL", webhookURL).Msg("bot started successfully")
+	log.Debug().Int64("botID", botID).Str("updatesMode", b.updates.Mode).Msg("bot started successfully")
 	return nil
 }
 
+// startUpdates подписывается на обновления бота в выбранном режиме, ctx - для запросов к API,
+// botCtx живет пока работает бот
+func (b *BotManager) startUpdates(ctx context.Context, botCtx context.Context, bot *telego.Bot, botID int64) (<-chan telego.Update, error) {
+	if b.updates.Mode == consts.UPDATES_MODE_POLLING {
+		// getUpdates не работает, пока у бота установлен вебхук
+		if err := bot.DeleteWebhook(ctx, &telego.DeleteWebhookParams{DropPendingUpdates: false}); err != nil {
+			return nil, fmt.Errorf("failed to delete webhook before long polling: %w", err)
+		}
+
+		updates, err := bot.UpdatesViaLongPolling(
+			botCtx,
+			&telego.GetUpdatesParams{
+				Timeout:        30,
+				AllowedUpdates: allowedUpdates,
+			},
+			telego.WithLongPollingBuffer(128),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup long polling updates: %w", err)
+		}
+		return updates, nil
+	}
+
+	// ServeMux не разбирает шаблоны вроде /bot_{id}, поэтому ID - отдельный сегмент пути
+	webhookURL := fmt.Sprintf("%s/bot/%d", strings.TrimSuffix(b.updates.WebhookURL, "/"), botID)
+	updates, err := bot.UpdatesViaWebhook(
+		botCtx,
+		b.webhooks.server(botID, bot.SecretToken()),
+		telego.WithWebhookBuffer(128),
+		telego.WithWebhookSet(ctx, &telego.SetWebhookParams{
+			URL:                webhookURL,
+			SecretToken:        bot.SecretToken(),
+			AllowedUpdates:     allowedUpdates,
+			DropPendingUpdates: false,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup webhook updates: %w", err)
+	}
+	return updates, nil
+}
+
+func newBot(token string) (*telego.Bot, error) {
+	return telego.NewBot(
+		token,
+		telego.WithAPICaller(&ta.RetryCaller{
+			Caller:       ta.DefaultFastHTTPCaller,
+			MaxAttempts:  4,
+			ExponentBase: 2,
+			StartDelay:   time.Millisecond * 10,
+			MaxDelay:     time.Second,
+		}),
+		telego.WithAPIServer(config.Config.TelegramBot.ApiURL),
+	)
+}
+
+// Client отдает API клиент бота для отправки сообщений: запущенного здесь или,
+// если бот работает на другой реплике, отдельный клиент без получения обновлений
+func (b *BotManager) Client(ctx context.Context, botID int64) (*telego.Bot, error) {
+	if instance, exists := b.GetBot(botID); exists {
+		return instance.Bot, nil
+	}
+
+	b.clientsMutex.Lock()
+	defer b.clientsMutex.Unlock()
+
+	if bot, exists := b.clients[botID]; exists {
+		return bot, nil
+	}
+
+	botData, err := b.service.BotByID(ctx, botID)
+	if err != nil {
+		return nil, fmt.Errorf("failed get bot %d: %w", botID, err)
+	}
+	bot, err := newBot(botData.SecretToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+
+	b.clients[botID] = bot
+	return bot, nil
+}
+
+// RemoveBot останавливает бота и снимает его вебхук: бот удален и больше нигде не запустится
 func (b *BotManager) RemoveBot(botID int64) error {
+	return b.stopBot(botID, true)
+}
+
+// StopBot останавливает бота только на этой реплике. Вебхук не трогается: бот переезжает
+// и его новая реплика, возможно, уже поставила свой
+func (b *BotManager) StopBot(botID int64) error {
+	return b.stopBot(botID, false)
+}
+
+func (b *BotManager) stopBot(botID int64, deleteWebhook bool) error {
 	b.mutex.Lock()
 	defer b.mutex.Unlock()
 
@@ -181,19 +367,26 @@ func (b *BotManager) RemoveBot(botID int64) error {
 		instance.Cancel()
 	}
 	instance.Handler.Stop()
-	instance.Running = false
+	instance.running.Store(false)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	if deleteWebhook && b.updates.Mode == consts.UPDATES_MODE_WEBHOOK {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
 
-	err := instance.Bot.DeleteWebhook(ctx, &telego.DeleteWebhookParams{
-		DropPendingUpdates: false,
-	})
-	if err != nil {
-		log.Warn().Err(err).Int64("botID", botID).Msg("failed to delete webhook")
+		err := instance.Bot.DeleteWebhook(ctx, &telego.DeleteWebhookParams{
+			DropPendingUpdates: false,
+		})
+		if err != nil {
+			log.Warn().Err(err).Int64("botID", botID).Msg("failed to delete webhook")
+		}
 	}
 
 	delete(b.bots, botID)
+	b.webhooks.remove(botID)
+
+	b.clientsMutex.Lock()
+	delete(b.clients, botID)
+	b.clientsMutex.Unlock()
 
 	log.Info().Int64("botID", botID).Msg("bot stopped and removed successfully")
 	return nil
@@ -219,6 +412,16 @@ func (b *BotManager) ListBots() []int64 {
 }
 
 func (b *BotManager) setupBotHandlers(instance *BotInstance) {
+	// снаружи recovery, чтобы паники тоже попадали в последнюю ошибку
+	instance.Handler.Use(func(c *th.Context, update telego.Update) error {
+		instance.lastUpdate.Store(time.Now().UnixNano())
+
+		err := c.Next(update)
+		if err != nil {
+			instance.lastError.Store(&BotError{Message: err.Error(), At: time.Now()})
+		}
+		return err
+	})
 	instance.Handler.Use(th.PanicRecoveryHandler(middleware.LogPanicHandler))
 
 	middlewareGroup := middleware.NewMiddlewareGroup(b.service, b.rdb)
@@ -226,7 +429,7 @@ func (b *BotManager) setupBotHandlers(instance *BotInstance) {
 	instance.Handler.Use(middleware.SkipNonPrivateChatsMiddleware)
 	instance.Handler.Use(middlewareGroup.GetInternalUserMiddleware)
 
-	handlerGroup := handlers.NewHandlerGroup(b.service, b.rdb)
+	handlerGroup := handlers.NewHandlerGroup(b.service, b.rdb, b.bus, b.storage, b.quota)
 	instance.Handler.Handle(utils.WithProm("handleBlocked", handlerGroup.HandleBlocked), th.AnyMyChatMember())
 
 	{
@@ -282,6 +485,54 @@ func (b *BotManager) setupBotHandlers(instance *BotInstance) {
 		if config.Config.BusinessGithubURL != "" {
 			standard.Handle(utils.WithProm("handleGithub", handlers.HandleGithub), th.CommandEqual("github"))
 		}
+		standard.Handle(
+			utils.WithProm("handleSearch", handlerGroup.HandleSearch),
+			th.Or(
+				th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SEARCH),
+				th.CommandEqual("search"),
+			),
+		)
+		standard.Handle(
+			utils.WithProm("handleHistory", handlerGroup.HandleHistory),
+			th.Or(
+				th.CallbackDataPrefix(consts.CALLBACK_PREFIX_HISTORY),
+				th.CommandEqual("history"),
+			),
+		)
+		standard.Handle(
+			utils.WithProm("handleHistoryChat", handlerGroup.HandleHistoryChat),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_HISTORY_CHAT),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleHistoryMessage", handlerGroup.HandleHistoryMessage),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_HISTORY_MESSAGE),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleExport", handlerGroup.HandleExport),
+			th.Or(
+				th.CallbackDataPrefix(consts.CALLBACK_PREFIX_EXPORT),
+				th.CommandEqual("export"),
+			),
+		)
+		standard.Handle(
+			utils.WithProm("handleExportFormat", handlerGroup.HandleExportFormat),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_EXPORT_FORMAT),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleExportStart", handlerGroup.HandleExportStart),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_EXPORT_START),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleForgetMe", handlerGroup.HandleForgetMe),
+			th.Or(
+				th.CallbackDataEqual(consts.CALLBACK_PREFIX_FORGET_ME),
+				th.CommandEqual("forget_me"),
+			),
+		)
 		standard.Handle(
 			utils.WithProm("handleSettings", handlerGroup.HandleSettings),
 			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS),
@@ -297,6 +548,43 @@ func (b *BotManager) setupBotHandlers(instance *BotInstance) {
 			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_EDITED),
 			th.AnyCallbackQueryWithMessage(),
 		)
+		standard.Handle(
+			utils.WithProm("handleSettingsRetention", handlerGroup.HandleSettingsRetention),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_RETENTION),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleSettingsDigest", handlerGroup.HandleSettingsDigest),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_DIGEST),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleSettingsCapture", handlerGroup.HandleSettingsCapture),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_CAPTURE),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleSettingsChats", handlerGroup.HandleSettingsChats),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_CHATS),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleSettingsChat", handlerGroup.HandleSettingsChat),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_CHAT),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleSettingsUsage", handlerGroup.HandleSettingsUsage),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_USAGE),
+			th.AnyCallbackQueryWithMessage(),
+		)
+		standard.Handle(
+			utils.WithProm("handleSettingsWebhook", handlerGroup.HandleSettingsWebhook),
+			th.Or(
+				th.CallbackDataPrefix(consts.CALLBACK_PREFIX_SETTINGS_WEBHOOK),
+				th.CommandEqual("webhook"),
+			),
+		)
 		standard.Handle(
 			utils.WithProm("handleLanguage", handlers.HandleLanguage),
 			th.CallbackDataEqual(consts.CALLBACK_PREFIX_LANG),
@@ -323,12 +611,23 @@ func (b *BotManager) setupBotHandlers(instance *BotInstance) {
 			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_DELETED_DETAILS),
 			th.AnyCallbackQueryWithMessage(),
 		)
+		standard.Handle(
+			utils.WithProm("handleStoredMessageDetails", handlerGroup.HandleStoredMessageDetails),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_STORED_DETAILS),
+			th.AnyCallbackQueryWithMessage(),
+		)
 		standard.Handle(
 			utils.WithProm("handleGetDeletedFiles", handlerGroup.HandleGetDeletedFiles),
 			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_DELETED_FILES),
 			th.AnyCallbackQueryWithMessage(),
 		)
 
+		standard.Handle(
+			utils.WithProm("handleEditedTimeline", handlerGroup.HandleEditedTimeline),
+			th.CallbackDataPrefix(consts.CALLBACK_PREFIX_EDITED_TIMELINE),
+			th.AnyCallbackQueryWithMessage(),
+		)
+
 		edited := standard.Group(th.AnyCallbackQueryWithMessage())
 		edited.Use(middlewareGroup.EditedGetMessages)
 		edited.Handle(
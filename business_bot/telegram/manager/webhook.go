@@ -0,0 +1,167 @@
+package manager
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/mymmrac/telego"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrBotNotHere = errors.New("bot is not running on this replica")
+
+// WebhookForwarder отправляет обновление бота реплике, на которой он запущен
+type WebhookForwarder func(ctx context.Context, botID int64, data []byte) error
+
+// WebhookHeld - бот запущен на другой реплике. Проверяется до секрета вебхука, чтобы запросы
+// на произвольные ID не доходили до mongo
+type WebhookHeld func(ctx context.Context, botID int64) (bool, error)
+
+type webhookRoute struct {
+	secretToken string
+	handler     telego.WebhookHandler
+}
+
+// webhookRouter - один маршрут POST /bot/{id} на все боты. http.ServeMux не умеет снимать
+// обработчики, а бот может переехать на другую реплику и вернуться обратно
+type webhookRouter struct {
+	routes map[int64]webhookRoute
+	mutex  sync.RWMutex
+
+	// forward - пересылка обновлений ботов других реплик, nil - отвечать 404.
+	// held - запущен ли бот на другой реплике, secretToken - секрет вебхука бота, который здесь не запущен
+	forward     WebhookForwarder
+	held        WebhookHeld
+	secretToken func(ctx context.Context, botID int64) (string, error)
+}
+
+func newWebhookRouter(mux *http.ServeMux, secretToken func(ctx context.Context, botID int64) (string, error)) *webhookRouter {
+	router := &webhookRouter{
+		routes:      make(map[int64]webhookRoute),
+		secretToken: secretToken,
+	}
+	mux.HandleFunc("POST /bot/{id}", router.serve)
+	return router
+}
+
+// server - замена telego.WebhookHTTPServeMux для UpdatesViaWebhook
+func (w *webhookRouter) server(botID int64, secretToken string) func(handler telego.WebhookHandler) error {
+	return func(handler telego.WebhookHandler) error {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		w.routes[botID] = webhookRoute{
+			secretToken: secretToken,
+			handler:     handler,
+		}
+		return nil
+	}
+}
+
+func (w *webhookRouter) setForwarder(forward WebhookForwarder, held WebhookHeld) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.forward = forward
+	w.held = held
+}
+
+func (w *webhookRouter) route(botID int64) (webhookRoute, bool) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	route, exists := w.routes[botID]
+	return route, exists
+}
+
+func (w *webhookRouter) remove(botID int64) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	delete(w.routes, botID)
+}
+
+func (w *webhookRouter) serve(writer http.ResponseWriter, request *http.Request) {
+	defer func() { _ = request.Body.Close() }()
+
+	botID, err := strconv.ParseInt(request.PathValue("id"), 10, 64)
+	if err != nil {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.mutex.RLock()
+	route, exists := w.routes[botID]
+	forward, held := w.forward, w.held
+	w.mutex.RUnlock()
+	if !exists {
+		w.serveForward(writer, request, botID, forward, held)
+		return
+	}
+
+	if route.secretToken != request.Header.Get(telego.WebhookSecretTokenHeader) {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err = route.handler(request.Context(), data); err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
+
+// serveForward пересылает обновление бота, который запущен на другой реплике: у всех реплик
+// один адрес вебхуков, и Telegram может прислать обновление любой из них
+func (w *webhookRouter) serveForward(writer http.ResponseWriter, request *http.Request, botID int64, forward WebhookForwarder, held WebhookHeld) {
+	if forward == nil {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	// аренда в redis есть только у запущенных ботов, так что неизвестные ID отсекаются без mongo.
+	// Бот в процессе переезда тоже сюда попадет, Telegram повторит обновление после ошибки
+	isHeld, err := held(request.Context(), botID)
+	if err != nil {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	if !isHeld {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	secretToken, err := w.secretToken(request.Context(), botID)
+	if err != nil {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if secretToken != request.Header.Get(telego.WebhookSecretTokenHeader) {
+		writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	data, err := io.ReadAll(request.Body)
+	if err != nil {
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// бот может быть в процессе переезда, Telegram повторит обновление после ошибки
+	if err = forward(request.Context(), botID, data); err != nil {
+		log.Debug().Err(err).Int64("botID", botID).Msg("failed forward webhook update")
+		writer.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	writer.WriteHeader(http.StatusOK)
+}
@@ -43,6 +43,9 @@ func (h *MiddlewareGroup) EditedGetMessages(c *th.Context, update telego.Update)
 			WithEdits:     true,
 		},
 	)
+	if err == nil {
+		err = h.service.OpenMessages(iUser.User.ID, msgs...)
+	}
 	if err != nil {
 		log.Error().Err(err).Int64("userID", iUser.User.ID).Msg("Error GetMessages for edited log")
 		utils.OnDataError(c, query.ID, loc)
@@ -54,28 +57,7 @@ func (h *MiddlewareGroup) EditedGetMessages(c *th.Context, update telego.Update)
 		return c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(query.ID))
 	}
 
-	var (
-		newMsg *telego.Message
-		oldMsg *telego.Message
-	)
-
-	for _, msg := range msgs {
-		if oldMsg == nil {
-			if (result.OldDateIsEdit && msg.EditDate == result.OldDate) ||
-				(!result.OldDateIsEdit && msg.Date == result.OldDate && msg.EditDate == 0) {
-				oldMsg = msg
-			}
-		}
-
-		if newMsg == nil && msg.EditDate == result.NewDate {
-			newMsg = msg
-		}
-
-		if oldMsg != nil && newMsg != nil {
-			break
-		}
-	}
-
+	oldMsg, newMsg := result.Versions(msgs)
 	if oldMsg == nil || newMsg == nil {
 		utils.OnDataError(c, query.ID, loc)
 	}
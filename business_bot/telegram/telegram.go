@@ -3,44 +3,92 @@ package telegram
 import (
 	"context"
 	"net/http"
+	"ssuspy-bot/archive"
 	"ssuspy-bot/config"
+	"ssuspy-bot/consts"
+	"ssuspy-bot/events"
 	"ssuspy-bot/grpc_server"
+	"ssuspy-bot/health"
+	"ssuspy-bot/quota"
 	"ssuspy-bot/redis"
 	"ssuspy-bot/repository"
+	"ssuspy-bot/shard"
+	"ssuspy-bot/telegram/digest"
 	"ssuspy-bot/telegram/files"
 	"ssuspy-bot/telegram/manager"
+	"ssuspy-bot/webhooks"
+	"ssuspy-common/grpcauth"
 
 	"github.com/rs/zerolog/log"
 )
 
-const (
-	BUSINESS_URL = "http://business-bot:8080"
-)
+// RunTelegram запускает ботов, которые достались этой реплике, воркеры и gRPC сервер.
+// Возвращает координатор, чтобы при остановке отдать ботов другим репликам
+func RunTelegram(ctx context.Context, mux *http.ServeMux, mongo *repository.MongoRepository, rdb *redis.Redis) *shard.Coordinator {
+	bus := events.NewBus()
+
+	// интерфейс остается nil, если архив выключен
+	var storage archive.Storage
+	if config.Config.Archive.Enabled {
+		archiveStorage, err := archive.NewStorage(config.Config.Archive)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed init media archive storage")
+		}
+		storage = archiveStorage
+		go archive.RunCleanup(ctx, mongo, storage, config.Config.Archive.CleanupInterval)
+	}
 
-func RunTelegram(ctx context.Context, mux *http.ServeMux, mongo *repository.MongoRepository, rdb *redis.Redis) {
-	mng := manager.NewBotManager(mongo, rdb, mux, BUSINESS_URL)
+	enforcer := quota.NewEnforcer(mongo, config.Config.Quota)
+	mng := manager.NewBotManager(mongo, rdb, bus, storage, enforcer, mux, config.Config.Updates)
 
-	bots, err := mongo.AllBots(ctx)
+	grpcTLS, grpcToken := config.Config.Grpc.TLS(), config.Config.Grpc.Token
+	if !grpcauth.Secured(grpcTLS, grpcToken) {
+		log.Warn().Msg("gRPC server is not protected, set GRPC_TLS_* or GRPC_TOKEN")
+	}
+	serverOptions, err := grpcauth.ServerOptions(grpcTLS, grpcToken)
 	if err != nil {
-		log.Fatal().Err(err).Msg("failed to load bots from database")
-	} else {
-		for _, bot := range bots {
-			err := mng.AddBot(ctx, bot.ID, bot.SecretToken)
-			if err != nil {
-				log.Error().Err(err).Int64("botID", bot.ID).Msg("Failed to start bot from database")
-			}
-		}
+		log.Fatal().Err(err).Msg("invalid gRPC security configuration")
+	}
+	dialOptions, err := grpcauth.DialOptions(grpcTLS, grpcToken)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid gRPC security configuration")
 	}
 
-	filesWorker := files.NewWorker(mongo, rdb, mng)
+	coordinator := shard.NewCoordinator(rdb, mongo, mng, config.Config.Shard, dialOptions)
+	log.Info().Str("replicaID", coordinator.ReplicaID()).Msg("joining shard")
+	// у всех реплик один адрес вебхуков, обновления чужих ботов уходят их владельцам
+	mng.SetWebhookForwarder(coordinator.ForwardUpdate, coordinator.HeldElsewhere)
+	go coordinator.Run(ctx)
+
+	health.Register(mux, mongo, rdb, mng, config.Config.Health)
+
+	filesWorker := files.NewWorker(
+		mongo,
+		rdb,
+		mng,
+		config.Config.FilesQueue,
+		files.NewFetcher(config.Config.FilesFetcher),
+		storage,
+	)
+	go filesWorker.RunReaper(ctx)
 	for i := range config.Config.FilesWorkers {
 		go filesWorker.Work(ctx)
 		log.Info().Int("workerID", i+1).Msg("Worker started")
 	}
 
+	go digest.NewWorker(mongo, mng, config.Config.Digest).Run(ctx)
+
+	webhooksWorker := webhooks.NewWorker(mongo, rdb, config.Config.Webhooks)
+	go webhooksWorker.RunReaper(ctx)
+	for range config.Config.Webhooks.Workers {
+		go webhooksWorker.Work(ctx)
+	}
+
 	go func() {
-		if err := grpc_server.StartGRPCServer("50051", mng, mongo); err != nil {
+		if err := grpc_server.StartGRPCServer(consts.GRPC_PORT, mng, mongo, coordinator, bus, serverOptions...); err != nil {
 			log.Fatal().Err(err).Msg("Failed to start gRPC server")
 		}
 	}()
+
+	return coordinator
 }
@@ -109,74 +109,148 @@ func CreateInputMediaFromFileInfoByFile(file telego.InputFile, mediaType string,
 	}
 }
 
+// GetFiles - все файлы сообщения: у платных медиа их может быть несколько, у остальных не больше одного
+func GetFiles(message *telego.Message) []*types.MediaItem {
+	if message != nil && message.PaidMedia != nil {
+		return getPaidMediaFiles(message.PaidMedia)
+	}
+	if media := GetFile(message); media != nil {
+		return []*types.MediaItem{media}
+	}
+	return nil
+}
+
+// FilesSize - сумма file_size всех файлов сообщения, 0 - файлов нет или размер неизвестен
+func FilesSize(message *telego.Message) int64 {
+	var size int64
+	for _, file := range GetFiles(message) {
+		size += file.FileSize
+	}
+	return size
+}
+
+// GetFile - файл сообщения, у платных медиа первый из открытых
 func GetFile(message *telego.Message) (media *types.MediaItem) {
 	if message == nil {
 		return media
 	}
 
 	switch {
-	case len(message.Photo) > 0:
-		actualFile := message.Photo[len(message.Photo)-1]
-		media = &types.MediaItem{
-			Type:     "photo",
-			FileID:   actualFile.FileID,
-			FileSize: int64(actualFile.FileSize),
+	case message.PaidMedia != nil:
+		if files := getPaidMediaFiles(message.PaidMedia); len(files) > 0 {
+			media = files[0]
 		}
 		break
+	case len(message.Photo) > 0:
+		media = getPhoto(message.Photo)
+		break
 	case message.Video != nil:
 		media = &types.MediaItem{
-			Type:     "video",
-			FileID:   message.Video.FileID,
-			FileSize: message.Video.FileSize,
+			Type:         "video",
+			FileID:       message.Video.FileID,
+			FileUniqueID: message.Video.FileUniqueID,
+			FileSize:     message.Video.FileSize,
 		}
 		break
 	case message.Animation != nil:
 		media = &types.MediaItem{
-			Type:     "animation",
-			FileID:   message.Animation.FileID,
-			FileSize: message.Animation.FileSize,
+			Type:         "animation",
+			FileID:       message.Animation.FileID,
+			FileUniqueID: message.Animation.FileUniqueID,
+			FileSize:     message.Animation.FileSize,
 		}
 		break
 	case message.Audio != nil:
 		media = &types.MediaItem{
-			Type:     "audio",
-			FileID:   message.Audio.FileID,
-			FileSize: message.Audio.FileSize,
+			Type:         "audio",
+			FileID:       message.Audio.FileID,
+			FileUniqueID: message.Audio.FileUniqueID,
+			FileSize:     message.Audio.FileSize,
 		}
 		break
 	case message.Voice != nil:
 		media = &types.MediaItem{
-			Type:     "voice",
-			FileID:   message.Voice.FileID,
-			FileSize: message.Voice.FileSize,
+			Type:         "voice",
+			FileID:       message.Voice.FileID,
+			FileUniqueID: message.Voice.FileUniqueID,
+			FileSize:     message.Voice.FileSize,
 		}
 		break
 	case message.Document != nil:
 		media = &types.MediaItem{
-			Type:     "document",
-			FileID:   message.Document.FileID,
-			FileSize: message.Document.FileSize,
+			Type:         "document",
+			FileID:       message.Document.FileID,
+			FileUniqueID: message.Document.FileUniqueID,
+			FileSize:     message.Document.FileSize,
 		}
 		break
 	case message.Sticker != nil:
 		media = &types.MediaItem{
-			Type:     "sticker",
-			FileID:   message.Sticker.FileID,
-			FileSize: int64(message.Sticker.FileSize),
+			Type:         "sticker",
+			FileID:       message.Sticker.FileID,
+			FileUniqueID: message.Sticker.FileUniqueID,
+			FileSize:     int64(message.Sticker.FileSize),
 		}
 		break
 	case message.VideoNote != nil:
 		media = &types.MediaItem{
-			Type:     "video_note",
-			FileID:   message.VideoNote.FileID,
-			FileSize: int64(message.VideoNote.FileSize),
+			Type:         "video_note",
+			FileID:       message.VideoNote.FileID,
+			FileUniqueID: message.VideoNote.FileUniqueID,
+			FileSize:     int64(message.VideoNote.FileSize),
 		}
 		break
+	case message.Game != nil && message.Game.Animation != nil:
+		media = &types.MediaItem{
+			Type:         "animation",
+			FileID:       message.Game.Animation.FileID,
+			FileUniqueID: message.Game.Animation.FileUniqueID,
+			FileSize:     message.Game.Animation.FileSize,
+		}
+		break
+	case message.Game != nil && len(message.Game.Photo) > 0:
+		media = getPhoto(message.Game.Photo)
+		break
+	case len(message.NewChatPhoto) > 0:
+		media = getPhoto(message.NewChatPhoto)
+		break
 	}
 
 	return media
 }
 
+// getPaidMediaFiles - файлы платных медиа, превью без покупки файла не содержит и пропускается
+func getPaidMediaFiles(paidMedia *telego.PaidMediaInfo) []*types.MediaItem {
+	var files []*types.MediaItem
+	for _, item := range paidMedia.PaidMedia {
+		switch paid := item.(type) {
+		case *telego.PaidMediaPhoto:
+			if len(paid.Photo) > 0 {
+				files = append(files, getPhoto(paid.Photo))
+			}
+		case *telego.PaidMediaVideo:
+			files = append(files, &types.MediaItem{
+				Type:         "video",
+				FileID:       paid.Video.FileID,
+				FileUniqueID: paid.Video.FileUniqueID,
+				FileSize:     paid.Video.FileSize,
+			})
+		}
+	}
+	return files
+}
+
+// getPhoto - самый большой размер фото
+func getPhoto(photo []telego.PhotoSize) *types.MediaItem {
+	actualFile := photo[len(photo)-1]
+	return &types.MediaItem{
+		Type:         "photo",
+		FileID:       actualFile.FileID,
+		FileUniqueID: actualFile.FileUniqueID,
+		FileSize:     int64(actualFile.FileSize),
+	}
+}
+
 func SortFiles(media []*types.MediaItemProcess) [][]*types.MediaItemProcess {
 	groups := make(map[string][]*types.MediaItemProcess)
 
@@ -201,20 +275,6 @@ func SortFiles(media []*types.MediaItemProcess) [][]*types.MediaItemProcess {
 	return result
 }
 
-func ConvertFileInfosGroupsToInputMediaGroups(mediaFiles [][]*types.MediaItemProcess) (result [][]telego.InputMedia) {
-	for _, innerSlice := range mediaFiles {
-		var convertedInner []telego.InputMedia
-		for _, mediaItem := range innerSlice {
-			if mediaItem != nil {
-				inputMedia := CreateInputMediaFromFileInfo(mediaItem.FileID, mediaItem.Type, mediaItem.Caption)
-				convertedInner = append(convertedInner, inputMedia)
-			}
-		}
-		result = append(result, convertedInner)
-	}
-	return result
-}
-
 func BusinessMessageMatches(pattern *regexp.Regexp) th.Predicate {
 	return func(_ context.Context, update telego.Update) bool {
 		return update.BusinessMessage != nil && pattern.MatchString(update.BusinessMessage.Text)
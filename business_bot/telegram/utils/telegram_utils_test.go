@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mymmrac/telego"
+
+	"ssuspy-bot/types"
+)
+
+func TestGetFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *telego.Message
+		want    []*types.MediaItem
+	}{
+		{
+			name: "nil message",
+		},
+		{
+			name:    "text only",
+			message: &telego.Message{Text: "hi"},
+		},
+		{
+			name: "largest photo size",
+			message: &telego.Message{Photo: []telego.PhotoSize{
+				{FileID: "small", FileUniqueID: "s", FileSize: 10},
+				{FileID: "big", FileUniqueID: "b", FileSize: 100},
+			}},
+			want: []*types.MediaItem{{Type: "photo", FileID: "big", FileUniqueID: "b", FileSize: 100}},
+		},
+		{
+			name:    "video",
+			message: &telego.Message{Video: &telego.Video{FileID: "v", FileUniqueID: "vu", FileSize: 5}},
+			want:    []*types.MediaItem{{Type: "video", FileID: "v", FileUniqueID: "vu", FileSize: 5}},
+		},
+		{
+			name:    "sticker",
+			message: &telego.Message{Sticker: &telego.Sticker{FileID: "st", FileUniqueID: "stu", FileSize: 7}},
+			want:    []*types.MediaItem{{Type: "sticker", FileID: "st", FileUniqueID: "stu", FileSize: 7}},
+		},
+		{
+			name:    "video note",
+			message: &telego.Message{VideoNote: &telego.VideoNote{FileID: "n", FileUniqueID: "nu", FileSize: 3}},
+			want:    []*types.MediaItem{{Type: "video_note", FileID: "n", FileUniqueID: "nu", FileSize: 3}},
+		},
+		{
+			name:    "game animation",
+			message: &telego.Message{Game: &telego.Game{Animation: &telego.Animation{FileID: "g", FileUniqueID: "gu"}}},
+			want:    []*types.MediaItem{{Type: "animation", FileID: "g", FileUniqueID: "gu"}},
+		},
+		{
+			name: "paid media with several items",
+			message: &telego.Message{PaidMedia: &telego.PaidMediaInfo{
+				StarCount: 10,
+				PaidMedia: []telego.PaidMedia{
+					&telego.PaidMediaPhoto{Photo: []telego.PhotoSize{
+						{FileID: "p1", FileUniqueID: "p1u", FileSize: 1},
+						{FileID: "p2", FileUniqueID: "p2u", FileSize: 2},
+					}},
+					&telego.PaidMediaPreview{Width: 100, Height: 100},
+					&telego.PaidMediaVideo{Video: telego.Video{FileID: "v", FileUniqueID: "vu", FileSize: 30}},
+				},
+			}},
+			want: []*types.MediaItem{
+				{Type: "photo", FileID: "p2", FileUniqueID: "p2u", FileSize: 2},
+				{Type: "video", FileID: "v", FileUniqueID: "vu", FileSize: 30},
+			},
+		},
+		{
+			name: "paid media preview only",
+			message: &telego.Message{PaidMedia: &telego.PaidMediaInfo{
+				PaidMedia: []telego.PaidMedia{&telego.PaidMediaPreview{}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetFiles(tt.message); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetFiles() = %+v, want %+v", got, tt.want)
+			}
+
+			var first *types.MediaItem
+			if len(tt.want) > 0 {
+				first = tt.want[0]
+			}
+			if got := GetFile(tt.message); !reflect.DeepEqual(got, first) {
+				t.Errorf("GetFile() = %+v, want %+v", got, first)
+			}
+		})
+	}
+}
+
+func TestFilesSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *telego.Message
+		want    int64
+	}{
+		{
+			name:    "no files",
+			message: &telego.Message{Text: "hi"},
+		},
+		{
+			name:    "document",
+			message: &telego.Message{Document: &telego.Document{FileID: "d", FileSize: 42}},
+			want:    42,
+		},
+		{
+			name: "every paid media item",
+			message: &telego.Message{PaidMedia: &telego.PaidMediaInfo{
+				PaidMedia: []telego.PaidMedia{
+					&telego.PaidMediaPhoto{Photo: []telego.PhotoSize{{FileID: "p", FileSize: 10}}},
+					&telego.PaidMediaVideo{Video: telego.Video{FileID: "v", FileSize: 20}},
+				},
+			}},
+			want: 30,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FilesSize(tt.message); got != tt.want {
+				t.Errorf("FilesSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -19,12 +19,15 @@ type MediaItemProcess struct {
 	FileID   string
 	FileSize int64
 	Caption  string
+	// FileUniqueID - по нему файл ищется в архиве, если отправить по FileID не вышло
+	FileUniqueID string
 }
 
 type MediaItem struct {
-	Type     string
-	FileID   string
-	FileSize int64
+	Type         string
+	FileID       string
+	FileUniqueID string
+	FileSize     int64
 }
 
 type MediaDiff struct {
@@ -65,6 +68,8 @@ type HandleDeletedMessageDataType int
 const (
 	HandleDeletedMessageDataTypeDetails HandleDeletedMessageDataType = iota
 	HandleDeletedMessageDataTypeMessage
+	// HandleDeletedMessageDataTypeStoredDetails - подробности сообщения из поиска или истории, оно может быть не удалено
+	HandleDeletedMessageDataTypeStoredDetails
 )
 
 func (h HandleDeletedMessageData) ToString(dataType HandleDeletedMessageDataType) string {
@@ -75,6 +80,8 @@ func (h HandleDeletedMessageData) ToString(dataType HandleDeletedMessageDataType
 		prefix = consts.CALLBACK_PREFIX_DELETED_DETAILS
 	case HandleDeletedMessageDataTypeMessage:
 		prefix = consts.CALLBACK_PREFIX_DELETED_MESSAGE
+	case HandleDeletedMessageDataTypeStoredDetails:
+		prefix = consts.CALLBACK_PREFIX_STORED_DETAILS
 	default:
 		return ""
 	}
@@ -132,6 +139,19 @@ func (h HandleEditedData) ToString(dataType HandleEditedDataType) string {
 	return fmt.Sprintf("%s|%d|%d", prefix, h.ChatID, h.DataID)
 }
 
+type HandleEditedTimelineData struct {
+	ChatID int64
+	DataID int64
+	// Revision - номер версии с нуля, отрицательный - последняя
+	Revision int
+	// Action - EDITED_TIMELINE_*
+	Action int
+}
+
+func (h HandleEditedTimelineData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d|%d|%d", consts.CALLBACK_PREFIX_EDITED_TIMELINE, h.ChatID, h.DataID, h.Revision, h.Action)
+}
+
 type HandleBusinessData struct {
 	DataID int64
 	ChatID int64
@@ -155,3 +175,98 @@ func (h HandleBusinessData) ToString(dataType HandleBusinessDataType) string {
 
 	return fmt.Sprintf("%s|%d|%d", prefix, h.DataID, h.ChatID)
 }
+
+type HandleSearchData struct {
+	DataID           int64
+	Offset           int
+	TypeOfPagination string
+}
+
+func (h HandleSearchData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d|%s", consts.CALLBACK_PREFIX_SEARCH, h.DataID, h.Offset, h.TypeOfPagination)
+}
+
+type HandleHistoryData struct {
+	Offset           int
+	TypeOfPagination string
+}
+
+func (h HandleHistoryData) ToString() string {
+	return fmt.Sprintf("%s|%d|%s", consts.CALLBACK_PREFIX_HISTORY, h.Offset, h.TypeOfPagination)
+}
+
+type HandleHistoryChatData struct {
+	DataID           int64
+	ChatIndex        int
+	Offset           int
+	TypeOfPagination string
+}
+
+func (h HandleHistoryChatData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d|%d|%s", consts.CALLBACK_PREFIX_HISTORY_CHAT, h.DataID, h.ChatIndex, h.Offset, h.TypeOfPagination)
+}
+
+type HandleHistoryMessageData struct {
+	DataID     int64
+	ChatIndex  int
+	MessageID  int
+	BackOffset int
+}
+
+func (h HandleHistoryMessageData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d|%d|%d", consts.CALLBACK_PREFIX_HISTORY_MESSAGE, h.DataID, h.ChatIndex, h.MessageID, h.BackOffset)
+}
+
+// ExportAllChats - ChatIndex для экспорта всех чатов разом
+const ExportAllChats = -1
+
+type HandleExportData struct {
+	Offset           int
+	TypeOfPagination string
+}
+
+func (h HandleExportData) ToString() string {
+	return fmt.Sprintf("%s|%d|%s", consts.CALLBACK_PREFIX_EXPORT, h.Offset, h.TypeOfPagination)
+}
+
+type HandleExportFormatData struct {
+	DataID    int64
+	ChatIndex int
+}
+
+func (h HandleExportFormatData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d", consts.CALLBACK_PREFIX_EXPORT_FORMAT, h.DataID, h.ChatIndex)
+}
+
+type HandleExportStartData struct {
+	DataID    int64
+	ChatIndex int
+	Format    string
+}
+
+func (h HandleExportStartData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d|%s", consts.CALLBACK_PREFIX_EXPORT_START, h.DataID, h.ChatIndex, h.Format)
+}
+
+type HandleSettingsChatsData struct {
+	Offset           int
+	TypeOfPagination string
+	// ToggleMode - переключить режим между denylist и allowlist
+	ToggleMode bool
+}
+
+func (h HandleSettingsChatsData) ToString() string {
+	return fmt.Sprintf("%s|%d|%s|%t", consts.CALLBACK_PREFIX_SETTINGS_CHATS, h.Offset, h.TypeOfPagination, h.ToggleMode)
+}
+
+type HandleSettingsChatData struct {
+	ChatID int64
+	// Offset - страница списка чатов, на которую вернуться
+	Offset int
+	// Action - CHAT_RULE_* или SETTINGS_SHOW_*
+	Action int
+}
+
+func (h HandleSettingsChatData) ToString() string {
+	return fmt.Sprintf("%s|%d|%d|%d", consts.CALLBACK_PREFIX_SETTINGS_CHAT, h.ChatID, h.Offset, h.Action)
+}
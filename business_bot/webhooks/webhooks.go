@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mymmrac/telego"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/consts"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-common/crypto"
+)
+
+const (
+	EventDeleted = "deleted"
+	EventEdited  = "edited"
+	// EventTest - отправляется кнопкой в настройках, чтобы проверить адрес и подпись
+	EventTest = "test"
+)
+
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// Payload - тело запроса на вебхук. Заполняются только поля, относящиеся к Event
+type Payload struct {
+	ID     string `json:"id"`
+	Event  string `json:"event"`
+	BotID  int64  `json:"bot_id"`
+	UserID int64  `json:"user_id"`
+	Date   int64  `json:"date"`
+
+	ChatID   int64  `json:"chat_id,omitempty"`
+	ChatName string `json:"chat_name,omitempty"`
+
+	// MessageIDs - все удаленные сообщения, Messages - те из них, что нашлись в базе
+	MessageIDs []int             `json:"message_ids,omitempty"`
+	Messages   []*telego.Message `json:"messages,omitempty"`
+
+	// OldMessage - nil, если старой версии нет в базе, тогда и Changes пустые
+	Message    *telego.Message `json:"message,omitempty"`
+	OldMessage *telego.Message `json:"old_message,omitempty"`
+	Changes    []string        `json:"changes,omitempty"`
+}
+
+func NewSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// Sign - HMAC-SHA256 от "timestamp.body" в hex. Время входит в подпись, чтобы старый запрос нельзя было повторить
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func ValidateURL(raw string) error {
+	if len(raw) > consts.MAX_WEBHOOK_URL_LEN {
+		return errors.New("url is too long")
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "https" && parsed.Scheme != "http" {
+		return errors.New("url scheme must be http or https")
+	}
+	if parsed.Hostname() == "" {
+		return errors.New("url has no host")
+	}
+	if parsed.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return nil
+}
+
+// Enqueue ставит событие в очередь доставки, если у пользователя есть вебхук и он подписан на это событие
+func Enqueue(ctx context.Context, service *repository.MongoRepository, rdb *redis.Redis, payload *Payload) error {
+	webhook, err := service.GetWebhook(ctx, payload.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed get webhook: %w", err)
+	}
+
+	switch {
+	case payload.Event == EventDeleted && !webhook.Deleted,
+		payload.Event == EventEdited && !webhook.Edited:
+		return nil
+	}
+
+	return enqueue(ctx, service, rdb, payload)
+}
+
+// EnqueueTest ставит в очередь тестовое событие, оно отправляется независимо от выбранных событий
+func EnqueueTest(ctx context.Context, service *repository.MongoRepository, rdb *redis.Redis, botID int64, userID int64) error {
+	return enqueue(ctx, service, rdb, &Payload{
+		Event:  EventTest,
+		BotID:  botID,
+		UserID: userID,
+	})
+}
+
+// enqueue кладет событие в redis. При включенном шифровании сообщений тело шифруется ключом пользователя,
+// чтобы расшифрованные сообщения не лежали в очереди открытым текстом
+func enqueue(ctx context.Context, service *repository.MongoRepository, rdb *redis.Redis, payload *Payload) error {
+	now := time.Now()
+
+	payload.ID = uuid.New().String()
+	if payload.Date == 0 {
+		payload.Date = now.Unix()
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	job := redis.WebhookJob{
+		ID:        payload.ID,
+		UserID:    payload.UserID,
+		Event:     payload.Event,
+		Payload:   body,
+		CreatedAt: now.Unix(),
+	}
+
+	box, err := service.MessageBox(payload.UserID)
+	if err != nil {
+		return fmt.Errorf("failed get message key: %w", err)
+	}
+	if box != nil {
+		job.SealedPayload, err = box.Encrypt(string(body), crypto.UserAAD(payload.UserID))
+		if err != nil {
+			return fmt.Errorf("failed to seal webhook payload: %w", err)
+		}
+		job.Payload = nil
+	}
+
+	return rdb.EnqueueWebhook(ctx, job)
+}
+
+// openPayload возвращает тело события, расшифровывая его, если оно было зашифровано при постановке в очередь
+func openPayload(service *repository.MongoRepository, job *redis.WebhookJob) ([]byte, error) {
+	if job.SealedPayload == "" {
+		return job.Payload, nil
+	}
+
+	box, err := service.MessageBox(job.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if box == nil {
+		return nil, errors.New("payload is sealed, but message encryption is disabled")
+	}
+
+	body, err := box.Decrypt(job.SealedPayload, crypto.UserAAD(job.UserID))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(body), nil
+}
@@ -0,0 +1,291 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-bot/config"
+	"ssuspy-bot/metrics"
+	"ssuspy-bot/redis"
+	"ssuspy-bot/repository"
+	"ssuspy-common/backoff"
+)
+
+const (
+	reapInterval = time.Second
+	reapBatch    = 100
+	// ответ вебхука не нужен, но дочитываем его, чтобы соединение можно было переиспользовать
+	maxResponseBody = 64 << 10
+)
+
+var (
+	errPrivateAddress  = errors.New("webhook address is in a private network")
+	errDeliveryTimeout = errors.New("webhook delivery was not acknowledged before visibility timeout")
+)
+
+// sharedAddressSpace - CGNAT диапазон, netip не считает его приватным
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// permanentError - повтор не поможет, событие сразу уходит в dead letter
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+type Worker struct {
+	service *repository.MongoRepository
+	rdb     *redis.Redis
+	cfg     *config.WebhooksConfig
+	client  *http.Client
+}
+
+func NewWorker(service *repository.MongoRepository, rdb *redis.Redis, cfg *config.WebhooksConfig) *Worker {
+	dialer := &net.Dialer{Timeout: cfg.Timeout}
+	if !cfg.AllowPrivate {
+		dialer.Control = denyPrivate
+	}
+
+	return &Worker{
+		service: service,
+		rdb:     rdb,
+		cfg:     cfg,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: cfg.Timeout,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			// редирект мог бы увести запрос на адрес, который не проверял пользователь
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// denyPrivate проверяет адрес уже после резолва, так что обойти проверку через DNS не выйдет
+func denyPrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) {
+		return errPrivateAddress
+	}
+	return nil
+}
+
+// Work доставляет события из очереди, пока не отменят ctx
+func (w *Worker) Work(ctx context.Context) {
+	for {
+		job, err := w.rdb.DequeueWebhook(ctx, 5*time.Second, w.cfg.VisibilityTimeout)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Warn().Err(err).Msg("failed dequeue webhook job")
+			time.Sleep(time.Second)
+			continue
+		}
+		if job == nil {
+			continue
+		}
+
+		// начатую доставку доводим до конца, иначе при остановке событие уйдет повторно по таймауту
+		jobCtx := context.WithoutCancel(ctx)
+		stop := w.keepAlive(jobCtx, job)
+		w.process(jobCtx, job)
+		stop()
+	}
+}
+
+// keepAlive продлевает срок доставки, пока она идет
+func (w *Worker) keepAlive(ctx context.Context, job *redis.WebhookJob) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		ticker := time.NewTicker(w.cfg.VisibilityTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := w.rdb.TouchWebhook(ctx, job, w.cfg.VisibilityTimeout); err != nil && ctx.Err() == nil {
+				log.Warn().Err(err).Str("deliveryID", job.ID).Msg("failed extend webhook delivery deadline")
+			}
+		}
+	}()
+
+	return cancel
+}
+
+// RunReaper возвращает в очередь отложенные доставки, у которых подошло время,
+// и повторяет доставки с истекшим сроком
+func (w *Worker) RunReaper(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		if _, err := w.rdb.PromoteWebhooks(ctx, now, reapBatch); err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("failed promote webhook retries")
+		}
+
+		expired, err := w.rdb.ExpiredWebhooks(ctx, now, w.cfg.VisibilityTimeout, reapBatch)
+		if err != nil && ctx.Err() == nil {
+			log.Warn().Err(err).Msg("failed get expired webhook deliveries")
+		}
+		for _, job := range expired {
+			w.fail(ctx, job, errDeliveryTimeout)
+		}
+	}
+}
+
+func (w *Worker) process(ctx context.Context, job *redis.WebhookJob) {
+	webhook, err := w.service.GetWebhook(ctx, job.UserID)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// вебхук удалили, пока событие ждало в очереди
+		metrics.WebhookDeliveriesTotal.WithLabelValues("dropped").Inc()
+		w.ack(ctx, job)
+		return
+	}
+	if err == nil {
+		err = w.deliver(ctx, webhook, job)
+
+		var lastError string
+		if err != nil {
+			lastError = err.Error()
+		}
+		if errSave := w.service.SetWebhookDelivery(ctx, job.UserID, lastError); errSave != nil {
+			log.Warn().Err(errSave).Str("deliveryID", job.ID).Msg("failed save webhook delivery result")
+		}
+	}
+	if err == nil {
+		metrics.WebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+		w.ack(ctx, job)
+		return
+	}
+
+	w.fail(ctx, job, err)
+}
+
+func (w *Worker) ack(ctx context.Context, job *redis.WebhookJob) {
+	if err := w.rdb.AckWebhook(ctx, job); err != nil {
+		log.Error().Err(err).Str("deliveryID", job.ID).Msg("failed ack webhook job")
+	}
+}
+
+// fail откладывает доставку на повтор, а после последней попытки или при постоянной ошибке
+// переносит событие в dead letter
+func (w *Worker) fail(ctx context.Context, job *redis.WebhookJob, cause error) {
+	log := log.With().
+		Str("deliveryID", job.ID).
+		Int64("userID", job.UserID).
+		Str("event", job.Event).
+		Int("attempt", job.Attempt+1).
+		Logger()
+
+	job.Attempt++
+	job.LastError = cause.Error()
+
+	var permanent *permanentError
+	if errors.As(cause, &permanent) || job.Attempt >= w.cfg.MaxAttempts {
+		metrics.WebhookDeliveriesTotal.WithLabelValues("dead").Inc()
+		log.Warn().Err(cause).Msg("webhook delivery failed, moving to dead letter")
+
+		if err := w.rdb.DeadWebhook(ctx, job, w.cfg.DeadLetterSize); err != nil {
+			log.Error().Err(err).Msg("failed save webhook to dead letter")
+		}
+		return
+	}
+
+	metrics.WebhookDeliveriesTotal.WithLabelValues("retry").Inc()
+	delay := backoff.Exponential(job.Attempt, w.cfg.BackoffBase, w.cfg.BackoffMax)
+	log.Debug().Err(cause).Dur("delay", delay).Msg("webhook delivery failed, retrying later")
+
+	if err := w.rdb.RetryWebhook(ctx, job, time.Now().Add(delay)); err != nil {
+		log.Error().Err(err).Msg("failed schedule webhook retry")
+	}
+}
+
+func (w *Worker) deliver(ctx context.Context, webhook *repository.Webhook, job *redis.WebhookJob) error {
+	body, err := openPayload(w.service, job)
+	if err != nil {
+		// ключ не подходит, повтор ничего не изменит
+		return &permanentError{fmt.Errorf("failed open payload: %w", err)}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, job.Event)
+	req.Header.Set(HeaderDelivery, job.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	start := time.Now()
+	resp, err := w.client.Do(req)
+	metrics.WebhookDeliveryDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		if errors.Is(err, errPrivateAddress) {
+			return &permanentError{err}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusRequestTimeout,
+		resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode >= 500:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	// остальные коды повтором не исправить
+	return &permanentError{fmt.Errorf("unexpected status %s", resp.Status)}
+}
@@ -0,0 +1,18 @@
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Exponential - base * 2^(attempt-1), но не больше max, плюс до 10% разброса,
+// чтобы повторы не шли пачкой. attempt начинается с 1
+func Exponential(attempt int, base time.Duration, max time.Duration) time.Duration {
+	delay := max
+	if shift := attempt - 1; shift >= 0 && shift < 32 {
+		if next := base << shift; next > 0 && next < delay {
+			delay = next
+		}
+	}
+	return delay + rand.N(delay/10+1)
+}
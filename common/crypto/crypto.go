@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// версия формата, чтобы можно было сменить алгоритм без путаницы со старыми записями
+const envelopePrefix = "v1:"
+
+var ErrNotEncrypted = errors.New("value is not encrypted")
+
+// Box шифрует секреты (токены ботов) перед сохранением в базу.
+// Из одного мастер-ключа выводятся два: для AES-GCM и для HMAC поиска
+type Box struct {
+	aead    cipher.AEAD
+	hashKey []byte
+}
+
+// NewBox принимает мастер-ключ в base64, ровно 32 байта (openssl rand -base64 32)
+func NewBox(masterKey string) (*Box, error) {
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed decode master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	return newBox(key)
+}
+
+func newBox(key []byte) (*Box, error) {
+	block, err := aes.NewCipher(derive(key, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Box{
+		aead:    aead,
+		hashKey: derive(key, "lookup"),
+	}, nil
+}
+
+func derive(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// BotAAD - привязка токена к боту: токен, скопированный в запись другого бота, не расшифруется
+func BotAAD(botID int64) string {
+	return "bot:" + strconv.FormatInt(botID, 10)
+}
+
+// UserAAD - привязка секрета к пользователю
+func UserAAD(userID int64) string {
+	return "user:" + strconv.FormatInt(userID, 10)
+}
+
+// Encrypt шифрует значение, привязывая его к записи через aad (дополнительные данные AES-GCM).
+// Расшифровать его можно только с тем же aad
+func (b *Box) Encrypt(plaintext string, aad string) (string, error) {
+	nonce := make([]byte, b.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := b.aead.Seal(nonce, nonce, []byte(plaintext), []byte(aad))
+	return envelopePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (b *Box) Decrypt(value string, aad string) (string, error) {
+	if !IsEncrypted(value) {
+		return "", ErrNotEncrypted
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, envelopePrefix))
+	if err != nil {
+		return "", fmt.Errorf("failed decode envelope: %w", err)
+	}
+
+	nonceSize := b.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", errors.New("envelope is too short")
+	}
+
+	plaintext, err := b.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(aad))
+	if err != nil {
+		return "", fmt.Errorf("failed open envelope: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Hash - детерминированный HMAC, по нему ищем дубликаты без расшифровки всех записей
+func (b *Box) Hash(value string) string {
+	mac := hmac.New(sha256.New, b.hashKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IsEncrypted отличает конверт по префиксу. Годится только для значений, которые сами с него
+// начинаться не могут (токены, file_id), пользовательский текст так проверять нельзя
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
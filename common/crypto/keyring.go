@@ -0,0 +1,41 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// Keyring выдает каждому пользователю свой Box, ключ выводится из мастер-ключа и ID пользователя,
+// поэтому хранить ключи пользователей не нужно
+type Keyring struct {
+	masterKey []byte
+	boxes     sync.Map
+}
+
+// NewKeyring принимает мастер-ключ в base64, ровно 32 байта (openssl rand -base64 32)
+func NewKeyring(masterKey string) (*Keyring, error) {
+	key, err := base64.StdEncoding.DecodeString(masterKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed decode master key: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("master key must be 32 bytes, got %d", len(key))
+	}
+	return &Keyring{masterKey: key}, nil
+}
+
+func (k *Keyring) ForUser(userID int64) (*Box, error) {
+	if box, ok := k.boxes.Load(userID); ok {
+		return box.(*Box), nil
+	}
+
+	box, err := newBox(derive(k.masterKey, "user:"+strconv.FormatInt(userID, 10)))
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := k.boxes.LoadOrStore(userID, box)
+	return actual.(*Box), nil
+}
@@ -0,0 +1,180 @@
+package grpcauth
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const authorizationKey = "authorization"
+
+// TLS - пути к PEM файлам. CAFile на сервере включает проверку клиентских сертификатов (mTLS),
+// на клиенте - проверку сервера этим CA вместо системных
+type TLS struct {
+	CertFile string
+	KeyFile  string
+	CAFile   string
+	// ServerName - имя из сертификата сервера, если оно не совпадает с адресом подключения
+	ServerName string
+}
+
+func (t TLS) enabled() bool {
+	return t.CertFile != "" || t.KeyFile != "" || t.CAFile != ""
+}
+
+func (t TLS) certificates() ([]tls.Certificate, error) {
+	if t.CertFile == "" && t.KeyFile == "" {
+		return nil, nil
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		return nil, errors.New("both tls cert and key must be set")
+	}
+
+	cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed load tls key pair: %w", err)
+	}
+	return []tls.Certificate{cert}, nil
+}
+
+func (t TLS) pool() (*x509.CertPool, error) {
+	if t.CAFile == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(t.CAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed read tls ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+	}
+	return pool, nil
+}
+
+// Secured - включено ли хоть что-то, чтобы можно было предупредить об открытом gRPC
+func Secured(t TLS, token string) bool {
+	return t.enabled() || token != ""
+}
+
+// ServerOptions - TLS (mTLS при заданном CA) и проверка токена, если они настроены
+func ServerOptions(t TLS, token string) ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	if t.enabled() {
+		certs, err := t.certificates()
+		if err != nil {
+			return nil, err
+		}
+		if certs == nil {
+			return nil, errors.New("server needs tls cert and key")
+		}
+		clientCAs, err := t.pool()
+		if err != nil {
+			return nil, err
+		}
+
+		cfg := &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: certs,
+		}
+		if clientCAs != nil {
+			cfg.ClientCAs = clientCAs
+			cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(cfg)))
+	}
+
+	if token != "" {
+		opts = append(opts,
+			grpc.ChainUnaryInterceptor(UnaryServerInterceptor(token)),
+			grpc.ChainStreamInterceptor(StreamServerInterceptor(token)),
+		)
+	}
+
+	return opts, nil
+}
+
+// DialOptions - клиентская сторона ServerOptions: сертификат для mTLS и токен в каждом запросе
+func DialOptions(t TLS, token string) ([]grpc.DialOption, error) {
+	var opts []grpc.DialOption
+
+	if t.enabled() {
+		certs, err := t.certificates()
+		if err != nil {
+			return nil, err
+		}
+		rootCAs, err := t.pool()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: certs,
+			RootCAs:      rootCAs,
+			ServerName:   t.ServerName,
+		})))
+	} else {
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if token != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(tokenCredentials(token)))
+	}
+
+	return opts, nil
+}
+
+// tokenCredentials передает токен и без TLS, для сетей, где mTLS не настроить
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{authorizationKey: "Bearer " + string(t)}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+func UnaryServerInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := checkToken(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamServerInterceptor(token string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := checkToken(ss.Context(), token); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func checkToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get(authorizationKey) {
+		received, ok := strings.CutPrefix(value, "Bearer ")
+		if ok && subtle.ConstantTimeCompare([]byte(received), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "invalid or missing token")
+}
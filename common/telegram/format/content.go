@@ -0,0 +1,208 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/mymmrac/telego"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// describeContent - строки сводки для содержимого сообщения помимо текста, обычных медиа и геолокации:
+// опросы, контакты, платные медиа, подарки, платежи и служебные сообщения
+func describeContent(message *telego.Message, loc *i18n.Localizer, truncate bool) []string {
+	var lines []string
+	add := func(messageID string, data map[string]any) {
+		lines = append(lines, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID:    "business.deleted.format.content." + messageID,
+			TemplateData: data,
+		}))
+	}
+
+	if message.PaidMedia != nil {
+		items := make([]string, 0, len(message.PaidMedia.PaidMedia))
+		for _, paid := range message.PaidMedia.PaidMedia {
+			mediaType := paid.MediaType()
+			if mediaType == telego.PaidMediaTypePreview {
+				mediaType = "paid_preview"
+			}
+			items = append(items, loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: fmt.Sprintf("mediaTypes.%s", mediaType),
+			}))
+		}
+		add("paidMedia", map[string]any{
+			"Stars": message.PaidMedia.StarCount,
+			"Items": strings.Join(items, ", "),
+		})
+	}
+	if message.PaidStarCount > 0 {
+		add("paidMessage", map[string]any{"Stars": message.PaidStarCount})
+	}
+	if message.Story != nil {
+		add("story", map[string]any{"Chat": chatTitle(message.Story.Chat)})
+	}
+
+	if poll := message.Poll; poll != nil {
+		var options strings.Builder
+		for _, option := range poll.Options {
+			options.WriteString(loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "business.deleted.format.content.pollOption",
+				TemplateData: map[string]any{
+					"Text":  formatText(option.Text, option.TextEntities, truncate),
+					"Votes": option.VoterCount,
+				},
+			}))
+		}
+		add("poll", map[string]any{
+			"Quiz":     poll.Type == telego.PollTypeQuiz,
+			"Question": formatText(poll.Question, poll.QuestionEntities, truncate),
+			"Options":  options.String(),
+		})
+	}
+	if contact := message.Contact; contact != nil {
+		add("contact", map[string]any{
+			"Name":  Name(contact.FirstName, contact.LastName),
+			"Phone": html.EscapeString(contact.PhoneNumber),
+		})
+	}
+	if venue := message.Venue; venue != nil {
+		add("venue", map[string]any{
+			"Title":   html.EscapeString(venue.Title),
+			"Address": html.EscapeString(venue.Address),
+		})
+	}
+	if dice := message.Dice; dice != nil {
+		add("dice", map[string]any{
+			"Emoji": dice.Emoji,
+			"Value": dice.Value,
+		})
+	}
+	if message.Game != nil {
+		add("game", map[string]any{"Title": html.EscapeString(message.Game.Title)})
+	}
+
+	if invoice := message.Invoice; invoice != nil {
+		add("invoice", map[string]any{
+			"Title":  html.EscapeString(invoice.Title),
+			"Amount": formatAmount(invoice.TotalAmount, invoice.Currency),
+		})
+	}
+	if payment := message.SuccessfulPayment; payment != nil {
+		add("payment", map[string]any{"Amount": formatAmount(payment.TotalAmount, payment.Currency)})
+	}
+	if refund := message.RefundedPayment; refund != nil {
+		add("refund", map[string]any{"Amount": formatAmount(refund.TotalAmount, refund.Currency)})
+	}
+
+	if gift := message.Gift; gift != nil {
+		add("gift", map[string]any{"Stars": gift.Gift.StarCount})
+		if gift.Text != "" {
+			lines = append(lines, loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "business.deleted.format.text",
+				TemplateData: map[string]string{
+					"Text": formatText(gift.Text, gift.Entities, truncate),
+				},
+			}))
+		}
+	}
+	if gift := message.UniqueGift; gift != nil {
+		add("uniqueGift", map[string]any{
+			"Name":   html.EscapeString(gift.Gift.BaseName),
+			"Number": gift.Gift.Number,
+		})
+	}
+	if giveaway := message.Giveaway; giveaway != nil {
+		add("giveaway", map[string]any{"Winners": giveaway.WinnerCount})
+	}
+	if winners := message.GiveawayWinners; winners != nil {
+		add("giveawayWinners", map[string]any{"Winners": winners.WinnerCount})
+	}
+
+	if kind := serviceKind(message); kind != "" {
+		add("service", map[string]any{
+			"Kind": loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "business.deleted.format.service." + kind,
+			}),
+		})
+	}
+
+	return lines
+}
+
+// serviceKind - вид служебного сообщения для ключа business.deleted.format.service.*
+func serviceKind(message *telego.Message) string {
+	switch {
+	case len(message.NewChatMembers) > 0:
+		return "newChatMembers"
+	case message.LeftChatMember != nil:
+		return "leftChatMember"
+	case message.NewChatTitle != "":
+		return "newChatTitle"
+	case len(message.NewChatPhoto) > 0:
+		return "newChatPhoto"
+	case message.DeleteChatPhoto:
+		return "deleteChatPhoto"
+	case message.GroupChatCreated || message.SupergroupChatCreated || message.ChannelChatCreated:
+		return "chatCreated"
+	case message.MessageAutoDeleteTimerChanged != nil:
+		return "autoDeleteTimer"
+	case message.MigrateToChatID != 0 || message.MigrateFromChatID != 0:
+		return "migrated"
+	case message.PinnedMessage != nil:
+		return "pinnedMessage"
+	case message.UsersShared != nil:
+		return "usersShared"
+	case message.ChatShared != nil:
+		return "chatShared"
+	case message.ConnectedWebsite != "":
+		return "connectedWebsite"
+	case message.WriteAccessAllowed != nil:
+		return "writeAccessAllowed"
+	case message.PassportData != nil:
+		return "passportData"
+	case message.ProximityAlertTriggered != nil:
+		return "proximityAlert"
+	case message.BoostAdded != nil:
+		return "boostAdded"
+	case message.ChatBackgroundSet != nil:
+		return "chatBackground"
+	case message.ForumTopicCreated != nil || message.ForumTopicEdited != nil ||
+		message.ForumTopicClosed != nil || message.ForumTopicReopened != nil ||
+		message.GeneralForumTopicHidden != nil || message.GeneralForumTopicUnhidden != nil:
+		return "forumTopic"
+	case message.GiveawayCreated != nil:
+		return "giveawayCreated"
+	case message.GiveawayCompleted != nil:
+		return "giveawayCompleted"
+	case message.PaidMessagePriceChanged != nil:
+		return "paidMessagePrice"
+	case message.VideoChatScheduled != nil || message.VideoChatStarted != nil ||
+		message.VideoChatEnded != nil || message.VideoChatParticipantsInvited != nil:
+		return "videoChat"
+	case message.WebAppData != nil:
+		return "webAppData"
+	}
+	return ""
+}
+
+// formatAmount - сумма платежа. Telegram передает ее в минимальных единицах валюты:
+// у звезд (XTR) это целые звезды, у большинства остальных валют сотые доли
+func formatAmount(amount int, currency string) string {
+	if currency == "XTR" {
+		return fmt.Sprintf("%d ⭐️", amount)
+	}
+	return fmt.Sprintf("%d.%02d %s", amount/100, amount%100, html.EscapeString(currency))
+}
+
+func chatTitle(chat telego.Chat) string {
+	switch {
+	case chat.Title != "":
+		return html.EscapeString(chat.Title)
+	case chat.FirstName != "":
+		return Name(chat.FirstName, chat.LastName)
+	case chat.Username != "":
+		return "@" + html.EscapeString(chat.Username)
+	}
+	return fmt.Sprint(chat.ID)
+}
@@ -0,0 +1,289 @@
+package format
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ssuspy-bot/consts"
+
+	"github.com/mymmrac/telego"
+)
+
+type diffKind int
+
+const (
+	diffEqual diffKind = iota
+	diffDelete
+	diffInsert
+)
+
+type diffOp struct {
+	kind diffKind
+	text string
+}
+
+const (
+	// maxDiffCells - предел таблицы LCS, для изменений больше diff остается блочным
+	maxDiffCells = 1 << 20
+	// minDiffEqualPercent - если с новым текстом совпало меньше, текст переписан почти целиком
+	// и пословный diff читается хуже блочного
+	minDiffEqualPercent = 30
+	// diffContext - сколько символов неизмененного текста оставлять вокруг правок при сокращении
+	diffContext = 40
+)
+
+// inlineDiff - пословный diff в Telegram HTML: удаленное зачеркнуто, добавленное подчеркнуто,
+// разметка берется из сущностей своей версии текста.
+// false - изменение слишком большое, его лучше показать блоками старого и нового текста.
+// С truncate переносы заменяются пробелами, длинные неизмененные куски сокращаются,
+// а весь текст укладывается в MAX_MESSAGE_TEXT_LEN, как у formatText
+func inlineDiff(oldText string, oldEntities []telego.MessageEntity, newText string, newEntities []telego.MessageEntity, truncate bool) (string, bool) {
+	ops, ok := diffWords(oldText, newText)
+	if !ok {
+		return "", false
+	}
+	return renderDiff(ops, newEntityText(oldText, oldEntities), newEntityText(newText, newEntities), truncate), true
+}
+
+func diffWords(oldText string, newText string) ([]diffOp, bool) {
+	a, b := tokenizeWords(oldText), tokenizeWords(newText)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	am, bm := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(am)+1)*(len(bm)+1) > maxDiffCells {
+		return nil, false
+	}
+
+	var ops []diffOp
+	add := func(kind diffKind, text string) {
+		if text == "" {
+			return
+		}
+		if len(ops) > 0 && ops[len(ops)-1].kind == kind {
+			ops[len(ops)-1].text += text
+			return
+		}
+		ops = append(ops, diffOp{kind: kind, text: text})
+	}
+
+	add(diffEqual, strings.Join(a[:prefix], ""))
+
+	// lcs[i*width+j] - длина общей подпоследовательности am[i:] и bm[j:]
+	width := len(bm) + 1
+	lcs := make([]int32, (len(am)+1)*width)
+	for i := len(am) - 1; i >= 0; i-- {
+		for j := len(bm) - 1; j >= 0; j-- {
+			if am[i] == bm[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else {
+				lcs[i*width+j] = max(lcs[(i+1)*width+j], lcs[i*width+j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(am) || j < len(bm) {
+		switch {
+		case i < len(am) && j < len(bm) && am[i] == bm[j]:
+			add(diffEqual, am[i])
+			i++
+			j++
+		case j == len(bm) || i < len(am) && lcs[(i+1)*width+j] >= lcs[i*width+j+1]:
+			add(diffDelete, am[i])
+			i++
+		default:
+			add(diffInsert, bm[j])
+			j++
+		}
+	}
+
+	add(diffEqual, strings.Join(a[len(a)-suffix:], ""))
+
+	ops = groupChanges(ops)
+
+	equal := 0
+	for _, op := range ops {
+		if op.kind == diffEqual {
+			equal += utf8.RuneCountInString(op.text)
+		}
+	}
+	if equal*100 < utf8.RuneCountInString(newText)*minDiffEqualPercent {
+		return nil, false
+	}
+	return ops, true
+}
+
+// groupChanges склеивает соседние правки в одну: пробелы между измененными словами уходят в правку,
+// а внутри нее сначала идет все удаленное, потом все добавленное
+func groupChanges(ops []diffOp) []diffOp {
+	var (
+		result  []diffOp
+		deleted strings.Builder
+		added   strings.Builder
+	)
+	flush := func() {
+		if deleted.Len() > 0 {
+			result = append(result, diffOp{kind: diffDelete, text: deleted.String()})
+		}
+		if added.Len() > 0 {
+			result = append(result, diffOp{kind: diffInsert, text: added.String()})
+		}
+		deleted.Reset()
+		added.Reset()
+	}
+
+	for i, op := range ops {
+		switch {
+		case op.kind == diffDelete:
+			deleted.WriteString(op.text)
+		case op.kind == diffInsert:
+			added.WriteString(op.text)
+		case i > 0 && i < len(ops)-1 && strings.TrimSpace(op.text) == "":
+			deleted.WriteString(op.text)
+			added.WriteString(op.text)
+		default:
+			flush()
+			result = append(result, op)
+		}
+	}
+	flush()
+	return result
+}
+
+// tokenizeWords делит текст на слова, пробельные промежутки и отдельные знаки
+func tokenizeWords(text string) []string {
+	var tokens []string
+	start := 0
+	prevClass := -1
+	for i, r := range text {
+		class := runeClass(r)
+		if i > start && (class != prevClass || class == 2) {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+		prevClass = class
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+// runeClass - 0 слово, 1 пробел, 2 знак (каждый знак - отдельный токен)
+func runeClass(r rune) int {
+	switch {
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return 0
+	case unicode.IsSpace(r):
+		return 1
+	}
+	return 2
+}
+
+// diffPart - кусок правки: текст [from, to) одной из версий или вставка вроде "…"
+type diffPart struct {
+	from    int
+	to      int
+	literal string
+}
+
+func renderDiff(ops []diffOp, oldText *entityText, newText *entityText, truncate bool) string {
+	var (
+		result strings.Builder
+		used   int
+		oldPos int
+		newPos int
+	)
+	for i, op := range ops {
+		// удаленное есть только в старой версии, остальное берется из новой вместе с ее разметкой
+		length := utf16Len(op.text)
+		text, from := newText, newPos
+		switch op.kind {
+		case diffDelete:
+			text, from = oldText, oldPos
+			oldPos += length
+		case diffInsert:
+			newPos += length
+		default:
+			oldPos += length
+			newPos += length
+		}
+
+		parts := []diffPart{{from: from, to: from + length}}
+		if truncate && op.kind == diffEqual {
+			parts = shortenEqual(text, from, from+length, i == 0, i == len(ops)-1)
+		}
+
+		var (
+			body     strings.Builder
+			overflow bool
+		)
+		for _, part := range parts {
+			if part.literal != "" {
+				body.WriteString(part.literal)
+				used += utf8.RuneCountInString(part.literal)
+				continue
+			}
+
+			to := part.to
+			if truncate {
+				if left := consts.MAX_MESSAGE_TEXT_LEN - used; text.runeLen(part.from, to) > left {
+					to, overflow = text.runesTo(part.from, max(left, 0)), true
+				}
+				used += text.runeLen(part.from, to)
+			}
+			body.WriteString(text.html(part.from, to, !truncate))
+			if overflow {
+				break
+			}
+		}
+
+		switch op.kind {
+		case diffDelete:
+			result.WriteString("<s>" + body.String() + "</s>")
+		case diffInsert:
+			result.WriteString("<u>" + body.String() + "</u>")
+		default:
+			result.WriteString(body.String())
+		}
+
+		if overflow {
+			result.WriteString("...")
+			break
+		}
+	}
+	return result.String()
+}
+
+// shortenEqual оставляет от длинного неизмененного куска [from, to) только края рядом с правками
+func shortenEqual(text *entityText, from int, to int, first bool, last bool) []diffPart {
+	length := text.runeLen(from, to)
+	switch {
+	case first && last:
+	case first && length > diffContext:
+		return []diffPart{
+			{literal: "…"},
+			{from: text.runesTo(from, length-diffContext), to: to},
+		}
+	case last && length > diffContext:
+		return []diffPart{
+			{from: from, to: text.runesTo(from, diffContext)},
+			{literal: "…"},
+		}
+	case !first && !last && length > 2*diffContext+3:
+		return []diffPart{
+			{from: from, to: text.runesTo(from, diffContext)},
+			{literal: " … "},
+			{from: text.runesTo(from, length-diffContext), to: to},
+		}
+	}
+	return []diffPart{{from: from, to: to}}
+}
@@ -0,0 +1,161 @@
+package format
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/telego"
+)
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldText string
+		newText string
+		want    []diffOp
+		ok      bool
+	}{
+		{
+			name:    "same text",
+			oldText: "hello world",
+			newText: "hello world",
+			want:    []diffOp{{kind: diffEqual, text: "hello world"}},
+			ok:      true,
+		},
+		{
+			name:    "inserted word",
+			oldText: "hello world",
+			newText: "hello there world",
+			want: []diffOp{
+				{kind: diffEqual, text: "hello "},
+				{kind: diffInsert, text: "there "},
+				{kind: diffEqual, text: "world"},
+			},
+			ok: true,
+		},
+		{
+			name:    "replaced word",
+			oldText: "the quick fox",
+			newText: "the slow fox",
+			want: []diffOp{
+				{kind: diffEqual, text: "the "},
+				{kind: diffDelete, text: "quick"},
+				{kind: diffInsert, text: "slow"},
+				{kind: diffEqual, text: " fox"},
+			},
+			ok: true,
+		},
+		{
+			name:    "neighbouring changes are grouped",
+			oldText: "a b c d",
+			newText: "a x y d",
+			want: []diffOp{
+				{kind: diffEqual, text: "a "},
+				{kind: diffDelete, text: "b c"},
+				{kind: diffInsert, text: "x y"},
+				{kind: diffEqual, text: " d"},
+			},
+			ok: true,
+		},
+		{
+			name:    "punctuation is a separate token",
+			oldText: "hi.",
+			newText: "hi!",
+			want: []diffOp{
+				{kind: diffEqual, text: "hi"},
+				{kind: diffDelete, text: "."},
+				{kind: diffInsert, text: "!"},
+			},
+			ok: true,
+		},
+		{
+			name:    "rewritten text",
+			oldText: "completely different",
+			newText: "nothing in common",
+			ok:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := diffWords(tt.oldText, tt.newText)
+			if ok != tt.ok {
+				t.Fatalf("diffWords() ok = %v, want %v", ok, tt.ok)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffWords() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderDiff(t *testing.T) {
+	longPrefix := strings.Repeat("word ", 20)
+
+	tests := []struct {
+		name        string
+		oldText     string
+		oldEntities []telego.MessageEntity
+		newText     string
+		newEntities []telego.MessageEntity
+		truncate    bool
+		want        string
+	}{
+		{
+			name:    "plain text",
+			oldText: "the quick fox",
+			newText: "the slow fox",
+			want:    "the <s>quick</s><u>slow</u> fox",
+		},
+		{
+			name:    "html is escaped",
+			oldText: "a < b",
+			newText: "a < c",
+			want:    "a &lt; <s>b</s><u>c</u>",
+		},
+		{
+			name:        "entities of each version",
+			oldText:     "the quick fox",
+			oldEntities: []telego.MessageEntity{{Type: telego.EntityTypeItalic, Offset: 4, Length: 5}},
+			newText:     "the slow fox",
+			newEntities: []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 0, Length: 8}},
+			want:        "<b>the </b><s><i>quick</i></s><u><b>slow</b></u> fox",
+		},
+		{
+			name:    "offsets after emoji",
+			oldText: "😀 old",
+			newText: "😀 new",
+			newEntities: []telego.MessageEntity{
+				{Type: telego.EntityTypeBold, Offset: 3, Length: 3},
+			},
+			want: "😀 <s>old</s><u><b>new</b></u>",
+		},
+		{
+			name:     "newlines are kept without truncate",
+			oldText:  "line\nold",
+			newText:  "line\nnew",
+			truncate: false,
+			want:     "line\n<s>old</s><u>new</u>",
+		},
+		{
+			name:     "long unchanged start is shortened",
+			oldText:  longPrefix + "old",
+			newText:  longPrefix + "new",
+			truncate: true,
+			want:     "…" + longPrefix[len(longPrefix)-diffContext:] + "<s>old</s><u>new</u>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := inlineDiff(tt.oldText, tt.oldEntities, tt.newText, tt.newEntities, tt.truncate)
+			if !ok {
+				t.Fatal("inlineDiff() fell back to block diff")
+			}
+			if got != tt.want {
+				t.Errorf("inlineDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
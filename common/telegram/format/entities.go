@@ -0,0 +1,210 @@
+package format
+
+import (
+	"cmp"
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/mymmrac/telego"
+)
+
+// entityText - текст сообщения вместе с сущностями (жирный, ссылки, спойлеры...).
+// Смещения сущностей Telegram считает в кодовых единицах UTF-16, поэтому текст хранится в них же
+type entityText struct {
+	units    []uint16
+	entities []telego.MessageEntity
+}
+
+func newEntityText(text string, entities []telego.MessageEntity) *entityText {
+	t := &entityText{units: utf16.Encode([]rune(text))}
+	for _, entity := range entities {
+		if entity.Length <= 0 || entity.Offset < 0 || entity.Offset >= len(t.units) {
+			continue
+		}
+		t.entities = append(t.entities, entity)
+	}
+	// внешние сущности раньше вложенных: по началу, при равном начале длинные первыми
+	slices.SortStableFunc(t.entities, func(a, b telego.MessageEntity) int {
+		return cmp.Or(cmp.Compare(a.Offset, b.Offset), cmp.Compare(b.Length, a.Length))
+	})
+	return t
+}
+
+// truncatedHTML сокращает текст до maxLength символов вместе с "...", как TruncateText.
+// Сущности обрезаются вместе с текстом, теги всегда закрыты
+func (t *entityText) truncatedHTML(maxLength int, newlines bool) string {
+	if t.runeLen(0, len(t.units)) <= maxLength {
+		return t.html(0, len(t.units), newlines)
+	}
+	if maxLength <= 0 {
+		return ""
+	}
+	return t.html(0, t.runesTo(0, max(maxLength-3, 0)), newlines) + "..."
+}
+
+// html размечает кусок [from, to) текста. Сущности, выходящие за границы куска, обрезаются по ним.
+// Без newlines переносы заменяются пробелами, а блочные сущности становятся строчными,
+// чтобы сокращенный текст оставался одной строкой
+func (t *entityText) html(from int, to int, newlines bool) string {
+	var (
+		b      strings.Builder
+		ends   []int
+		closes []string
+		cursor = from
+	)
+	// пишет текст до pos, закрывая по пути закончившиеся сущности
+	advance := func(pos int) {
+		for len(ends) > 0 && ends[len(ends)-1] <= pos {
+			last := len(ends) - 1
+			t.writeText(&b, cursor, ends[last], newlines)
+			b.WriteString(closes[last])
+			cursor = ends[last]
+			ends, closes = ends[:last], closes[:last]
+		}
+		t.writeText(&b, cursor, pos, newlines)
+		cursor = pos
+	}
+
+	for _, entity := range t.entities {
+		start, end := max(entity.Offset, from), min(entity.Offset+entity.Length, to)
+		if start >= end {
+			continue
+		}
+		advance(start)
+		if len(ends) > 0 {
+			// сущности Telegram вложены друг в друга, пересечения обрезаются по внешней
+			end = min(end, ends[len(ends)-1])
+		}
+
+		whole := start == entity.Offset && end == entity.Offset+entity.Length
+		open, closeTag := entityTags(entity, whole, newlines)
+		b.WriteString(open)
+		ends, closes = append(ends, end), append(closes, closeTag)
+	}
+	advance(to)
+
+	return b.String()
+}
+
+func (t *entityText) writeText(b *strings.Builder, from int, to int, newlines bool) {
+	if from >= to {
+		return
+	}
+	text := string(utf16.Decode(t.units[from:to]))
+	if !newlines {
+		text = strings.ReplaceAll(text, "\n", " ")
+	}
+	b.WriteString(html.EscapeString(text))
+}
+
+// entityTags - открывающий и закрывающий теги сущности. Обычные ссылки, упоминания и хэштеги
+// Telegram находит в тексте сам, у них тегов нет
+func entityTags(entity telego.MessageEntity, whole bool, newlines bool) (string, string) {
+	switch entity.Type {
+	case telego.EntityTypeBold:
+		return "<b>", "</b>"
+	case telego.EntityTypeItalic:
+		return "<i>", "</i>"
+	case telego.EntityTypeUnderline:
+		return "<u>", "</u>"
+	case telego.EntityTypeStrikethrough:
+		return "<s>", "</s>"
+	case telego.EntityTypeSpoiler:
+		return "<tg-spoiler>", "</tg-spoiler>"
+	case telego.EntityTypeCode:
+		return "<code>", "</code>"
+	case telego.EntityTypePre:
+		switch {
+		case !newlines:
+			return "<code>", "</code>"
+		case entity.Language != "":
+			return fmt.Sprintf(`<pre><code class="language-%s">`, html.EscapeString(entity.Language)), "</code></pre>"
+		}
+		return "<pre>", "</pre>"
+	case telego.EntityTypeBlockquote:
+		if newlines {
+			return "<blockquote>", "</blockquote>"
+		}
+	case telego.EntityTypeExpandableBlockquote:
+		if newlines {
+			return "<blockquote expandable>", "</blockquote>"
+		}
+	case telego.EntityTypeTextLink:
+		return fmt.Sprintf(`<a href="%s">`, html.EscapeString(entity.URL)), "</a>"
+	case telego.EntityTypeTextMention:
+		if entity.User != nil {
+			return fmt.Sprintf(`<a href="tg://user?id=%d">`, entity.User.ID), "</a>"
+		}
+	case telego.EntityTypeCustomEmoji:
+		// обрезанный кастомный эмодзи Telegram не примет, остается обычный символ
+		if whole && entity.CustomEmojiID != "" {
+			return fmt.Sprintf(`<tg-emoji emoji-id="%s">`, html.EscapeString(entity.CustomEmojiID)), "</tg-emoji>"
+		}
+	}
+	return "", ""
+}
+
+// runeLen - сколько символов в куске [from, to)
+func (t *entityText) runeLen(from int, to int) int {
+	n := 0
+	for i := from; i < to; i++ {
+		if t.pairAt(i) && i+1 < to {
+			i++
+		}
+		n++
+	}
+	return n
+}
+
+// runesTo - позиция через n символов от from, суррогатные пары не разрываются
+func (t *entityText) runesTo(from int, n int) int {
+	pos := from
+	for ; n > 0 && pos < len(t.units); n-- {
+		if t.pairAt(pos) {
+			pos++
+		}
+		pos++
+	}
+	return pos
+}
+
+// pairAt - начинается ли в i суррогатная пара, то есть один символ из двух единиц
+func (t *entityText) pairAt(i int) bool {
+	return i+1 < len(t.units) &&
+		t.units[i] >= 0xd800 && t.units[i] < 0xdc00 &&
+		t.units[i+1] >= 0xdc00 && t.units[i+1] < 0xe000
+}
+
+// utf16Len - длина строки в кодовых единицах UTF-16
+func utf16Len(text string) int {
+	n := 0
+	for _, r := range text {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// sameEntities - одинакова ли разметка, порядок сущностей не важен
+func sameEntities(a []telego.MessageEntity, b []telego.MessageEntity) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	key := func(entity telego.MessageEntity) string {
+		var userID int64
+		if entity.User != nil {
+			userID = entity.User.ID
+		}
+		return fmt.Sprintf("%s|%d|%d|%s|%d|%s|%s", entity.Type, entity.Offset, entity.Length, entity.URL, userID, entity.Language, entity.CustomEmojiID)
+	}
+	keysA, keysB := make([]string, len(a)), make([]string, len(b))
+	for i := range a {
+		keysA[i], keysB[i] = key(a[i]), key(b[i])
+	}
+	slices.Sort(keysA)
+	slices.Sort(keysB)
+	return slices.Equal(keysA, keysB)
+}
@@ -0,0 +1,191 @@
+package format
+
+import (
+	"testing"
+
+	"github.com/mymmrac/telego"
+)
+
+func TestEntityTextHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []telego.MessageEntity
+		newlines bool
+		want     string
+	}{
+		{
+			name:     "escapes text",
+			text:     "a<b & c",
+			newlines: true,
+			want:     "a&lt;b &amp; c",
+		},
+		{
+			name:     "bold",
+			text:     "hello world",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 0, Length: 5}},
+			newlines: true,
+			want:     "<b>hello</b> world",
+		},
+		{
+			name: "nested entities",
+			text: "hello world",
+			entities: []telego.MessageEntity{
+				{Type: telego.EntityTypeItalic, Offset: 6, Length: 5},
+				{Type: telego.EntityTypeBold, Offset: 0, Length: 11},
+			},
+			newlines: true,
+			want:     "<b>hello <i>world</i></b>",
+		},
+		{
+			name: "crossing entities are cut by the outer one",
+			text: "abcdef",
+			entities: []telego.MessageEntity{
+				{Type: telego.EntityTypeBold, Offset: 0, Length: 4},
+				{Type: telego.EntityTypeItalic, Offset: 2, Length: 4},
+			},
+			newlines: true,
+			want:     "<b>ab<i>cd</i></b>ef",
+		},
+		{
+			name:     "offsets are utf-16 units",
+			text:     "😀 hi",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 3, Length: 2}},
+			newlines: true,
+			want:     "😀 <b>hi</b>",
+		},
+		{
+			name:     "text link url is escaped",
+			text:     "link",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeTextLink, Offset: 0, Length: 4, URL: "https://example.com/?a=1&b=2"}},
+			newlines: true,
+			want:     `<a href="https://example.com/?a=1&amp;b=2">link</a>`,
+		},
+		{
+			name:     "text mention",
+			text:     "user",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeTextMention, Offset: 0, Length: 4, User: &telego.User{ID: 42}}},
+			newlines: true,
+			want:     `<a href="tg://user?id=42">user</a>`,
+		},
+		{
+			name:     "pre with language",
+			text:     "x := 1",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypePre, Offset: 0, Length: 6, Language: "go"}},
+			newlines: true,
+			want:     `<pre><code class="language-go">x := 1</code></pre>`,
+		},
+		{
+			name:     "pre becomes code in one line",
+			text:     "a\nb",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypePre, Offset: 0, Length: 3}},
+			newlines: false,
+			want:     "<code>a b</code>",
+		},
+		{
+			name:     "blockquote is dropped in one line",
+			text:     "a\nb",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeBlockquote, Offset: 0, Length: 3}},
+			newlines: false,
+			want:     "a b",
+		},
+		{
+			name:     "blockquote",
+			text:     "a\nb",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeExpandableBlockquote, Offset: 0, Length: 3}},
+			newlines: true,
+			want:     "<blockquote expandable>a\nb</blockquote>",
+		},
+		{
+			name:     "custom emoji",
+			text:     "😀",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeCustomEmoji, Offset: 0, Length: 2, CustomEmojiID: "123"}},
+			newlines: true,
+			want:     `<tg-emoji emoji-id="123">😀</tg-emoji>`,
+		},
+		{
+			name: "entities out of range are ignored",
+			text: "abc",
+			entities: []telego.MessageEntity{
+				{Type: telego.EntityTypeBold, Offset: 3, Length: 2},
+				{Type: telego.EntityTypeItalic, Offset: 0, Length: 0},
+			},
+			newlines: true,
+			want:     "abc",
+		},
+		{
+			name:     "entity longer than text",
+			text:     "abc",
+			entities: []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 1, Length: 10}},
+			newlines: true,
+			want:     "a<b>bc</b>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text := newEntityText(tt.text, tt.entities)
+			if got := text.html(0, len(text.units), tt.newlines); got != tt.want {
+				t.Errorf("html() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityTextTruncatedHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		entities  []telego.MessageEntity
+		maxLength int
+		want      string
+	}{
+		{
+			name:      "fits",
+			text:      "hello",
+			maxLength: 5,
+			want:      "hello",
+		},
+		{
+			name:      "cut inside an entity closes it",
+			text:      "hello world",
+			entities:  []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 0, Length: 11}},
+			maxLength: 8,
+			want:      "<b>hello</b>...",
+		},
+		{
+			name:      "entity after the cut is dropped",
+			text:      "hello world",
+			entities:  []telego.MessageEntity{{Type: telego.EntityTypeItalic, Offset: 6, Length: 5}},
+			maxLength: 8,
+			want:      "hello...",
+		},
+		{
+			name:      "surrogate pairs are not split",
+			text:      "😀😀😀😀😀😀",
+			maxLength: 5,
+			want:      "😀😀...",
+		},
+		{
+			name:      "length is counted in characters, not utf-16 units",
+			text:      "😀😀😀",
+			entities:  []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 2, Length: 2}},
+			maxLength: 3,
+			want:      "😀<b>😀</b>😀",
+		},
+		{
+			name:      "zero length",
+			text:      "hello",
+			maxLength: 0,
+			want:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newEntityText(tt.text, tt.entities).truncatedHTML(tt.maxLength, true); got != tt.want {
+				t.Errorf("truncatedHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,10 @@
 package format
 
 import (
+	"cmp"
 	"fmt"
 	"html"
+	"slices"
 	"ssuspy-bot/consts"
 	"ssuspy-bot/telegram/utils"
 	"ssuspy-bot/types"
@@ -10,10 +12,14 @@ import (
 	"strings"
 	"unicode/utf8"
 
-	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/converter"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/base"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/commonmark"
+	"github.com/JohannesKaufmann/html-to-markdown/v2/plugin/strikethrough"
 	"github.com/mymmrac/telego"
 	tu "github.com/mymmrac/telego/telegoutil"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	nethtml "golang.org/x/net/html"
 )
 
 func CompareMedia(oldMedia, newMedia *types.MediaItem) (diff types.MediaDiff) {
@@ -72,12 +78,15 @@ func SummarizeDeletedMessage(message *telego.Message, loc *i18n.Localizer, trunc
 		}
 	}
 
-	var text string
+	var (
+		text     string
+		entities []telego.MessageEntity
+	)
 	switch {
 	case message.Text != "":
-		text = message.Text
+		text, entities = message.Text, message.Entities
 	case message.Caption != "":
-		text = message.Caption
+		text, entities = message.Caption, message.CaptionEntities
 	}
 
 	if text != "" {
@@ -86,7 +95,7 @@ func SummarizeDeletedMessage(message *telego.Message, loc *i18n.Localizer, trunc
 			loc.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "business.deleted.format.text",
 				TemplateData: map[string]string{
-					"Text": formatText(text, truncate),
+					"Text": formatText(text, entities, truncate),
 				},
 			}),
 		)
@@ -128,6 +137,8 @@ func SummarizeDeletedMessage(message *telego.Message, loc *i18n.Localizer, trunc
 		)
 	}
 
+	summary = append(summary, describeContent(message, loc, truncate)...)
+
 	if message.Location != nil {
 		lat := strconv.FormatFloat(message.Location.Latitude, 'f', -1, 64)
 		lon := strconv.FormatFloat(message.Location.Longitude, 'f', -1, 64)
@@ -153,38 +164,89 @@ func SummarizeDeletedMessage(message *telego.Message, loc *i18n.Localizer, trunc
 }
 
 func SummarizeDeletedMessages(messages []*telego.Message, name string, loc *i18n.Localizer, truncate bool, offset int, messagesLen int) string {
-	if messagesLen == 1 {
+	return SummarizeDeletedMessagesFit(messages, name, loc, truncate, offset, messagesLen, 0, "")
+}
+
+// SummarizeDeletedMessagesFit укладывает сводку в maxLength (0 - без ограничения). Не поместившиеся
+// сообщения отбрасываются целиком, чтобы не разрезать HTML, вместо них в конце ставится overflow
+func SummarizeDeletedMessagesFit(
+	messages []*telego.Message,
+	name string,
+	loc *i18n.Localizer,
+	truncate bool,
+	offset int,
+	messagesLen int,
+	maxLength int,
+	overflow string,
+) string {
+	return summarizeMessages("business.deleted.format.message", messages, name, loc, truncate, offset, messagesLen, maxLength, overflow)
+}
+
+// SummarizeStoredMessages - сводка версий сообщения из базы, которое не обязательно удалено (поиск, история)
+func SummarizeStoredMessages(messages []*telego.Message, name string, loc *i18n.Localizer) string {
+	return summarizeMessages("business.stored.format.message", messages, name, loc, false, 0, len(messages), 0, "")
+}
+
+func summarizeMessages(
+	messageID string,
+	messages []*telego.Message,
+	name string,
+	loc *i18n.Localizer,
+	truncate bool,
+	offset int,
+	messagesLen int,
+	maxLength int,
+	overflow string,
+) string {
+	render := func(result string) string {
 		return loc.MustLocalize(&i18n.LocalizeConfig{
-			MessageID: "business.deleted.format.message",
-			TemplateData: map[string]string{
-				"Result":           SummarizeDeletedMessage(messages[0], loc, truncate),
+			MessageID: messageID,
+			TemplateData: map[string]any{
+				"Count":            messagesLen,
+				"Result":           result,
 				"ResolvedChatName": name,
 			},
 			PluralCount: messagesLen,
 		})
 	}
 
-	var result strings.Builder
+	if messagesLen == 1 {
+		return FitItems([]string{SummarizeDeletedMessage(messages[0], loc, truncate)}, maxLength, overflow, render)
+	}
+
+	items := make([]string, 0, len(messages))
 	for i, message := range messages {
-		summarize := SummarizeDeletedMessage(message, loc, truncate)
-		result.WriteString(loc.MustLocalize(&i18n.LocalizeConfig{
+		items = append(items, loc.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "business.deleted.format.messageItem",
 			TemplateData: map[string]any{
 				"Count":   i + 1 + offset,
-				"Message": summarize,
+				"Message": SummarizeDeletedMessage(message, loc, truncate),
 			},
 		}))
 	}
+	return FitItems(items, maxLength, overflow, render)
+}
 
-	return loc.MustLocalize(&i18n.LocalizeConfig{
-		MessageID: "business.deleted.format.message",
-		TemplateData: map[string]any{
-			"Count":            messagesLen,
-			"Result":           result.String(),
-			"ResolvedChatName": name,
-		},
-		PluralCount: messagesLen,
-	})
+// FitItems склеивает items и оборачивает их render, пока результат помещается в maxLength (0 - без ограничения).
+// Элементы не режутся, поэтому HTML в них остается целым. Если поместились не все, после последнего добавляется overflow
+func FitItems(items []string, maxLength int, overflow string, render func(result string) string) string {
+	full := render(strings.Join(items, ""))
+	if maxLength <= 0 || utf8.RuneCountInString(full) <= maxLength {
+		return full
+	}
+
+	budget := maxLength - utf8.RuneCountInString(render(overflow))
+	var result strings.Builder
+	for _, item := range items {
+		itemLen := utf8.RuneCountInString(item)
+		if itemLen > budget {
+			break
+		}
+		budget -= itemLen
+		result.WriteString(item)
+	}
+	result.WriteString(overflow)
+	return render(result.String())
 }
 
 func EditedDiff(oldMsg *telego.Message, newMsg *telego.Message, loc *i18n.Localizer, truncate bool) ([]string, types.MediaDiff) {
@@ -195,23 +257,16 @@ func EditedDiff(oldMsg *telego.Message, newMsg *telego.Message, loc *i18n.Locali
 	textEqual := newMsg.Text == oldMsg.Text
 
 	if oldHasText && newHasText && !textEqual {
-		changes = append(
-			changes,
-			loc.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "business.edited.text.changed",
-				TemplateData: map[string]string{
-					"Old": formatText(oldMsg.Text, truncate),
-					"New": formatText(newMsg.Text, truncate),
-				},
-			}),
-		)
+		changes = append(changes, textChange(oldMsg.Text, oldMsg.Entities, newMsg.Text, newMsg.Entities, loc, truncate))
+	} else if oldHasText && newHasText && !sameEntities(oldMsg.Entities, newMsg.Entities) {
+		changes = append(changes, formattingChange(newMsg.Text, newMsg.Entities, loc, truncate))
 	} else if !oldHasText && newHasText {
 		changes = append(
 			changes,
 			loc.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "business.edited.text.added",
 				TemplateData: map[string]string{
-					"New": formatText(newMsg.Text, truncate),
+					"New": formatText(newMsg.Text, newMsg.Entities, truncate),
 				},
 			}),
 		)
@@ -221,7 +276,7 @@ func EditedDiff(oldMsg *telego.Message, newMsg *telego.Message, loc *i18n.Locali
 			loc.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "business.edited.text.removed",
 				TemplateData: map[string]string{
-					"New": formatText(oldMsg.Text, truncate),
+					"New": formatText(oldMsg.Text, oldMsg.Entities, truncate),
 				},
 			}),
 		)
@@ -232,23 +287,16 @@ func EditedDiff(oldMsg *telego.Message, newMsg *telego.Message, loc *i18n.Locali
 	captionEqual := newMsg.Caption == oldMsg.Caption
 
 	if oldHasCaption && newHasCaption && !captionEqual {
-		changes = append(
-			changes,
-			loc.MustLocalize(&i18n.LocalizeConfig{
-				MessageID: "business.edited.text.changed",
-				TemplateData: map[string]string{
-					"Old": formatText(oldMsg.Caption, truncate),
-					"New": formatText(newMsg.Caption, truncate),
-				},
-			}),
-		)
+		changes = append(changes, textChange(oldMsg.Caption, oldMsg.CaptionEntities, newMsg.Caption, newMsg.CaptionEntities, loc, truncate))
+	} else if oldHasCaption && newHasCaption && !sameEntities(oldMsg.CaptionEntities, newMsg.CaptionEntities) {
+		changes = append(changes, formattingChange(newMsg.Caption, newMsg.CaptionEntities, loc, truncate))
 	} else if !oldHasCaption && newHasCaption && newMsg.Caption != oldMsg.Text {
 		changes = append(
 			changes,
 			loc.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "business.edited.text.added",
 				TemplateData: map[string]string{
-					"New": formatText(newMsg.Caption, truncate),
+					"New": formatText(newMsg.Caption, newMsg.CaptionEntities, truncate),
 				},
 			}),
 		)
@@ -258,12 +306,24 @@ func EditedDiff(oldMsg *telego.Message, newMsg *telego.Message, loc *i18n.Locali
 			loc.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "business.edited.text.removed",
 				TemplateData: map[string]string{
-					"New": formatText(oldMsg.Caption, truncate),
+					"New": formatText(oldMsg.Caption, oldMsg.CaptionEntities, truncate),
 				},
 			}),
 		)
 	}
 
+	// геолокация сюда не входит: трансляция обновляет ее правками каждые несколько секунд
+	oldContent := describeContent(oldMsg, loc, truncate)
+	newContent := describeContent(newMsg, loc, truncate)
+	if len(newContent) > 0 && !slices.Equal(oldContent, newContent) {
+		changes = append(changes, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.content",
+			TemplateData: map[string]string{
+				"New": strings.Join(newContent, "\n"),
+			},
+		}))
+	}
+
 	oldMedia := utils.GetFile(oldMsg)
 	newMedia := utils.GetFile(newMsg)
 	mediaDiff := CompareMedia(oldMedia, newMedia)
@@ -301,6 +361,36 @@ func EditedDiff(oldMsg *telego.Message, newMsg *telego.Message, loc *i18n.Locali
 	return changes, mediaDiff
 }
 
+// textChange - изменение текста пословно, а если переписано почти все - старым и новым блоками
+func textChange(oldText string, oldEntities []telego.MessageEntity, newText string, newEntities []telego.MessageEntity, loc *i18n.Localizer, truncate bool) string {
+	if diff, ok := inlineDiff(oldText, oldEntities, newText, newEntities, truncate); ok {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "business.edited.text.diff",
+			TemplateData: map[string]string{
+				"Diff": diff,
+			},
+		})
+	}
+
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "business.edited.text.changed",
+		TemplateData: map[string]string{
+			"Old": formatText(oldText, oldEntities, truncate),
+			"New": formatText(newText, newEntities, truncate),
+		},
+	})
+}
+
+// formattingChange - текст тот же, поменялась только разметка (жирный, ссылка...), показывается новая версия
+func formattingChange(text string, entities []telego.MessageEntity, loc *i18n.Localizer, truncate bool) string {
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: "business.edited.text.formatting",
+		TemplateData: map[string]string{
+			"New": formatText(text, entities, truncate),
+		},
+	})
+}
+
 func TruncateText(text string, maxLength int, replaceN bool) (result string) {
 	return CustomTruncateText(text, maxLength, "...", replaceN)
 }
@@ -390,9 +480,31 @@ func getForwardInfo(msg *telego.Message, loc *i18n.Localizer) string {
 	}
 }
 
+// mdConverter - commonmark с зачеркиванием. Для подчеркиваний из diff в markdown нет разметки,
+// они остаются html тегами, спойлеры размечаются как в MarkdownV2 Telegram
+var mdConverter = func() *converter.Converter {
+	conv := converter.NewConverter(converter.WithPlugins(
+		base.NewBasePlugin(),
+		commonmark.NewCommonmarkPlugin(),
+		strikethrough.NewStrikethroughPlugin(),
+	))
+	conv.Register.RendererFor("u", converter.TagTypeInline, wrapRenderer("<u>", "</u>"), converter.PriorityEarly)
+	conv.Register.RendererFor("tg-spoiler", converter.TagTypeInline, wrapRenderer("||", "||"), converter.PriorityEarly)
+	return conv
+}()
+
+func wrapRenderer(prefix string, suffix string) converter.HandleRenderFunc {
+	return func(ctx converter.Context, w converter.Writer, node *nethtml.Node) converter.RenderStatus {
+		w.WriteString(prefix)
+		ctx.RenderChildNodes(ctx, w, node)
+		w.WriteString(suffix)
+		return converter.RenderSuccess
+	}
+}
+
 func GetMDInputFile(text string, fileName string) telego.InputFile {
 	text = strings.ReplaceAll(text, "\n", "<br>")
-	newText, err := htmltomarkdown.ConvertString(text)
+	newText, err := mdConverter.ConvertString(text)
 	if err == nil {
 		fileName += ".md"
 	} else {
@@ -429,6 +541,17 @@ func FilterMessagesByDate(msgs []*telego.Message) []*telego.Message {
 	return result
 }
 
+// SortRevisions - версии одного сообщения от исходной к последней, повторы одной правки убираются
+func SortRevisions(msgs []*telego.Message) []*telego.Message {
+	revisions := slices.Clone(msgs)
+	slices.SortStableFunc(revisions, func(a, b *telego.Message) int {
+		return cmp.Compare(chooseTime(a.EditDate, a.Date), chooseTime(b.EditDate, b.Date))
+	})
+	return slices.CompactFunc(revisions, func(a, b *telego.Message) bool {
+		return a.EditDate == b.EditDate && a.Date == b.Date
+	})
+}
+
 func chooseTime(editDate, date int64) int64 {
 	if editDate > 0 {
 		return editDate
@@ -447,17 +570,15 @@ func Name(name string, lastName string) string {
 	return name
 }
 
-func formatText(text string, truncate bool) string {
+// formatText - текст сообщения с разметкой из entities, с truncate сокращенный до одной строки
+func formatText(text string, entities []telego.MessageEntity, truncate bool) string {
+	t := newEntityText(text, entities)
 	if truncate {
-		text = TruncateText(text, consts.MAX_MESSAGE_TEXT_LEN, true)
+		return t.truncatedHTML(consts.MAX_MESSAGE_TEXT_LEN, false)
 	}
-	return html.EscapeString(
-		text,
-	)
+	return t.html(0, len(t.units), true)
 }
 
-func Caption(text string) string {
-	return html.EscapeString(
-		TruncateText(text, consts.MAX_MEDIA_CAPTION_LEN, false),
-	)
+func Caption(text string, entities []telego.MessageEntity) string {
+	return newEntityText(text, entities).truncatedHTML(consts.MAX_MEDIA_CAPTION_LEN, true)
 }
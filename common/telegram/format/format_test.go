@@ -0,0 +1,243 @@
+package format
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/mymmrac/telego"
+	"golang.org/x/text/language"
+
+	"ssuspy-bot/telegram/locales"
+	"ssuspy-bot/types"
+)
+
+func TestMain(m *testing.M) {
+	if err := locales.Init(language.English); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSummarizeDeletedMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *telego.Message
+		want    string
+	}{
+		{
+			name:    "text",
+			message: &telego.Message{Text: "hi <there>"},
+			want:    "📝 <b>text:</b> hi &lt;there&gt;",
+		},
+		{
+			name: "photo with caption",
+			message: &telego.Message{
+				Caption:         "look",
+				CaptionEntities: []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 0, Length: 4}},
+				Photo:           []telego.PhotoSize{{FileID: "small"}, {FileID: "big"}},
+			},
+			want: "📝 <b>text:</b> <b>look</b>\n<b>📷 photo</b>",
+		},
+		{
+			name:    "hidden forward",
+			message: &telego.Message{Text: "fwd", ForwardOrigin: &telego.MessageOriginHiddenUser{Type: telego.OriginTypeHiddenUser, SenderUserName: "Anon"}},
+			want:    "↪️ <b>forwarded from:</b> 👤 Anon\n📝 <b>text:</b> fwd",
+		},
+		{
+			name: "paid media with several items",
+			message: &telego.Message{
+				PaidMedia: &telego.PaidMediaInfo{
+					StarCount: 50,
+					PaidMedia: []telego.PaidMedia{
+						&telego.PaidMediaPhoto{Type: telego.PaidMediaTypePhoto, Photo: []telego.PhotoSize{{FileID: "p"}}},
+						&telego.PaidMediaVideo{Type: telego.PaidMediaTypeVideo, Video: telego.Video{FileID: "v"}},
+						&telego.PaidMediaPreview{Type: telego.PaidMediaTypePreview},
+					},
+				},
+			},
+			want: "⭐️ <b>paid media</b> for 50 ⭐️: 📷 photo, 🎬 video, 🔒 hidden media",
+		},
+		{
+			name: "quiz",
+			message: &telego.Message{Poll: &telego.Poll{
+				Type:     telego.PollTypeQuiz,
+				Question: "2+2?",
+				Options:  []telego.PollOption{{Text: "4", VoterCount: 3}, {Text: "5", VoterCount: 1}},
+			}},
+			want: "📊 <b>quiz:</b> 2+2?\n • 4 — 3\n • 5 — 1",
+		},
+		{
+			name:    "contact",
+			message: &telego.Message{Contact: &telego.Contact{FirstName: "Ann", PhoneNumber: "+100"}},
+			want:    "👤 <b>contact:</b> Ann, +100",
+		},
+		{
+			name:    "payment in currency",
+			message: &telego.Message{SuccessfulPayment: &telego.SuccessfulPayment{TotalAmount: 1999, Currency: "USD"}},
+			want:    "💳 <b>payment:</b> 19.99 USD",
+		},
+		{
+			name:    "payment in stars",
+			message: &telego.Message{SuccessfulPayment: &telego.SuccessfulPayment{TotalAmount: 25, Currency: "XTR"}},
+			want:    "💳 <b>payment:</b> 25 ⭐️",
+		},
+		{
+			name:    "service message",
+			message: &telego.Message{PinnedMessage: &telego.Message{}},
+			want:    "⚙️ <b>service message:</b> message pinned",
+		},
+		{
+			name:    "location",
+			message: &telego.Message{Location: &telego.Location{Latitude: 55.75, Longitude: 37.61}},
+			want:    "📍 location: 55.75 37.61",
+		},
+		{
+			name:    "empty",
+			message: &telego.Message{},
+			want:    "<b>empty or unknown message was deleted</b>",
+		},
+	}
+
+	loc := locales.NewLocalizer("en")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SummarizeDeletedMessage(tt.message, loc, false); got != tt.want {
+				t.Errorf("SummarizeDeletedMessage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditedDiff(t *testing.T) {
+	photo := func(fileID string) []telego.PhotoSize {
+		return []telego.PhotoSize{{FileID: fileID, FileUniqueID: fileID}}
+	}
+
+	tests := []struct {
+		name        string
+		oldMsg      *telego.Message
+		newMsg      *telego.Message
+		wantChanges []string
+		wantAdded   bool
+		wantRemoved bool
+	}{
+		{
+			name:   "nothing changed",
+			oldMsg: &telego.Message{Text: "same"},
+			newMsg: &telego.Message{Text: "same"},
+		},
+		{
+			name:        "text edited",
+			oldMsg:      &telego.Message{Text: "the quick fox"},
+			newMsg:      &telego.Message{Text: "the slow fox"},
+			wantChanges: []string{"📝 <b>text changed:</b>\nthe <s>quick</s><u>slow</u> fox"},
+		},
+		{
+			name:        "text rewritten",
+			oldMsg:      &telego.Message{Text: "completely different"},
+			newMsg:      &telego.Message{Text: "nothing in common"},
+			wantChanges: []string{"📝 <b>text changed:</b>\n- old: completely different\n+ new: nothing in common"},
+		},
+		{
+			name:   "formatting changed",
+			oldMsg: &telego.Message{Text: "bold"},
+			newMsg: &telego.Message{
+				Text:     "bold",
+				Entities: []telego.MessageEntity{{Type: telego.EntityTypeBold, Offset: 0, Length: 4}},
+			},
+			wantChanges: []string{"📝 <b>formatting changed:</b> <b>bold</b>"},
+		},
+		{
+			name:        "caption added",
+			oldMsg:      &telego.Message{Photo: photo("a")},
+			newMsg:      &telego.Message{Photo: photo("a"), Caption: "new"},
+			wantChanges: []string{"📝 <b>text added:</b> new"},
+		},
+		{
+			name:        "caption removed",
+			oldMsg:      &telego.Message{Photo: photo("a"), Caption: "old"},
+			newMsg:      &telego.Message{Photo: photo("a")},
+			wantChanges: []string{"📝 <b>text removed:</b> old"},
+		},
+		{
+			name:        "photo replaced",
+			oldMsg:      &telego.Message{Photo: photo("a")},
+			newMsg:      &telego.Message{Photo: photo("b")},
+			wantChanges: []string{"<b>📷 photo updated</b>"},
+			wantAdded:   true,
+			wantRemoved: true,
+		},
+		{
+			name:        "photo replaced with video",
+			oldMsg:      &telego.Message{Photo: photo("a")},
+			newMsg:      &telego.Message{Video: &telego.Video{FileID: "v"}},
+			wantChanges: []string{"<b>🎬 video added</b>"},
+			wantAdded:   true,
+			wantRemoved: true,
+		},
+		{
+			name:        "media added to text",
+			oldMsg:      &telego.Message{Text: "hi"},
+			newMsg:      &telego.Message{Caption: "hi", Document: &telego.Document{FileID: "d"}},
+			wantChanges: []string{"<b>📄 document added</b>"},
+			wantAdded:   true,
+		},
+		{
+			name: "poll changed",
+			oldMsg: &telego.Message{Poll: &telego.Poll{
+				Type:     telego.PollTypeRegular,
+				Question: "lunch?",
+				Options:  []telego.PollOption{{Text: "yes"}},
+			}},
+			newMsg: &telego.Message{Poll: &telego.Poll{
+				Type:     telego.PollTypeRegular,
+				Question: "lunch?",
+				Options:  []telego.PollOption{{Text: "yes", VoterCount: 2}},
+			}},
+			wantChanges: []string{"🔄 <b>content changed:</b>\n📊 <b>poll:</b> lunch?\n • yes — 2"},
+		},
+		{
+			name:   "live location moved",
+			oldMsg: &telego.Message{Location: &telego.Location{Latitude: 1, Longitude: 1}},
+			newMsg: &telego.Message{Location: &telego.Location{Latitude: 2, Longitude: 2}},
+		},
+	}
+
+	loc := locales.NewLocalizer("en")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, mediaDiff := EditedDiff(tt.oldMsg, tt.newMsg, loc, false)
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("EditedDiff() changes = %q, want %q", changes, tt.wantChanges)
+			}
+			if (mediaDiff.Added != nil) != tt.wantAdded || (mediaDiff.Removed != nil) != tt.wantRemoved {
+				t.Errorf("EditedDiff() media diff = %+v, want added %v, removed %v", mediaDiff, tt.wantAdded, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestCompareMedia(t *testing.T) {
+	photo := &types.MediaItem{Type: "photo", FileID: "a"}
+
+	tests := []struct {
+		name     string
+		oldMedia *types.MediaItem
+		newMedia *types.MediaItem
+		want     types.MediaDiff
+	}{
+		{name: "no media"},
+		{name: "same file", oldMedia: photo, newMedia: &types.MediaItem{Type: "photo", FileID: "a"}},
+		{name: "added", newMedia: photo, want: types.MediaDiff{Added: photo}},
+		{name: "removed", oldMedia: photo, want: types.MediaDiff{Removed: photo}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareMedia(tt.oldMedia, tt.newMedia); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareMedia() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -15,8 +15,9 @@ import (
 	"github.com/rs/zerolog/log"
 	"golang.org/x/text/language"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 
+	"ssuspy-common/crypto"
+	"ssuspy-common/grpcauth"
 	"ssuspy-creator-bot/config"
 	"ssuspy-creator-bot/consts"
 	proto "ssuspy-creator-bot/pb"
@@ -58,7 +59,12 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to initialize i18n")
 	}
 
-	mongoRepo, err := repository.NewMongoRepository(cfg.Mongo)
+	box, err := crypto.NewBox(cfg.TokenEncryptionKey)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid token encryption key")
+	}
+
+	mongoRepo, err := repository.NewMongoRepository(cfg.Mongo, box)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to MongoDB")
 	}
@@ -69,10 +75,15 @@ func main() {
 		log.Fatal().Err(err).Msg("failed to connect to Redis")
 	}
 
-	grpcConn, err := grpc.NewClient(
-		fmt.Sprint(cfg.Grpc),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
-	)
+	if !grpcauth.Secured(cfg.Grpc.TLS(), cfg.Grpc.Token) {
+		log.Warn().Msg("gRPC connection to business bot is not protected, set GRPC_SERVER_TLS_* or GRPC_SERVER_TOKEN")
+	}
+	dialOptions, err := grpcauth.DialOptions(cfg.Grpc.TLS(), cfg.Grpc.Token)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid gRPC security configuration")
+	}
+
+	grpcConn, err := grpc.NewClient(fmt.Sprint(cfg.Grpc), dialOptions...)
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to connect to GRPC")
 	}
@@ -112,28 +123,51 @@ func main() {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 
+	allowedUpdates := []string{
+		"update_id",
+		"message",
+		"callback_query",
+		"my_chat_member",
+	}
+
 	var updates <-chan telego.Update
-	url := "http://creator-bot:8080/bot"
-	updates, err = bot.UpdatesViaWebhook(
-		ctx,
-		telego.WebhookHTTPServeMux(mux, "POST /bot", bot.SecretToken()),
-		telego.WithWebhookBuffer(128),
-		telego.WithWebhookSet(ctx, &telego.SetWebhookParams{
-			URL:         url,
-			SecretToken: bot.SecretToken(),
-			AllowedUpdates: []string{
-				"update_id",
-				"message",
-				"callback_query",
-				"my_chat_member",
+	switch cfg.Updates.Mode {
+	case consts.UPDATES_MODE_POLLING:
+		// getUpdates не работает, пока у бота установлен вебхук
+		if err := bot.DeleteWebhook(ctx, &telego.DeleteWebhookParams{DropPendingUpdates: false}); err != nil {
+			log.Fatal().Err(err).Msg("error delete webhook before long polling")
+		}
+
+		updates, err = bot.UpdatesViaLongPolling(
+			ctx,
+			&telego.GetUpdatesParams{
+				Timeout:        30,
+				AllowedUpdates: allowedUpdates,
 			},
-			DropPendingUpdates: false,
-		}),
-	)
-	if err != nil {
-		log.Fatal().Err(err).Msg("error create bot updates via webhook (local bot api)")
+			telego.WithLongPollingBuffer(128),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error create bot updates via long polling")
+		}
+		log.Info().Str("botApiURL", cfg.TelegramBot.ApiURL).Msg("successfully started long polling")
+	default:
+		url := cfg.Updates.WebhookURL
+		updates, err = bot.UpdatesViaWebhook(
+			ctx,
+			telego.WebhookHTTPServeMux(mux, "POST /bot", bot.SecretToken()),
+			telego.WithWebhookBuffer(128),
+			telego.WithWebhookSet(ctx, &telego.SetWebhookParams{
+				URL:                url,
+				SecretToken:        bot.SecretToken(),
+				AllowedUpdates:     allowedUpdates,
+				DropPendingUpdates: false,
+			}),
+		)
+		if err != nil {
+			log.Fatal().Err(err).Msg("error create bot updates via webhook")
+		}
+		log.Info().Str("botApiURL", cfg.TelegramBot.ApiURL).Str("url", url).Msg("successfully set webhook")
 	}
-	log.Info().Str("localBotApiURL", cfg.TelegramBot.ApiURL).Str("url", url).Msg("successfully set webhook (local bot api)")
 
 	bh, err := th.NewBotHandler(bot, updates)
 	if err != nil {
@@ -180,6 +214,8 @@ func main() {
 		)
 		starndard.Handle(utils.WithProm("handleBotsList", handlerGroup.HandleBotsList), th.CallbackDataPrefix(consts.CALLBACK_PREFIX_BOT_LIST), th.AnyCallbackQueryWithMessage())
 		starndard.Handle(utils.WithProm("handleBotItem", handlerGroup.HandleBotItem), th.CallbackDataPrefix(consts.CALLBACK_PREFIX_BOT_ITEM), th.AnyCallbackQueryWithMessage())
+		starndard.Handle(utils.WithProm("handleBotRestart", handlerGroup.HandleBotRestart), th.CallbackDataPrefix(consts.CALLBACK_PREFIX_BOT_RESTART), th.AnyCallbackQueryWithMessage())
+		starndard.Handle(utils.WithProm("handleBotQuota", handlerGroup.HandleBotQuota), th.CallbackDataPrefix(consts.CALLBACK_PREFIX_BOT_QUOTA), th.AnyCallbackQueryWithMessage())
 		starndard.Handle(utils.WithProm("handleBotRemove", handlerGroup.HandleBotRemove), th.CallbackDataPrefix(consts.CALLBACK_PREFIX_BOT_REMOVE), th.AnyCallbackQueryWithMessage())
 		starndard.Handle(utils.WithProm("handleToken", handlerGroup.HandleToken), th.AnyMessageWithText())
 	}
@@ -6,6 +6,9 @@ import (
 	"net/url"
 
 	"github.com/sethvargo/go-envconfig"
+
+	"ssuspy-common/grpcauth"
+	"ssuspy-creator-bot/consts"
 )
 
 var Config StructConfig
@@ -16,24 +19,46 @@ func NewConfig() (StructConfig, error) {
 	if err := envconfig.Process(context.Background(), &config); err != nil {
 		return StructConfig{}, err
 	}
+	if err := config.Updates.Validate(); err != nil {
+		return StructConfig{}, err
+	}
 
 	Config = config
 	return config, nil
 }
 
 type StructConfig struct {
-	Mongo            *MongoConfig `env:", prefix=MONGO_"`
-	Redis            *RedisConfig `env:", prefix=REDIS_"`
-	TelegramBot      *BotConfig   `env:", prefix=TELEGRAM_"`
-	Grpc             *GrpcConfig  `env:", prefix=GRPC_SERVER_"`
-	CreatorGithubURL string       `env:"CREATOR_GITHUB_URL"`
-	MaxBotsByUser    int64        `env:"MAX_BOTS_BY_USER, default=10"`
-	DevMode          bool         `env:"DEV_MODE, default=false"`
+	Mongo              *MongoConfig   `env:", prefix=MONGO_"`
+	Redis              *RedisConfig   `env:", prefix=REDIS_"`
+	Updates            *UpdatesConfig `env:", prefix=UPDATES_"`
+	TelegramBot        *BotConfig     `env:", prefix=TELEGRAM_"`
+	Grpc               *GrpcConfig    `env:", prefix=GRPC_SERVER_"`
+	CreatorGithubURL   string         `env:"CREATOR_GITHUB_URL"`
+	MaxBotsByUser      int64          `env:"MAX_BOTS_BY_USER, default=10"`
+	DevMode            bool           `env:"DEV_MODE, default=false"`
+	TokenEncryptionKey string         `env:"TOKEN_ENCRYPTION_KEY, required"`
 }
 
 type GrpcConfig struct {
 	Host string `env:"HOST, required"`
 	Port int    `env:"PORT, default=50051"`
+
+	// сертификат и ключ creator_bot для mTLS, TLSCA - CA сертификата business_bot
+	TLSCert       string `env:"TLS_CERT"`
+	TLSKey        string `env:"TLS_KEY"`
+	TLSCA         string `env:"TLS_CA"`
+	TLSServerName string `env:"TLS_SERVER_NAME"`
+	// Token - общий секрет с business_bot, для сетей без mTLS
+	Token string `env:"TOKEN"`
+}
+
+func (g *GrpcConfig) TLS() grpcauth.TLS {
+	return grpcauth.TLS{
+		CertFile:   g.TLSCert,
+		KeyFile:    g.TLSKey,
+		CAFile:     g.TLSCA,
+		ServerName: g.TLSServerName,
+	}
 }
 
 func (g *GrpcConfig) String() string {
@@ -76,5 +101,20 @@ type RedisConfig struct {
 
 type BotConfig struct {
 	Token  string `env:"TOKEN, required"`
-	ApiURL string `env:"API_URL, required"`
+	ApiURL string `env:"API_URL, default=https://api.telegram.org"`
+}
+
+type UpdatesConfig struct {
+	// webhook - Telegram сам присылает обновления на WebhookURL, polling - бот забирает их через getUpdates
+	Mode string `env:"MODE, default=webhook"`
+	// WebhookURL - полный адрес вебхука
+	WebhookURL string `env:"WEBHOOK_URL, default=http://creator-bot:8080/bot"`
+}
+
+func (u *UpdatesConfig) Validate() error {
+	switch u.Mode {
+	case consts.UPDATES_MODE_WEBHOOK, consts.UPDATES_MODE_POLLING:
+		return nil
+	}
+	return fmt.Errorf("unknown updates mode %q, expected %q or %q", u.Mode, consts.UPDATES_MODE_WEBHOOK, consts.UPDATES_MODE_POLLING)
 }
@@ -7,6 +7,11 @@ const MonthInSeconds = 30 * 24 * 60 * 60
 
 const MAX_NAME_LEN = 128
 
+const (
+	UPDATES_MODE_WEBHOOK = "webhook"
+	UPDATES_MODE_POLLING = "polling"
+)
+
 const CALLBACK_PREFIX_BACK_TO_START = "+++1"
 const CALLBACK_PREFIX_LANG = "+++2"
 const CALLBACK_PREFIX_LANG_CHANGE = "+++3"
@@ -14,3 +19,19 @@ const CALLBACK_PREFIX_LANG_CHANGE = "+++3"
 const CALLBACK_PREFIX_BOT_LIST = "+++4"
 const CALLBACK_PREFIX_BOT_ITEM = "+++5"
 const CALLBACK_PREFIX_BOT_REMOVE = "+++6"
+const CALLBACK_PREFIX_BOT_RESTART = "+++7"
+const CALLBACK_PREFIX_BOT_QUOTA = "+++8"
+
+// совпадают с политиками квот в business_bot
+const (
+	QUOTA_POLICY_REJECT      = "reject"
+	QUOTA_POLICY_DROP_OLDEST = "drop_oldest"
+)
+
+// поля квоты в callback data экрана квот
+const (
+	QUOTA_FIELD_MESSAGES      = "messages"
+	QUOTA_FIELD_MESSAGE_BYTES = "message_bytes"
+	QUOTA_FIELD_MEDIA_BYTES   = "media_bytes"
+	QUOTA_FIELD_POLICY        = "policy"
+)
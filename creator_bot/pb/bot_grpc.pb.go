@@ -19,8 +19,13 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Bot_AddBot_FullMethodName    = "/pb.Bot/AddBot"
-	Bot_RemoveBot_FullMethodName = "/pb.Bot/RemoveBot"
+	Bot_AddBot_FullMethodName          = "/pb.Bot/AddBot"
+	Bot_RemoveBot_FullMethodName       = "/pb.Bot/RemoveBot"
+	Bot_ListBots_FullMethodName        = "/pb.Bot/ListBots"
+	Bot_GetBotStatus_FullMethodName    = "/pb.Bot/GetBotStatus"
+	Bot_RestartBot_FullMethodName      = "/pb.Bot/RestartBot"
+	Bot_SubscribeEvents_FullMethodName = "/pb.Bot/SubscribeEvents"
+	Bot_ForwardUpdate_FullMethodName   = "/pb.Bot/ForwardUpdate"
 )
 
 // BotClient is the client API for Bot service.
@@ -29,6 +34,11 @@ const (
 type BotClient interface {
 	AddBot(ctx context.Context, in *AddBotRequest, opts ...grpc.CallOption) (*AddBotReply, error)
 	RemoveBot(ctx context.Context, in *RemoveBotRequest, opts ...grpc.CallOption) (*RemoveBotReply, error)
+	ListBots(ctx context.Context, in *ListBotsRequest, opts ...grpc.CallOption) (*ListBotsReply, error)
+	GetBotStatus(ctx context.Context, in *GetBotStatusRequest, opts ...grpc.CallOption) (*BotStatus, error)
+	RestartBot(ctx context.Context, in *RestartBotRequest, opts ...grpc.CallOption) (*RestartBotReply, error)
+	SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error)
+	ForwardUpdate(ctx context.Context, in *ForwardUpdateRequest, opts ...grpc.CallOption) (*ForwardUpdateReply, error)
 }
 
 type botClient struct {
@@ -59,12 +69,76 @@ func (c *botClient) RemoveBot(ctx context.Context, in *RemoveBotRequest, opts ..
 	return out, nil
 }
 
+func (c *botClient) ListBots(ctx context.Context, in *ListBotsRequest, opts ...grpc.CallOption) (*ListBotsReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListBotsReply)
+	err := c.cc.Invoke(ctx, Bot_ListBots_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *botClient) GetBotStatus(ctx context.Context, in *GetBotStatusRequest, opts ...grpc.CallOption) (*BotStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BotStatus)
+	err := c.cc.Invoke(ctx, Bot_GetBotStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *botClient) RestartBot(ctx context.Context, in *RestartBotRequest, opts ...grpc.CallOption) (*RestartBotReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RestartBotReply)
+	err := c.cc.Invoke(ctx, Bot_RestartBot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *botClient) SubscribeEvents(ctx context.Context, in *SubscribeEventsRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Event], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Bot_ServiceDesc.Streams[0], Bot_SubscribeEvents_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SubscribeEventsRequest, Event]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bot_SubscribeEventsClient = grpc.ServerStreamingClient[Event]
+
+func (c *botClient) ForwardUpdate(ctx context.Context, in *ForwardUpdateRequest, opts ...grpc.CallOption) (*ForwardUpdateReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ForwardUpdateReply)
+	err := c.cc.Invoke(ctx, Bot_ForwardUpdate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BotServer is the server API for Bot service.
 // All implementations must embed UnimplementedBotServer
 // for forward compatibility.
 type BotServer interface {
 	AddBot(context.Context, *AddBotRequest) (*AddBotReply, error)
 	RemoveBot(context.Context, *RemoveBotRequest) (*RemoveBotReply, error)
+	ListBots(context.Context, *ListBotsRequest) (*ListBotsReply, error)
+	GetBotStatus(context.Context, *GetBotStatusRequest) (*BotStatus, error)
+	RestartBot(context.Context, *RestartBotRequest) (*RestartBotReply, error)
+	SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error
+	ForwardUpdate(context.Context, *ForwardUpdateRequest) (*ForwardUpdateReply, error)
 	mustEmbedUnimplementedBotServer()
 }
 
@@ -81,6 +155,21 @@ func (UnimplementedBotServer) AddBot(context.Context, *AddBotRequest) (*AddBotRe
 func (UnimplementedBotServer) RemoveBot(context.Context, *RemoveBotRequest) (*RemoveBotReply, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveBot not implemented")
 }
+func (UnimplementedBotServer) ListBots(context.Context, *ListBotsRequest) (*ListBotsReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListBots not implemented")
+}
+func (UnimplementedBotServer) GetBotStatus(context.Context, *GetBotStatusRequest) (*BotStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBotStatus not implemented")
+}
+func (UnimplementedBotServer) RestartBot(context.Context, *RestartBotRequest) (*RestartBotReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestartBot not implemented")
+}
+func (UnimplementedBotServer) SubscribeEvents(*SubscribeEventsRequest, grpc.ServerStreamingServer[Event]) error {
+	return status.Errorf(codes.Unimplemented, "method SubscribeEvents not implemented")
+}
+func (UnimplementedBotServer) ForwardUpdate(context.Context, *ForwardUpdateRequest) (*ForwardUpdateReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ForwardUpdate not implemented")
+}
 func (UnimplementedBotServer) mustEmbedUnimplementedBotServer() {}
 func (UnimplementedBotServer) testEmbeddedByValue()             {}
 
@@ -138,6 +227,89 @@ func _Bot_RemoveBot_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Bot_ListBots_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBotsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServer).ListBots(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bot_ListBots_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServer).ListBots(ctx, req.(*ListBotsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bot_GetBotStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBotStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServer).GetBotStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bot_GetBotStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServer).GetBotStatus(ctx, req.(*GetBotStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bot_RestartBot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestartBotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServer).RestartBot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bot_RestartBot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServer).RestartBot(ctx, req.(*RestartBotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Bot_SubscribeEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SubscribeEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(BotServer).SubscribeEvents(m, &grpc.GenericServerStream[SubscribeEventsRequest, Event]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Bot_SubscribeEventsServer = grpc.ServerStreamingServer[Event]
+
+func _Bot_ForwardUpdate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ForwardUpdateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BotServer).ForwardUpdate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Bot_ForwardUpdate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BotServer).ForwardUpdate(ctx, req.(*ForwardUpdateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Bot_ServiceDesc is the grpc.ServiceDesc for Bot service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -153,7 +325,29 @@ var Bot_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveBot",
 			Handler:    _Bot_RemoveBot_Handler,
 		},
+		{
+			MethodName: "ListBots",
+			Handler:    _Bot_ListBots_Handler,
+		},
+		{
+			MethodName: "GetBotStatus",
+			Handler:    _Bot_GetBotStatus_Handler,
+		},
+		{
+			MethodName: "RestartBot",
+			Handler:    _Bot_RestartBot_Handler,
+		},
+		{
+			MethodName: "ForwardUpdate",
+			Handler:    _Bot_ForwardUpdate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "SubscribeEvents",
+			Handler:       _Bot_SubscribeEvents_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "bot.proto",
 }
@@ -6,18 +6,33 @@ import (
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
+
+	"ssuspy-common/crypto"
 )
 
 type Bot struct {
 	ID int64 `bson:"_id"`
 
-	Username    string `bson:"username"`
+	Username string `bson:"username"`
+	// SecretToken зашифрован, расшифровывает только business_bot
 	SecretToken string `bson:"secret_token"`
+	TokenHash   string `bson:"token_hash"`
+
+	// Quota - ограничения для пользователей бота, business_bot применяет их, если они строже серверных
+	Quota *Quota `bson:"quota,omitempty"`
 
 	UserID    int64     `bson:"user_id"`
 	CreatedAt time.Time `bson:"created_at"`
 }
 
+// Quota - то же, что repository.Quota в business_bot. 0 и пустая политика - значение сервера
+type Quota struct {
+	MaxMessages     int64  `bson:"max_messages,omitempty"`
+	MaxMessageBytes int64  `bson:"max_message_bytes,omitempty"`
+	MaxMediaBytes   int64  `bson:"max_media_bytes,omitempty"`
+	Policy          string `bson:"policy,omitempty"`
+}
+
 type FindBotWithUserCountsResult struct {
 	Bot                `bson:",inline"`
 	TotalUsers         int `bson:"totalUsers"`
@@ -115,7 +130,7 @@ func (r *MongoRepository) FindBotByToken(
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	filter := bson.M{"user_id": userId, "secret_token": token}
+	filter := bson.M{"user_id": userId, "token_hash": r.box.Hash(token)}
 
 	var bot Bot
 	if err := r.bots.FindOne(ctx, filter).Decode(&bot); err != nil {
@@ -153,18 +168,24 @@ func (r *MongoRepository) InsertBot(
 	token string,
 	username string,
 ) error {
+	encryptedToken, err := r.box.Encrypt(token, crypto.BotAAD(botID))
+	if err != nil {
+		return err
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
 	bot := &Bot{
 		ID:          botID,
-		SecretToken: token,
+		SecretToken: encryptedToken,
+		TokenHash:   r.box.Hash(token),
 		UserID:      userId,
 		CreatedAt:   time.Now(),
 		Username:    username,
 	}
 
-	_, err := r.bots.InsertOne(ctx, bot)
+	_, err = r.bots.InsertOne(ctx, bot)
 	return err
 }
 
@@ -181,3 +202,25 @@ func (r *MongoRepository) RemoveBot(
 	_, err := r.bots.DeleteOne(ctx, filter)
 	return err
 }
+
+// SetBotQuota сохраняет квоту бота, менять ее может только владелец
+func (r *MongoRepository) SetBotQuota(
+	ctx context.Context,
+	userId int64,
+	botID int64,
+	quota *Quota,
+) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	filter := bson.M{"user_id": userId, "_id": botID}
+
+	res, err := r.bots.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"quota": quota}})
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"ssuspy-common/crypto"
 	"ssuspy-creator-bot/config"
 )
 
@@ -16,13 +17,15 @@ type MongoRepository struct {
 	users    *mongo.Collection
 	bots     *mongo.Collection
 	counters *mongo.Collection
+
+	box *crypto.Box
 }
 
 type Sequence struct {
 	Value int64 `bson:"value"`
 }
 
-func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
+func NewMongoRepository(cfg *config.MongoConfig, box *crypto.Box) (*MongoRepository, error) {
 	uri := cfg.BuildMongoURI()
 
 	clientOptions := options.Client().ApplyURI(uri)
@@ -49,6 +52,7 @@ func NewMongoRepository(cfg *config.MongoConfig) (*MongoRepository, error) {
 		users:    userCollection,
 		bots:     botCollection,
 		counters: countersCollection,
+		box:      box,
 	}, nil
 }
 
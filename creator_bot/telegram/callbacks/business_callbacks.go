@@ -25,3 +25,22 @@ func NewHandleBotItemFromString(s string) (data *types.HandleBotItem, err error)
 
 	return data, nil
 }
+
+func NewHandleBotQuotaFromString(s string) (data *types.HandleBotQuota, err error) {
+	parts := strings.Split(s, "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return nil, fmt.Errorf("wrong number of parameters: expected 2 or 3, received %d", len(parts))
+	}
+
+	data = &types.HandleBotQuota{}
+
+	data.BotID, err = strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert BotID: %v", err)
+	}
+	if len(parts) == 3 {
+		data.Field = parts[2]
+	}
+
+	return data, nil
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"ssuspy-creator-bot/config"
 	"ssuspy-creator-bot/consts"
@@ -246,19 +247,77 @@ func (h *Handler) HandleBotItem(c *th.Context, update telego.Update) error {
 		return err
 	}
 
-	botInfo, err := h.service.FindBotWithUserCounts(c, internalUser.ID, data.BotID)
+	return h.showBotItem(c, query, loc, internalUser.ID, data.BotID)
+}
+
+func (h *Handler) HandleBotRestart(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	log := c.Value("log").(*zerolog.Logger)
+	internalUser := c.Value("internalUser").(*types.InternalUser)
+
+	data, err := callbacks.NewHandleBotItemFromString(query.Data)
 	if err != nil {
 		log.Warn().Err(err).Msg("failed get data")
 		utils.OnDataError(c, query.ID, loc)
 		return err
 	}
 
+	// перезапускать можно только своего бота
+	if _, err := h.service.FindBotWithUserCounts(c, internalUser.ID, data.BotID); err != nil {
+		log.Warn().Err(err).Msg("failed get data")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	messageID := "handleBotRestart.success"
+	if _, err := h.grpcClient.RestartBot(ctx, &proto.RestartBotRequest{Id: data.BotID}); err != nil {
+		log.Warn().Err(err).Int64("botID", data.BotID).Msg("failed restart bot")
+		messageID = "handleBotRestart.fail"
+	}
+
+	c.Bot().AnswerCallbackQuery(c, tu.CallbackQuery(query.ID).WithText(
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: messageID,
+		}),
+	))
+
+	return h.showBotItem(c, query, loc, internalUser.ID, data.BotID)
+}
+
+func (h *Handler) showBotItem(c *th.Context, query *telego.CallbackQuery, loc *i18n.Localizer, userID int64, botID int64) error {
+	log := c.Value("log").(*zerolog.Logger)
+
+	botInfo, err := h.service.FindBotWithUserCounts(c, userID, botID)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed get data")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	status, err := h.grpcClient.GetBotStatus(ctx, &proto.GetBotStatusRequest{Id: botID})
+	if err != nil {
+		log.Warn().Err(err).Int64("botID", botID).Msg("failed get bot status")
+	}
+
 	removeData := types.HandleBotRemove{
-		BotID: data.BotID,
+		BotID: botID,
+	}
+	restartData := types.HandleBotRestart{
+		BotID: botID,
+	}
+	quotaData := types.HandleBotQuota{
+		BotID: botID,
 	}
 
 	_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
-		tu.ID(internalUser.ID),
+		tu.ID(userID),
 		query.Message.GetMessageID(),
 		loc.MustLocalize(&i18n.LocalizeConfig{
 			MessageID: "handleBotItem.message",
@@ -266,9 +325,20 @@ func (h *Handler) HandleBotItem(c *th.Context, update telego.Update) error {
 				"Username":      botInfo.Username,
 				"Users":         botInfo.TotalUsers,
 				"BusinessUsers": botInfo.TotalBusinessUsers,
+				"Status":        botStatusText(loc, status),
 			},
 		}),
 	).WithReplyMarkup(tu.InlineKeyboard(
+		tu.InlineKeyboardRow(tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "handleBotItem.buttons.restart",
+			}),
+		).WithCallbackData(restartData.String())),
+		tu.InlineKeyboardRow(tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "handleBotItem.buttons.quota",
+			}),
+		).WithCallbackData(quotaData.String())),
 		tu.InlineKeyboardRow(tu.InlineKeyboardButton(
 			loc.MustLocalize(&i18n.LocalizeConfig{
 				MessageID: "handleBotItem.buttons.remove",
@@ -283,6 +353,76 @@ func (h *Handler) HandleBotItem(c *th.Context, update telego.Update) error {
 	return err
 }
 
+// botStatusText - строки статуса бота для экрана бота, status == nil - business_bot не ответил
+func botStatusText(loc *i18n.Localizer, status *proto.BotStatus) string {
+	if status == nil {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "handleBotItem.status.unknown",
+		})
+	}
+
+	messageID := "handleBotItem.status.stopped"
+	if status.Running {
+		messageID = "handleBotItem.status.running"
+	}
+	lines := []string{loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: messageID,
+	})}
+
+	if status.LastUpdateAt != 0 {
+		lines = append(lines, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "handleBotItem.status.lastUpdate",
+			TemplateData: map[string]any{
+				"Date": formatStatusDate(status.LastUpdateAt),
+			},
+		}))
+	}
+
+	if webhook := status.Webhook; webhook != nil {
+		lines = append(lines, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "handleBotItem.status.pendingUpdates",
+			TemplateData: map[string]any{
+				"Count": webhook.PendingUpdateCount,
+			},
+		}))
+		if webhook.LastErrorDate != 0 {
+			lines = append(lines, loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "handleBotItem.status.webhookError",
+				TemplateData: map[string]any{
+					"Error": truncateStatusError(webhook.LastErrorMessage),
+					"Date":  formatStatusDate(webhook.LastErrorDate),
+				},
+			}))
+		}
+	}
+
+	if status.LastError != "" {
+		lines = append(lines, loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "handleBotItem.status.lastError",
+			TemplateData: map[string]any{
+				"Error": truncateStatusError(status.LastError),
+				"Date":  formatStatusDate(status.LastErrorAt),
+			},
+		}))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func formatStatusDate(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("02.01.2006 15:04 UTC")
+}
+
+func truncateStatusError(text string) string {
+	const maxLen = 200
+
+	runes := []rune(text)
+	if len(runes) <= maxLen {
+		return text
+	}
+	return string(runes[:maxLen]) + "..."
+}
+
 func (h *Handler) HandleBotRemove(c *th.Context, update telego.Update) error {
 	query := update.CallbackQuery
 	loc := c.Value("loc").(*i18n.Localizer)
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"slices"
+	"strconv"
+
+	"ssuspy-creator-bot/consts"
+	"ssuspy-creator-bot/repository"
+	"ssuspy-creator-bot/telegram/callbacks"
+	"ssuspy-creator-bot/telegram/utils"
+	"ssuspy-creator-bot/types"
+
+	"github.com/mymmrac/telego"
+	th "github.com/mymmrac/telego/telegohandler"
+	tu "github.com/mymmrac/telego/telegoutil"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/rs/zerolog"
+)
+
+type quotaPreset struct {
+	value int64
+	label string
+}
+
+// quotaPresets - значения, между которыми переключается кнопка лимита. 0 - лимит сервера
+var quotaPresets = map[string][]quotaPreset{
+	consts.QUOTA_FIELD_MESSAGES: {
+		{0, ""},
+		{1_000, "1 000"},
+		{10_000, "10 000"},
+		{100_000, "100 000"},
+		{1_000_000, "1 000 000"},
+	},
+	consts.QUOTA_FIELD_MESSAGE_BYTES: {
+		{0, ""},
+		{10 << 20, "10 MB"},
+		{100 << 20, "100 MB"},
+		{1 << 30, "1 GB"},
+	},
+	consts.QUOTA_FIELD_MEDIA_BYTES: {
+		{0, ""},
+		{100 << 20, "100 MB"},
+		{1 << 30, "1 GB"},
+		{10 << 30, "10 GB"},
+	},
+}
+
+var quotaPolicies = []string{"", consts.QUOTA_POLICY_REJECT, consts.QUOTA_POLICY_DROP_OLDEST}
+
+// nextQuotaPreset - следующее значение после current, значение не из списка сбрасывается на первое
+func nextQuotaPreset(field string, current int64) int64 {
+	presets := quotaPresets[field]
+	i := slices.IndexFunc(presets, func(p quotaPreset) bool { return p.value == current })
+	return presets[(i+1)%len(presets)].value
+}
+
+func quotaValueText(loc *i18n.Localizer, field string, value int64) string {
+	if value == 0 {
+		return loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "handleBotQuota.default",
+		})
+	}
+
+	i := slices.IndexFunc(quotaPresets[field], func(p quotaPreset) bool { return p.value == value })
+	if i < 0 {
+		return strconv.FormatInt(value, 10)
+	}
+	return quotaPresets[field][i].label
+}
+
+func quotaPolicyText(loc *i18n.Localizer, policy string) string {
+	messageID := "handleBotQuota.policy.default"
+	switch policy {
+	case consts.QUOTA_POLICY_REJECT:
+		messageID = "handleBotQuota.policy.reject"
+	case consts.QUOTA_POLICY_DROP_OLDEST:
+		messageID = "handleBotQuota.policy.dropOldest"
+	}
+	return loc.MustLocalize(&i18n.LocalizeConfig{
+		MessageID: messageID,
+	})
+}
+
+func (h *Handler) HandleBotQuota(c *th.Context, update telego.Update) error {
+	query := update.CallbackQuery
+	loc := c.Value("loc").(*i18n.Localizer)
+	log := c.Value("log").(*zerolog.Logger)
+	internalUser := c.Value("internalUser").(*types.InternalUser)
+
+	data, err := callbacks.NewHandleBotQuotaFromString(query.Data)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed get data")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	// квоту можно менять только у своего бота
+	botInfo, err := h.service.FindBotWithUserCounts(c, internalUser.ID, data.BotID)
+	if err != nil {
+		log.Warn().Err(err).Msg("failed get data")
+		utils.OnDataError(c, query.ID, loc)
+		return err
+	}
+
+	quota := botInfo.Quota
+	if quota == nil {
+		quota = &repository.Quota{}
+	}
+
+	if data.Field != "" {
+		switch data.Field {
+		case consts.QUOTA_FIELD_MESSAGES:
+			quota.MaxMessages = nextQuotaPreset(data.Field, quota.MaxMessages)
+		case consts.QUOTA_FIELD_MESSAGE_BYTES:
+			quota.MaxMessageBytes = nextQuotaPreset(data.Field, quota.MaxMessageBytes)
+		case consts.QUOTA_FIELD_MEDIA_BYTES:
+			quota.MaxMediaBytes = nextQuotaPreset(data.Field, quota.MaxMediaBytes)
+		case consts.QUOTA_FIELD_POLICY:
+			i := slices.Index(quotaPolicies, quota.Policy)
+			quota.Policy = quotaPolicies[(i+1)%len(quotaPolicies)]
+		default:
+			utils.OnDataError(c, query.ID, loc)
+			return nil
+		}
+
+		if err := h.service.SetBotQuota(c, internalUser.ID, data.BotID, quota); err != nil {
+			log.Warn().Err(err).Int64("botID", data.BotID).Msg("failed set bot quota")
+			utils.OnDataError(c, query.ID, loc)
+			return err
+		}
+	}
+
+	button := func(messageID string, field string, value string) []telego.InlineKeyboardButton {
+		fieldData := types.HandleBotQuota{BotID: data.BotID, Field: field}
+		return tu.InlineKeyboardRow(tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: messageID,
+				TemplateData: map[string]any{
+					"Value": value,
+				},
+			}),
+		).WithCallbackData(fieldData.String()))
+	}
+
+	itemData := types.HandleBotItem{
+		BotID: data.BotID,
+	}
+
+	_, err = c.Bot().EditMessageText(c, tu.EditMessageText(
+		tu.ID(internalUser.ID),
+		query.Message.GetMessageID(),
+		loc.MustLocalize(&i18n.LocalizeConfig{
+			MessageID: "handleBotQuota.message",
+			TemplateData: map[string]any{
+				"Username": botInfo.Username,
+			},
+		}),
+	).WithReplyMarkup(tu.InlineKeyboard(
+		button("handleBotQuota.buttons.messages", consts.QUOTA_FIELD_MESSAGES,
+			quotaValueText(loc, consts.QUOTA_FIELD_MESSAGES, quota.MaxMessages)),
+		button("handleBotQuota.buttons.messageBytes", consts.QUOTA_FIELD_MESSAGE_BYTES,
+			quotaValueText(loc, consts.QUOTA_FIELD_MESSAGE_BYTES, quota.MaxMessageBytes)),
+		button("handleBotQuota.buttons.mediaBytes", consts.QUOTA_FIELD_MEDIA_BYTES,
+			quotaValueText(loc, consts.QUOTA_FIELD_MEDIA_BYTES, quota.MaxMediaBytes)),
+		button("handleBotQuota.buttons.policy", consts.QUOTA_FIELD_POLICY,
+			quotaPolicyText(loc, quota.Policy)),
+		tu.InlineKeyboardRow(tu.InlineKeyboardButton(
+			loc.MustLocalize(&i18n.LocalizeConfig{
+				MessageID: "back",
+			}),
+		).WithCallbackData(itemData.String())),
+	)))
+	return err
+}
@@ -29,3 +29,24 @@ type HandleBotRemove struct {
 func (h *HandleBotRemove) String() string {
 	return fmt.Sprintf("%s|%d", consts.CALLBACK_PREFIX_BOT_REMOVE, h.BotID)
 }
+
+type HandleBotRestart struct {
+	BotID int64
+}
+
+func (h *HandleBotRestart) String() string {
+	return fmt.Sprintf("%s|%d", consts.CALLBACK_PREFIX_BOT_RESTART, h.BotID)
+}
+
+type HandleBotQuota struct {
+	BotID int64
+	// Field - поле квоты, которое нужно переключить, пустое - только показать экран
+	Field string
+}
+
+func (h *HandleBotQuota) String() string {
+	if h.Field == "" {
+		return fmt.Sprintf("%s|%d", consts.CALLBACK_PREFIX_BOT_QUOTA, h.BotID)
+	}
+	return fmt.Sprintf("%s|%d|%s", consts.CALLBACK_PREFIX_BOT_QUOTA, h.BotID, h.Field)
+}